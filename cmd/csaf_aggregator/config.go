@@ -10,12 +10,17 @@ package main
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
+	"regexp"
 	"runtime"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/ProtonMail/gopenpgp/v2/crypto"
@@ -30,14 +35,126 @@ const (
 	defaultFolder     = "/var/www"
 	defaultWeb        = "/var/www/html"
 	defaultDomain     = "https://example.com"
+
+	defaultFullInterval    = 24 * time.Hour
+	defaultInterimInterval = time.Hour
 )
 
+// duration is a time.Duration which can be given
+// as string like "1h30m" in the configuration.
+type duration time.Duration
+
+// UnmarshalText implements the encoding.TextUnmarshaller interface.
+func (d *duration) UnmarshalText(data []byte) error {
+	v, err := time.ParseDuration(string(data))
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
 type provider struct {
 	Name   string `toml:"name"`
 	Domain string `toml:"domain"`
 	// Rate gives the provider specific rate limiting (see overall Rate).
 	Rate     *float64 `toml:"rate"`
 	Insecure *bool    `toml:"insecure"`
+	// FullInterval gives the provider specific interval of
+	// the full runs in daemon mode (see overall FullInterval).
+	FullInterval *duration `toml:"full_interval"`
+	// InterimInterval gives the provider specific interval of
+	// the interim runs in daemon mode (see overall InterimInterval).
+	InterimInterval *duration `toml:"interim_interval"`
+	// Publisher lists the provider in the csaf_publishers
+	// instead of the csaf_providers of the aggregator.
+	Publisher bool `toml:"publisher"`
+	// UpdateInterval tells how often a publisher is checked
+	// for new advisories, e.g. "daily". Mandatory for publishers.
+	UpdateInterval string `toml:"update_interval"`
+	// AllowUnsigned gives the provider specific
+	// handling of unsigned advisories (see overall AllowUnsigned).
+	AllowUnsigned *bool `toml:"allow_unsigned"`
+
+	// TLPLabels restricts the mirrored advisories to these TLP labels.
+	TLPLabels []string `toml:"tlp_labels"`
+	// ReleasedAfter restricts the mirrored advisories to
+	// those initially released after this time.
+	ReleasedAfter *time.Time `toml:"released_after"`
+	// Include are regular expressions. If given only advisories
+	// with a matching document ID or filename are mirrored.
+	Include []string `toml:"include"`
+	// Exclude are regular expressions of the document IDs
+	// or filenames of advisories which are not mirrored.
+	Exclude []string `toml:"exclude"`
+	// FinalOnly restricts the mirrored advisories
+	// to those with the tracking status "final".
+	FinalOnly bool `toml:"final_only"`
+	// Retention gives the provider specific retention
+	// policy (see overall Retention).
+	Retention string `toml:"retention"`
+	// ClientCert and ClientKey are the files of a PEM encoded
+	// TLS client certificate to authenticate at the provider.
+	ClientCert *string `toml:"client_cert"`
+	ClientKey  *string `toml:"client_key"`
+	// CACert is a file of PEM encoded certificates of additional
+	// CAs to verify the TLS server certificates of the provider.
+	CACert *string `toml:"ca_cert"`
+	// Headers are extra HTTP headers sent with all
+	// requests for the provider, e.g. API tokens.
+	Headers map[string]string `toml:"headers"`
+
+	include, exclude []*regexp.Regexp
+
+	// certificates and rootCAs are loaded from the
+	// ClientCert, ClientKey and CACert files.
+	certificates []tls.Certificate
+	rootCAs      *x509.CertPool
+
+	// metadataURLs are the locations of the provider metadata
+	// to be tried instead of looking it up by the domain.
+	metadataURLs []string
+	// metadata is the entry of the provider in the aggregator
+	// of a source. It is used if the provider is taken from a mirror.
+	metadata *csaf.AggregatorCSAFProviderMetadata
+}
+
+// fullInterval returns the interval of the full runs of the provider.
+func (p *provider) fullInterval(c *config) time.Duration {
+	if p.FullInterval != nil {
+		return time.Duration(*p.FullInterval)
+	}
+	return time.Duration(*c.FullInterval)
+}
+
+// retention returns the retention policy of the
+// advisories removed by the provider.
+func (p *provider) retention(c *config) string {
+	if p.Retention != "" {
+		return p.Retention
+	}
+	return c.Retention
+}
+
+// allowUnsigned returns true if advisories of the provider
+// without verifiable signatures are mirrored.
+func (p *provider) allowUnsigned(c *config) bool {
+	if p.AllowUnsigned != nil {
+		return *p.AllowUnsigned
+	}
+	return c.AllowUnsigned
+}
+
+// interimInterval returns the interval of the interim runs of the provider.
+// Less/equal zero means no interim runs.
+func (p *provider) interimInterval(c *config) time.Duration {
+	if !c.runAsMirror() {
+		return 0
+	}
+	if p.InterimInterval != nil {
+		return time.Duration(*p.InterimInterval)
+	}
+	return time.Duration(*c.InterimInterval)
 }
 
 type config struct {
@@ -52,11 +169,56 @@ type config struct {
 	Insecure            *bool               `toml:"insecure"`
 	Aggregator          csaf.AggregatorInfo `toml:"aggregator"`
 	Providers           []*provider         `toml:"providers"`
+	Sources             []*source           `toml:"sources"`
 	OpenPGPPrivateKey   string              `toml:"openpgp_private_key"`
 	OpenPGPPublicKey    string              `toml:"openpgp_public_key"`
 	Passphrase          *string             `toml:"passphrase"`
 	AllowSingleProvider bool                `toml:"allow_single_provider"`
 
+	// EnforceMandatoryTests rejects advisories failing
+	// the mandatory tests of the CSAF standard.
+	EnforceMandatoryTests bool `toml:"enforce_mandatory_tests"`
+
+	// OptionalTests runs the optional and informative tests
+	// and reports their findings without rejecting advisories.
+	OptionalTests bool `toml:"optional_tests"`
+
+	// EnforceOptionalTests rejects advisories failing one of
+	// the listed optional or informative tests.
+	EnforceOptionalTests []string `toml:"enforce_optional_tests"`
+
+	// Retention is the policy for advisories removed by
+	// the providers: "delete", "keep" or "archive".
+	Retention string `toml:"retention"`
+
+	// Quarantine is the folder where advisories are stored
+	// which fail the verification of their hashes or signatures.
+	Quarantine string `toml:"quarantine"`
+
+	// AllowUnsigned mirrors advisories which signatures cannot
+	// be verified because they are missing or the provider has
+	// no usable public OpenPGP keys. They are signed with our key.
+	AllowUnsigned bool `toml:"allow_unsigned"`
+
+	// Report is the file to write the JSON report of each run to.
+	Report string `toml:"report"`
+
+	// Retries is the number of retries of requests which failed
+	// because of network errors or transient server errors.
+	Retries int `toml:"retries"`
+
+	// Cache sends conditional requests for the responses
+	// of former runs with an ETag or a Last-Modified header.
+	Cache bool `toml:"cache"`
+
+	// MetricsFile is the file to write Prometheus metrics to after
+	// each run, e.g. for the textfile collector of the node exporter.
+	MetricsFile string `toml:"metrics_file"`
+
+	// MetricsAddress is the address to serve Prometheus metrics
+	// on in daemon mode, e.g. "localhost:9469".
+	MetricsAddress string `toml:"metrics_address"`
+
 	// LockFile tries to lock to a given file.
 	LockFile *string `toml:"lock_file"`
 
@@ -67,9 +229,36 @@ type config struct {
 	// for interim advisories. Less/equal zero means forever.
 	InterimYears int `toml:"interim_years"`
 
+	// FullInterval is the interval of the full runs in daemon mode.
+	FullInterval *duration `toml:"full_interval"`
+
+	// InterimInterval is the interval of the interim runs in daemon mode.
+	// Less/equal zero means no interim runs.
+	InterimInterval *duration `toml:"interim_interval"`
+
 	keyMu  sync.Mutex
 	key    *crypto.Key
 	keyErr error
+
+	metrics *util.Metrics
+
+	cachesMu sync.Mutex
+	caches   map[string]*util.HTTPCache
+
+	// configured are the providers without the ones from the sources.
+	configured []*provider
+
+	// dryRun only reports what a run would do.
+	dryRun bool
+}
+
+// tests returns the configuration of the CSAF tests run on the advisories.
+func (c *config) tests() *csaf.TestConfig {
+	return &csaf.TestConfig{
+		Mandatory: c.EnforceMandatoryTests,
+		Optional:  c.OptionalTests,
+		Enforce:   c.EnforceOptionalTests,
+	}
 }
 
 // runAsMirror determines if the aggregator should run in mirror mode.
@@ -96,49 +285,146 @@ func (c *config) privateOpenPGPKey() (*crypto.Key, error) {
 	return c.key, c.keyErr
 }
 
+// loadTLS loads the TLS client certificate and
+// the additional CAs of the provider.
+func (p *provider) loadTLS() error {
+	if (p.ClientCert == nil) != (p.ClientKey == nil) {
+		return fmt.Errorf(
+			"both client_cert and client_key must be given for provider '%s'", p.Name)
+	}
+	if p.ClientCert != nil {
+		cert, err := tls.LoadX509KeyPair(*p.ClientCert, *p.ClientKey)
+		if err != nil {
+			return fmt.Errorf("cannot load client certificate of provider '%s': %v",
+				p.Name, err)
+		}
+		p.certificates = []tls.Certificate{cert}
+	}
+	if p.CACert != nil {
+		pem, err := os.ReadFile(*p.CACert)
+		if err != nil {
+			return fmt.Errorf("cannot load CA certificates of provider '%s': %v",
+				p.Name, err)
+		}
+		if p.rootCAs, err = x509.SystemCertPool(); err != nil {
+			p.rootCAs = x509.NewCertPool()
+		}
+		if !p.rootCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no CA certificates found in '%s' of provider '%s'",
+				*p.CACert, p.Name)
+		}
+	}
+	return nil
+}
+
+// httpCache returns the cache of the responses for a provider.
+// It lives as long as the process to be used by the following runs.
+func (c *config) httpCache(name string) *util.HTTPCache {
+	c.cachesMu.Lock()
+	defer c.cachesMu.Unlock()
+	if c.caches == nil {
+		c.caches = make(map[string]*util.HTTPCache)
+	}
+	cache := c.caches[name]
+	if cache == nil {
+		cache = new(util.HTTPCache)
+		c.caches[name] = cache
+	}
+	return cache
+}
+
+// host returns the host name of the domain of the provider.
+func (p *provider) host() string {
+	domain := p.Domain
+	if !strings.Contains(domain, "://") {
+		domain = "https://" + domain
+	}
+	if u, err := url.Parse(domain); err == nil {
+		return u.Hostname()
+	}
+	return p.Domain
+}
+
 func (c *config) httpClient(p *provider) util.Client {
 
 	hClient := http.Client{}
-	if p.Insecure != nil && *p.Insecure || c.Insecure != nil && *c.Insecure {
+	insecure := p.Insecure != nil && *p.Insecure || c.Insecure != nil && *c.Insecure
+	if insecure || p.certificates != nil || p.rootCAs != nil {
 		hClient.Transport = &http.Transport{
 			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
+				InsecureSkipVerify: insecure,
+				Certificates:       p.certificates,
+				RootCAs:            p.rootCAs,
 			},
 		}
 	}
 
-	var client util.Client
+	var client util.Client = &hClient
 
-	if c.Verbose {
-		client = &util.LoggingClient{Client: &hClient}
-	} else {
-		client = &hClient
+	if len(p.Headers) > 0 {
+		header := make(http.Header, len(p.Headers))
+		for key, value := range p.Headers {
+			header.Set(key, value)
+		}
+		hc := &util.HeaderClient{
+			Client: client,
+			Header: header,
+			Hosts:  []string{p.host()},
+		}
+		hClient.CheckRedirect = hc.CheckRedirect
+		client = hc
 	}
 
-	if p.Rate == nil && c.Rate == nil {
-		return client
+	if c.Cache {
+		client = &util.CachingClient{Client: client, Cache: c.httpCache(p.Name)}
 	}
 
-	var r float64
-	if c.Rate != nil {
-		r = *c.Rate
+	if c.Verbose {
+		client = &util.LoggingClient{Client: client}
 	}
-	if p.Rate != nil {
-		r = *p.Rate
+
+	if p.Rate != nil || c.Rate != nil {
+		var r float64
+		if c.Rate != nil {
+			r = *c.Rate
+		}
+		if p.Rate != nil {
+			r = *p.Rate
+		}
+		client = &util.LimitingClient{
+			Client:  client,
+			Limiter: rate.NewLimiter(rate.Limit(r), 1),
+		}
 	}
-	return &util.LimitingClient{
-		Client:  client,
-		Limiter: rate.NewLimiter(rate.Limit(r), 1),
+
+	if c.metrics != nil {
+		client = &metricsClient{
+			Client:   client,
+			metrics:  c.metrics,
+			provider: p.Name,
+		}
+	}
+
+	// Retry outermost to rate limit and count each attempt.
+	if c.Retries > 0 {
+		client = &util.RetryingClient{Client: client, Retries: c.Retries}
 	}
+
+	return client
 }
 
 func (c *config) checkProviders() error {
 
+	if len(c.Providers) == 0 {
+		return errors.New("no providers given in configuration or sources")
+	}
+
 	if !c.AllowSingleProvider && len(c.Providers) < 2 {
 		return errors.New("need at least two providers")
 	}
 
 	already := make(map[string]bool)
+	var publishers int
 
 	for _, p := range c.Providers {
 		if p.Name == "" {
@@ -147,11 +433,39 @@ func (c *config) checkProviders() error {
 		if p.Domain == "" {
 			return errors.New("no domain given for provider")
 		}
+		if p.FullInterval != nil && *p.FullInterval <= 0 {
+			return fmt.Errorf("full_interval of provider '%s' must be positive", p.Name)
+		}
+		if p.Publisher {
+			if !c.runAsMirror() {
+				return fmt.Errorf("publisher '%s' needs an aggregator to be mirrored", p.Name)
+			}
+			if p.UpdateInterval == "" {
+				return fmt.Errorf("no update_interval given for publisher '%s'", p.Name)
+			}
+			publishers++
+		} else if p.UpdateInterval != "" {
+			return fmt.Errorf("update_interval given for provider '%s' which is no publisher", p.Name)
+		}
+		if p.Retention != "" && !validRetention(p.Retention) {
+			return fmt.Errorf("invalid retention '%s' for provider '%s'",
+				p.Retention, p.Name)
+		}
+		if err := p.compileFilters(); err != nil {
+			return err
+		}
+		if err := p.loadTLS(); err != nil {
+			return err
+		}
 		if already[p.Name] {
 			return fmt.Errorf("provider '%s' is configured more than once", p.Name)
 		}
 		already[p.Name] = true
 	}
+
+	if publishers == len(c.Providers) {
+		return errors.New("need at least one provider which is not a publisher")
+	}
 	return nil
 }
 
@@ -168,6 +482,20 @@ func (c *config) setDefaults() {
 		c.Domain = defaultDomain
 	}
 
+	if c.Retention == "" {
+		c.Retention = retentionDelete
+	}
+
+	if c.FullInterval == nil {
+		d := duration(defaultFullInterval)
+		c.FullInterval = &d
+	}
+
+	if c.InterimInterval == nil {
+		d := duration(defaultInterimInterval)
+		c.InterimInterval = &d
+	}
+
 	if c.Workers <= 0 {
 		if n := runtime.NumCPU(); n > defaultWorkers {
 			c.Workers = defaultWorkers
@@ -175,14 +503,10 @@ func (c *config) setDefaults() {
 			c.Workers = n
 		}
 	}
-
-	if c.Workers > len(c.Providers) {
-		c.Workers = len(c.Providers)
-	}
 }
 
 func (c *config) check() error {
-	if len(c.Providers) == 0 {
+	if len(c.Providers) == 0 && len(c.Sources) == 0 {
 		return errors.New("no providers given in configuration")
 	}
 
@@ -190,7 +514,25 @@ func (c *config) check() error {
 		return err
 	}
 
-	return c.checkProviders()
+	if *c.FullInterval <= 0 {
+		return errors.New("full_interval must be positive")
+	}
+
+	if !validRetention(c.Retention) {
+		return fmt.Errorf("invalid retention '%s'", c.Retention)
+	}
+
+	if c.Retries < 0 {
+		return errors.New("retries must not be negative")
+	}
+
+	for _, s := range c.Sources {
+		if err := s.check(); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func loadConfig(path string) (*config, error) {
@@ -209,5 +551,18 @@ func loadConfig(path string) (*config, error) {
 		return nil, err
 	}
 
+	// This checks the providers, too.
+	if err := cfg.resolveSources(); err != nil {
+		return nil, err
+	}
+
+	if cfg.MetricsFile != "" || cfg.MetricsAddress != "" {
+		cfg.metrics = newMetrics()
+		// Continue counting from the last run.
+		if err := cfg.metrics.ReadFile(cfg.MetricsFile); err != nil {
+			return nil, err
+		}
+	}
+
 	return &cfg, nil
 }
@@ -0,0 +1,246 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// minBackoff is the delay before a failed run is retried.
+// It is doubled with each further failure up to the interval of the run.
+const minBackoff = time.Minute
+
+// task is a periodic run for a provider in daemon mode.
+type task struct {
+	next     time.Time
+	last     time.Time // last successful run
+	failures int
+}
+
+// due checks if the task has to be run.
+func (t *task) due(now time.Time) bool {
+	return !now.Before(t.next)
+}
+
+// done schedules the next run of the task after a run.
+// Failed runs are retried with an increasing delay.
+func (t *task) done(now time.Time, interval time.Duration, failed bool) {
+	if !failed {
+		t.failures = 0
+		t.last = now
+		t.next = now.Add(interval)
+		return
+	}
+	t.failures++
+	backoff := minBackoff
+	for i := 1; i < t.failures && backoff < interval; i++ {
+		backoff *= 2
+	}
+	if backoff > interval {
+		backoff = interval
+	}
+	t.next = now.Add(backoff)
+}
+
+// schedule are the periodic runs of a provider.
+type schedule struct {
+	provider *provider
+	full     task
+	interim  task
+}
+
+// interimEnabled checks if interim runs are scheduled for the provider.
+// This is not the case before its first successful full run.
+func (s *schedule) interimEnabled(cfg *config) bool {
+	return !s.full.last.IsZero() && s.provider.interimInterval(cfg) > 0
+}
+
+// interimDue checks if an interim run has to be done for the provider.
+func (s *schedule) interimDue(now time.Time, cfg *config) bool {
+	return s.interimEnabled(cfg) && s.interim.due(now)
+}
+
+// daemon runs the full and interim runs of the providers
+// periodically until the process is interrupted.
+func (p *processor) daemon() error {
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	p.serveMetrics()
+
+	schedules := make([]*schedule, len(p.cfg.Providers))
+	for i, prv := range p.cfg.Providers {
+		// Start with a full run. Interim runs follow after it.
+		schedules[i] = &schedule{provider: prv}
+	}
+
+	for first := true; ; first = false {
+		now := time.Now()
+
+		// The providers were just resolved when loading the configuration.
+		if !first && anyFullDue(schedules, now) {
+			schedules = p.updateSchedules(schedules)
+		}
+
+		var full, interim []*schedule
+		for _, s := range schedules {
+			switch {
+			case s.full.due(now):
+				full = append(full, s)
+			case s.interimDue(now, p.cfg):
+				interim = append(interim, s)
+			}
+		}
+
+		if len(full) > 0 || len(interim) > 0 {
+			p.run(full, interim)
+		}
+
+		// Sleep until the next task is due.
+		next := schedules[0].full.next
+		for _, s := range schedules {
+			if s.full.next.Before(next) {
+				next = s.full.next
+			}
+			if s.interimEnabled(p.cfg) && s.interim.next.Before(next) {
+				next = s.interim.next
+			}
+		}
+		wait := time.Until(next)
+		if wait < 0 {
+			wait = 0
+		}
+		log.Printf("next run in %s\n", wait.Round(time.Second))
+
+		timer := time.NewTimer(wait)
+		select {
+		case sig := <-sigs:
+			timer.Stop()
+			log.Printf("received %s, stopping\n", sig)
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// anyFullDue checks if a full run is due for any of the providers.
+func anyFullDue(schedules []*schedule, now time.Time) bool {
+	for _, s := range schedules {
+		if s.full.due(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// updateSchedules resolves the sources again and returns the schedules
+// of the resulting providers. Providers keep their schedules by name.
+// New providers start with a full run.
+func (p *processor) updateSchedules(schedules []*schedule) []*schedule {
+	if len(p.cfg.Sources) == 0 {
+		return schedules
+	}
+	if err := p.cfg.resolveSources(); err != nil {
+		log.Printf("error: %v\n", err)
+		return schedules
+	}
+	byName := make(map[string]*schedule, len(schedules))
+	for _, s := range schedules {
+		byName[s.provider.Name] = s
+	}
+	updated := make([]*schedule, len(p.cfg.Providers))
+	for i, prv := range p.cfg.Providers {
+		s := byName[prv.Name]
+		if s == nil {
+			s = &schedule{}
+		}
+		s.provider = prv
+		updated[i] = s
+	}
+	return updated
+}
+
+// run performs the given full and interim runs. Runs do not
+// overlap with runs of other processes using the lock file.
+func (p *processor) run(full, interim []*schedule) {
+
+	providers := func(schedules []*schedule) []*provider {
+		prvs := make([]*provider, len(schedules))
+		for i, s := range schedules {
+			prvs[i] = s.provider
+		}
+		return prvs
+	}
+
+	var fullFailed, interimFailed map[string]bool
+
+	if err := lock(p.cfg.LockFile, func() error {
+		if err := p.prepare(); err != nil {
+			return err
+		}
+		p.startReport()
+		defer p.writeReport()
+		defer p.writeMetrics()
+		if len(full) > 0 {
+			var err error
+			if fullFailed, err = p.full(providers(full)); err != nil {
+				log.Printf("error: %v\n", err)
+			}
+		}
+		if len(interim) > 0 {
+			var err error
+			if interimFailed, err = p.interim(providers(interim)); err != nil {
+				log.Printf("error: %v\n", err)
+			}
+		}
+		return nil
+	}); err != nil {
+		// Nothing was done. Try again later.
+		log.Printf("error: %v\n", err)
+		retry := time.Now().Add(minBackoff)
+		for _, s := range full {
+			s.full.next = retry
+		}
+		for _, s := range interim {
+			s.interim.next = retry
+		}
+		return
+	}
+
+	now := time.Now()
+
+	for _, s := range full {
+		failed := fullFailed[s.provider.Name]
+		s.full.done(now, s.provider.fullInterval(p.cfg), failed)
+		if failed {
+			log.Printf("'%s': full run failed %d time(s), retrying at %s\n",
+				s.provider.Name, s.full.failures,
+				s.full.next.Format(time.RFC3339))
+		} else {
+			// The full run includes the interim advisories.
+			s.interim.next = now.Add(s.provider.interimInterval(p.cfg))
+		}
+	}
+
+	for _, s := range interim {
+		failed := interimFailed[s.provider.Name]
+		s.interim.done(now, s.provider.interimInterval(p.cfg), failed)
+		if failed {
+			log.Printf("'%s': interim run failed %d time(s), retrying at %s\n",
+				s.provider.Name, s.interim.failures,
+				s.interim.next.Format(time.RFC3339))
+		}
+	}
+}
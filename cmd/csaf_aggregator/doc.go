@@ -2,6 +2,7 @@
 // CSAF 2.0 specification
 // (https://docs.oasis-open.org/csaf/csaf/v2.0/csd02/csaf-v2.0-csd02.html)
 //
-// TODO: To be called periodically, e.g with cron
+// To be called periodically, e.g with cron, or to be run
+// with --daemon to schedule the runs itself.
 
 package main
@@ -0,0 +1,106 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// dryRun performs a full run without writing to the folders,
+// signing advisories or switching the mirrors.
+// The plan of the run is printed to stdout.
+func (p *processor) dryRun() error {
+
+	p.report = newRunReport()
+	defer func() { p.report = nil }()
+
+	_, err := p.full(p.cfg.Providers)
+
+	out := bufio.NewWriter(os.Stdout)
+	p.report.printPlan(out, p.cfg.runAsMirror())
+	if err := out.Flush(); err != nil {
+		return err
+	}
+
+	if p.cfg.Report != "" {
+		if err := p.report.write(p.cfg.Report); err != nil {
+			log.Printf("error: writing report failed: %v\n", err)
+		}
+	}
+	return err
+}
+
+// printPlan writes the report of a dry run in human readable form.
+func (rr *runReport) printPlan(w io.Writer, mirror bool) {
+
+	list := func(title string, items []string) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "  %s (%d):\n", title, len(items))
+		for _, item := range items {
+			fmt.Fprintf(w, "    %s\n", item)
+		}
+	}
+
+	yesNo := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+
+	action := "listing"
+	if mirror {
+		action = "mirroring"
+	}
+
+	for _, pr := range rr.Providers {
+		fmt.Fprintf(w, "provider '%s':\n", pr.Name)
+		list("discovery", pr.Discovery)
+		if pr.Metadata == "" {
+			fmt.Fprintln(w, "  provider-metadata.json: not found")
+		} else {
+			fmt.Fprintf(w, "  provider-metadata.json: %s\n", pr.Metadata)
+			fmt.Fprintf(w, "  %s allowed: %s\n", action, yesNo(pr.Allowed))
+		}
+		if pr.Allowed {
+			fmt.Fprintf(w, "  advisories: %d\n", pr.Advisories)
+			for _, label := range mirrorLabels {
+				if n, ok := pr.Labels[label]; ok {
+					fmt.Fprintf(w, "    %s: %d\n", label, n)
+				}
+			}
+		}
+		if mirror {
+			list("new", pr.New)
+			list("changed", pr.Changed)
+			if pr.Unchanged > 0 {
+				fmt.Fprintf(w, "  unchanged: %d\n", pr.Unchanged)
+			}
+			removed := make([]string, len(pr.Removed))
+			for i, r := range pr.Removed {
+				removed[i] = r.Path + " (" + r.Retention + ")"
+			}
+			list("removed", removed)
+			list("invalid", pr.InvalidReasons)
+			list("rejected", pr.RejectedReasons)
+			if pr.Filtered > 0 {
+				fmt.Fprintf(w, "  filtered: %d\n", pr.Filtered)
+			}
+		}
+		if pr.Error != "" {
+			fmt.Fprintf(w, "  error: %s\n", pr.Error)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/csaf-poc/csaf_distribution/csaf"
+)
+
+// statusFinal is the tracking status of final advisories.
+const statusFinal = "final"
+
+// compileFilters checks the filters of the provider
+// and compiles its regular expressions.
+func (p *provider) compileFilters() error {
+	for _, label := range p.TLPLabels {
+		var tlpLabel csaf.TLPLabel
+		if err := tlpLabel.UnmarshalText([]byte(strings.ToUpper(label))); err != nil {
+			return fmt.Errorf("invalid TLP label '%s' for provider '%s'", label, p.Name)
+		}
+	}
+	compile := func(exprs []string) ([]*regexp.Regexp, error) {
+		res := make([]*regexp.Regexp, len(exprs))
+		for i, expr := range exprs {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern '%s' for provider '%s': %v",
+					expr, p.Name, err)
+			}
+			res[i] = re
+		}
+		return res, nil
+	}
+	var err error
+	if p.include, err = compile(p.Include); err != nil {
+		return err
+	}
+	p.exclude, err = compile(p.Exclude)
+	return err
+}
+
+// labelSelected checks if advisories with the given TLP label are mirrored.
+func (p *provider) labelSelected(label string) bool {
+	if len(p.TLPLabels) == 0 {
+		return true
+	}
+	for _, l := range p.TLPLabels {
+		if strings.EqualFold(l, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches checks if one of the regular expressions matches
+// the filename or the document ID of an advisory.
+func matches(res []*regexp.Regexp, filename, id string) bool {
+	for _, re := range res {
+		if re.MatchString(filename) || id != "" && re.MatchString(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// nameSelected checks the include and exclude patterns against
+// the filename and the document ID of an advisory.
+func (p *provider) nameSelected(filename, id string) bool {
+	return (len(p.include) == 0 || matches(p.include, filename, id)) &&
+		!matches(p.exclude, filename, id)
+}
+
+// releaseSelected checks if an advisory with the given
+// initial release date is mirrored.
+func (p *provider) releaseSelected(initial time.Time) bool {
+	return p.ReleasedAfter == nil || initial.After(*p.ReleasedAfter)
+}
+
+// preFilter checks if an advisory is to be mirrored before downloading
+// it. The ROLIE entry of the advisory is used if there is one.
+func (p *provider) preFilter(filename string, label *csaf.TLPLabel, entry *csaf.Entry) bool {
+	if label != nil && !p.labelSelected(string(*label)) {
+		return false
+	}
+	if entry == nil {
+		// The document ID is not known before downloading.
+		return !matches(p.exclude, filename, "")
+	}
+	if published := time.Time(entry.Published); !published.IsZero() &&
+		!p.releaseSelected(published) {
+		return false
+	}
+	return p.nameSelected(filename, entry.ID)
+}
+
+// postFilter checks if an advisory is to be mirrored by its content.
+func (p *provider) postFilter(filename, label string, sum *csaf.AdvisorySummary) bool {
+	return p.labelSelected(label) &&
+		p.nameSelected(filename, sum.ID) &&
+		p.releaseSelected(sum.InitialReleaseDate) &&
+		(!p.FinalOnly || sum.Status == statusFinal)
+}
+
+// filtered counts an advisory which is not selected by the filters.
+func (w *worker) filtered(file string) {
+	w.report.Filtered++
+	if w.cfg.Verbose {
+		log.Printf("%s: filtered\n", file)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/csaf-poc/csaf_distribution/csaf"
+)
+
+// filterProvider returns a provider with compiled filters.
+func filterProvider(t *testing.T, p *provider) *provider {
+	if err := p.compileFilters(); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestCompileFilters(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		prv  *provider
+		fail bool
+	}{
+		{"empty", &provider{}, false},
+		{"valid", &provider{
+			TLPLabels: []string{"white", "GREEN"},
+			Include:   []string{`^acme-`},
+			Exclude:   []string{`test`},
+		}, false},
+		{"invalid label", &provider{TLPLabels: []string{"blue"}}, true},
+		{"invalid include", &provider{Include: []string{`(`}}, true},
+		{"invalid exclude", &provider{Exclude: []string{`[`}}, true},
+	} {
+		if err := tc.prv.compileFilters(); (err != nil) != tc.fail {
+			t.Errorf("%s: expected failure %t, got %v", tc.name, tc.fail, err)
+		}
+	}
+}
+
+func TestNameSelected(t *testing.T) {
+	p := filterProvider(t, &provider{
+		Include: []string{`^acme-`, `^ACME-`},
+		Exclude: []string{`-test`},
+	})
+	for _, tc := range []struct {
+		filename, id string
+		want         bool
+	}{
+		{"acme-2022-1.json", "", true},
+		{"other-2022-1.json", "ACME-2022-1", true},
+		{"other-2022-1.json", "", false},
+		{"other-2022-1.json", "OTHER-2022-1", false},
+		{"acme-2022-test.json", "", false},
+		{"acme-2022-1.json", "ACME-2022-test", false},
+	} {
+		if got := p.nameSelected(tc.filename, tc.id); got != tc.want {
+			t.Errorf("%s %s: expected %t, got %t", tc.filename, tc.id, tc.want, got)
+		}
+	}
+
+	// Without include patterns all but the excluded ones are selected.
+	p = filterProvider(t, &provider{Exclude: []string{`-test`}})
+	if !p.nameSelected("other-2022-1.json", "") {
+		t.Error("expected advisory without include patterns to be selected")
+	}
+	if p.nameSelected("other-test.json", "") {
+		t.Error("expected excluded advisory not to be selected")
+	}
+}
+
+func TestReleaseSelected(t *testing.T) {
+	after := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name     string
+		after    *time.Time
+		released time.Time
+		want     bool
+	}{
+		{"no restriction", nil, after.AddDate(-1, 0, 0), true},
+		{"before", &after, after.AddDate(0, 0, -1), false},
+		{"same time", &after, after, false},
+		{"after", &after, after.Add(time.Second), true},
+	} {
+		p := &provider{ReleasedAfter: tc.after}
+		if got := p.releaseSelected(tc.released); got != tc.want {
+			t.Errorf("%s: expected %t, got %t", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestPreFilter(t *testing.T) {
+	after := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	p := filterProvider(t, &provider{
+		TLPLabels:     []string{"white"},
+		ReleasedAfter: &after,
+		Include:       []string{`^ACME-`},
+		Exclude:       []string{`-test`},
+	})
+
+	var (
+		white csaf.TLPLabel = csaf.TLPLabelWhite
+		amber csaf.TLPLabel = csaf.TLPLabelAmber
+	)
+	entry := func(id string, published time.Time) *csaf.Entry {
+		return &csaf.Entry{ID: id, Published: csaf.TimeStamp(published)}
+	}
+	later, earlier := after.AddDate(0, 1, 0), after.AddDate(0, -1, 0)
+
+	for _, tc := range []struct {
+		name     string
+		filename string
+		label    *csaf.TLPLabel
+		entry    *csaf.Entry
+		want     bool
+	}{
+		{"selected", "acme-1.json", &white, entry("ACME-1", later), true},
+		{"no label", "acme-1.json", nil, entry("ACME-1", later), true},
+		{"other label", "acme-1.json", &amber, entry("ACME-1", later), false},
+		{"released before", "acme-1.json", &white, entry("ACME-1", earlier), false},
+		{"no release date", "acme-1.json", &white, entry("ACME-1", time.Time{}), true},
+		{"not included", "other-1.json", &white, entry("OTHER-1", later), false},
+		{"excluded", "acme-test.json", &white, entry("ACME-test", later), false},
+		// Without an entry only the filename is checked against the
+		// exclude patterns. The rest is checked after downloading.
+		{"no entry", "other-1.json", &white, nil, true},
+		{"no entry excluded", "other-test.json", &white, nil, false},
+	} {
+		if got := p.preFilter(tc.filename, tc.label, tc.entry); got != tc.want {
+			t.Errorf("%s: expected %t, got %t", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestPostFilter(t *testing.T) {
+	after := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	p := filterProvider(t, &provider{
+		TLPLabels:     []string{"white", "green"},
+		ReleasedAfter: &after,
+		Include:       []string{`^ACME-`},
+		FinalOnly:     true,
+	})
+
+	summary := func(id string, initial time.Time, status string) *csaf.AdvisorySummary {
+		return &csaf.AdvisorySummary{
+			ID:                 id,
+			InitialReleaseDate: initial,
+			Status:             status,
+		}
+	}
+	later, earlier := after.AddDate(0, 1, 0), after.AddDate(0, -1, 0)
+
+	for _, tc := range []struct {
+		name     string
+		filename string
+		label    string
+		sum      *csaf.AdvisorySummary
+		want     bool
+	}{
+		{"selected", "acme-1.json", "GREEN", summary("ACME-1", later, "final"), true},
+		{"other label", "acme-1.json", "RED", summary("ACME-1", later, "final"), false},
+		{"not included", "other-1.json", "WHITE", summary("OTHER-1", later, "final"), false},
+		{"released before", "acme-1.json", "WHITE", summary("ACME-1", earlier, "final"), false},
+		{"draft", "acme-1.json", "WHITE", summary("ACME-1", later, "draft"), false},
+		{"interim", "acme-1.json", "WHITE", summary("ACME-1", later, "interim"), false},
+	} {
+		if got := p.postFilter(tc.filename, tc.label, tc.sum); got != tc.want {
+			t.Errorf("%s: expected %t, got %t", tc.name, tc.want, got)
+		}
+	}
+
+	// Without final_only advisories of all states are selected.
+	p.FinalOnly = false
+	if !p.postFilter("acme-1.json", "WHITE", summary("ACME-1", later, "draft")) {
+		t.Error("expected draft to be selected without final_only")
+	}
+}
@@ -24,6 +24,8 @@ import (
 type fullJob struct {
 	provider           *provider
 	aggregatorProvider *csaf.AggregatorCSAFProvider
+	rejected           []string
+	report             *providerReport
 	err                error
 }
 
@@ -34,6 +36,9 @@ func (w *worker) setupProviderFull(provider *provider) error {
 
 	w.dir = ""
 	w.provider = provider
+	w.rejected = nil
+	w.summaries = nil
+	w.report = newProviderReport(provider, "full")
 
 	// Each job needs a separate client.
 	w.client = w.cfg.httpClient(provider)
@@ -71,14 +76,27 @@ func (w *worker) fullWork(
 	for j := range jobs {
 		if err := w.setupProviderFull(j.provider); err != nil {
 			j.err = err
-			continue
+		} else {
+			j.aggregatorProvider, j.err = doWork(w)
+		}
+		j.rejected = w.rejected
+		j.report = w.report
+		j.report.Rejected = len(w.rejected)
+		j.report.RejectedReasons = w.rejected
+		for label, ss := range w.summaries {
+			if j.report.Labels == nil {
+				j.report.Labels = make(map[string]int)
+			}
+			j.report.Labels[label] = len(ss)
+			j.report.Advisories += len(ss)
 		}
-		j.aggregatorProvider, j.err = doWork(w)
+		j.report.finish(j.err)
 	}
 }
 
-// full performs the complete lister/download
-func (p *processor) full() error {
+// full performs the complete lister/download of the given providers.
+// It returns the names of the providers which failed.
+func (p *processor) full(providers []*provider) (map[string]bool, error) {
 
 	var doWork fullWorkFunc
 
@@ -93,16 +111,22 @@ func (p *processor) full() error {
 	queue := make(chan *fullJob)
 	var wg sync.WaitGroup
 
-	log.Printf("Starting %d workers.\n", p.cfg.Workers)
-	for i := 1; i <= p.cfg.Workers; i++ {
+	// No need for more workers than providers.
+	workers := p.cfg.Workers
+	if workers > len(providers) {
+		workers = len(providers)
+	}
+
+	log.Printf("Starting %d workers.\n", workers)
+	for i := 1; i <= workers; i++ {
 		wg.Add(1)
 		w := newWorker(i, p.cfg)
 		go w.fullWork(&wg, doWork, queue)
 	}
 
-	jobs := make([]fullJob, len(p.cfg.Providers))
+	jobs := make([]fullJob, len(providers))
 
-	for i, p := range p.cfg.Providers {
+	for i, p := range providers {
 		jobs[i] = fullJob{provider: p}
 		queue <- &jobs[i]
 	}
@@ -110,26 +134,73 @@ func (p *processor) full() error {
 
 	wg.Wait()
 
-	// Assemble aggregator data structure.
+	if p.aggregated == nil {
+		p.aggregated = make(map[string]*csaf.AggregatorCSAFProvider)
+	}
 
-	csafProviders := make([]*csaf.AggregatorCSAFProvider, 0, len(jobs))
+	failed := make(map[string]bool)
 
 	for i := range jobs {
 		j := &jobs[i]
+		p.report.add(j.report)
+		p.recordMetrics(j.report)
+		if len(j.rejected) > 0 {
+			log.Printf("warning: '%s': %d advisories rejected\n",
+				j.provider.Name, len(j.rejected))
+		}
 		if j.err != nil {
 			log.Printf("error: '%s' failed: %v\n", j.provider.Name, j.err)
+			failed[j.provider.Name] = true
 			continue
 		}
 		if j.aggregatorProvider == nil {
 			log.Printf(
 				"error: '%s' does not produce any result.\n", j.provider.Name)
+			failed[j.provider.Name] = true
+			continue
+		}
+		p.aggregated[j.provider.Name] = j.aggregatorProvider
+	}
+
+	// Assemble aggregator data structure.
+	// Providers not processed in this run keep their last results.
+
+	var (
+		csafProviders  []*csaf.AggregatorCSAFProvider
+		csafPublishers []*csaf.AggregatorCSAFPublisher
+	)
+
+	for _, prv := range p.cfg.Providers {
+		ap := p.aggregated[prv.Name]
+		switch {
+		case ap == nil:
 			continue
+		case prv.Publisher:
+			// The metadata is taken from the provider-metadata.json
+			// of the publisher but it is listed in the publisher role.
+			metadata := *ap.Metadata
+			role := csaf.MetadataRolePublisher
+			metadata.Role = &role
+			csafPublishers = append(csafPublishers, &csaf.AggregatorCSAFPublisher{
+				Metadata:       &metadata,
+				Mirrors:        ap.Mirrors,
+				UpdateInterval: prv.UpdateInterval,
+			})
+		default:
+			csafProviders = append(csafProviders, ap)
 		}
-		csafProviders = append(csafProviders, j.aggregatorProvider)
 	}
 
 	if len(csafProviders) == 0 {
-		return errors.New("all jobs failed, stopping")
+		if len(csafPublishers) == 0 {
+			return failed, errors.New("all jobs failed, stopping")
+		}
+		return failed, errors.New("all jobs of providers failed, stopping")
+	}
+
+	// Nothing is written in a dry run.
+	if p.cfg.dryRun {
+		return failed, nil
 	}
 
 	version := csaf.AggregatorVersion20
@@ -139,11 +210,16 @@ func (p *processor) full() error {
 	lastUpdated := csaf.TimeStamp(time.Now().UTC())
 
 	agg := csaf.Aggregator{
-		Aggregator:    &p.cfg.Aggregator,
-		Version:       &version,
-		CanonicalURL:  &canonicalURL,
-		CSAFProviders: csafProviders,
-		LastUpdated:   &lastUpdated,
+		Aggregator:     &p.cfg.Aggregator,
+		Version:        &version,
+		CanonicalURL:   &canonicalURL,
+		CSAFProviders:  csafProviders,
+		CSAFPublishers: csafPublishers,
+		LastUpdated:    &lastUpdated,
+	}
+
+	if err := agg.Validate(); err != nil {
+		return failed, err
 	}
 
 	web := filepath.Join(p.cfg.Web, ".well-known", "csaf-aggregator")
@@ -152,18 +228,18 @@ func (p *processor) full() error {
 
 	fname, file, err := util.MakeUniqFile(dstName + ".tmp")
 	if err != nil {
-		return err
+		return failed, err
 	}
 
 	if _, err := agg.WriteTo(file); err != nil {
 		file.Close()
 		os.RemoveAll(fname)
-		return err
+		return failed, err
 	}
 
 	if err := file.Close(); err != nil {
-		return err
+		return failed, err
 	}
 
-	return os.Rename(fname, dstName)
+	return failed, os.Rename(fname, dstName)
 }
@@ -0,0 +1,223 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/csaf-poc/csaf_distribution/csaf"
+	"github.com/csaf-poc/csaf_distribution/util"
+)
+
+// previousFile is an advisory mirrored in the last run.
+type previousFile struct {
+	label   string    // label folder of the advisory.
+	path    string    // path relative to the label folder.
+	updated time.Time // time of the last update.
+}
+
+// previousMirror returns the folder of the last mirror run
+// of the current provider. Empty if there is none.
+func (w *worker) previousMirror() string {
+	webTarget := filepath.Join(
+		w.cfg.Web, ".well-known", "csaf-aggregator", w.provider.Name)
+	dir, err := filepath.EvalSymlinks(webTarget)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("error: %v\n", err)
+		}
+		return ""
+	}
+	return dir
+}
+
+// previousFiles returns the advisories of the given label
+// mirrored in the last run indexed by their paths
+// relative to the label folder.
+func (w *worker) previousFiles(label string) map[string]previousFile {
+	if files, ok := w.previous[label]; ok {
+		return files
+	}
+	files, err := readChanges(label, filepath.Join(w.previousDir, label, "changes.csv"))
+	if err != nil {
+		log.Printf("error: %v\n", err)
+	}
+	w.previous[label] = files
+	return files
+}
+
+// previousByName returns the advisories of the given labels
+// mirrored in the last run under the given filename.
+// The same filename may be found in several years
+// or in the archive of a label.
+func (w *worker) previousByName(labels []string, filename string) []previousFile {
+	if w.previousNames == nil {
+		w.previousNames = make(map[string][]previousFile)
+		for _, label := range mirrorLabels {
+			files := w.previousFiles(label)
+			paths := make([]string, 0, len(files))
+			for p := range files {
+				paths = append(paths, p)
+			}
+			sort.Strings(paths)
+			for _, p := range paths {
+				name := path.Base(p)
+				w.previousNames[name] = append(w.previousNames[name], files[p])
+			}
+		}
+	}
+	var found []previousFile
+	for _, label := range labels {
+		for _, pf := range w.previousNames[filename] {
+			if pf.label == label {
+				found = append(found, pf)
+			}
+		}
+	}
+	return found
+}
+
+// readChanges reads the advisories of a label from a changes.csv file.
+func readChanges(label, fname string) (map[string]previousFile, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	files := make(map[string]previousFile)
+	if err := parseChanges(f, func(t time.Time, p string) {
+		files[p] = previousFile{label: label, path: p, updated: t}
+	}); err != nil {
+		return nil, fmt.Errorf("%s: %v", fname, err)
+	}
+	return files, nil
+}
+
+// parseChanges parses the records of a changes.csv
+// and calls fn for each of them.
+func parseChanges(r io.Reader, fn func(time.Time, string)) error {
+	c := csv.NewReader(r)
+	c.FieldsPerRecord = 2
+	for {
+		record, err := c.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		t, err := time.Parse(time.RFC3339, record[0])
+		if err != nil {
+			return err
+		}
+		fn(t, record[1])
+	}
+}
+
+// unchanged checks if an advisory mirrored in the last run
+// under one of the given labels is still up-to-date.
+// This is the case if the update time given by the provider
+// or its remote SHA256 hash matches the local one.
+// It returns the local path and the summary of the advisory or
+// an empty path if the advisory has to be downloaded.
+func (w *worker) unchanged(
+	labels []string, file, filename string,
+) (string, *csaf.AdvisorySummary) {
+
+	if w.previousDir == "" {
+		return "", nil
+	}
+
+	var (
+		remoteHash    []byte
+		remoteFetched bool
+	)
+	// fetchRemoteHash loads the remote SHA256 hash at most once.
+	fetchRemoteHash := func() []byte {
+		if remoteFetched {
+			return remoteHash
+		}
+		remoteFetched = true
+		res, err := w.client.Get(file + ".sha256")
+		if err != nil {
+			return nil
+		}
+		remoteHash, _ = func() ([]byte, error) {
+			defer res.Body.Close()
+			if res.StatusCode != http.StatusOK {
+				return nil, errNotFound
+			}
+			return util.HashFromReader(res.Body)
+		}()
+		return remoteHash
+	}
+
+	for _, pf := range w.previousByName(labels, filename) {
+		local := filepath.Join(w.previousDir, pf.label, filepath.FromSlash(pf.path))
+
+		if updated, ok := w.updates[file]; !ok || !updated.Equal(pf.updated) {
+			// Compare the hashes.
+			localHash, err := util.HashFromFile(local + ".sha256")
+			if err != nil {
+				continue
+			}
+			remoteHash := fetchRemoteHash()
+			if remoteHash == nil || !bytes.Equal(localHash, remoteHash) {
+				continue
+			}
+		}
+
+		sum, err := w.loadSummary(local)
+		if err != nil {
+			continue
+		}
+		return local, sum
+	}
+	return "", nil
+}
+
+// newOrChanged reports a downloaded advisory as new or,
+// if it was mirrored in the last run, as changed.
+func (w *worker) newOrChanged(label, filename string, sum *csaf.AdvisorySummary) {
+	path := label + "/" + strconv.Itoa(sum.InitialReleaseDate.Year()) + "/" + filename
+	if w.previousDir != "" && len(w.previousByName(mirrorLabels, filename)) > 0 {
+		w.report.Changed = append(w.report.Changed, path)
+		return
+	}
+	w.report.New = append(w.report.New, path)
+}
+
+// linkFiles hard links an advisory and its hashes and signature
+// from the last run into the new mirror.
+func linkFiles(dst, src string) error {
+	for _, ext := range []string{"", ".sha256", ".sha512", ".asc"} {
+		if err := os.Link(src+ext, dst+ext); err != nil {
+			// The signature may be missing.
+			if ext == ".asc" && os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/csaf-poc/csaf_distribution/util"
+)
+
+// writeMirrored writes an advisory with the given id to the path
+// of the white label folder of dir. Without hash the .sha256 file
+// is left out.
+func writeMirrored(t *testing.T, dir, p, id string, hash bool) {
+	t.Helper()
+	fname := filepath.Join(dir, "white", filepath.FromSlash(p))
+	if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+		t.Fatal(err)
+	}
+	data := []byte(retentionAdvisory(id))
+	if err := os.WriteFile(fname, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if hash {
+		sum := sha256.Sum256(data)
+		if err := util.WriteHashSumToFile(fname+".sha256", filepath.Base(p), sum[:]); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPreviousMirror(t *testing.T) {
+	web := t.TempDir()
+	w := &worker{
+		cfg:      &config{Web: web},
+		provider: &provider{Name: "test"},
+	}
+	if dir := w.previousMirror(); dir != "" {
+		t.Errorf("expected no previous mirror, got %q", dir)
+	}
+
+	target := t.TempDir()
+	link := filepath.Join(web, ".well-known", "csaf-aggregator", "test")
+	if err := os.MkdirAll(filepath.Dir(link), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+	want, err := filepath.EvalSymlinks(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir := w.previousMirror(); dir != want {
+		t.Errorf("expected previous mirror %q, got %q", want, dir)
+	}
+}
+
+func TestPreviousFiles(t *testing.T) {
+	previous := t.TempDir()
+	writeMirrored(t, previous, "2021/dup.json", "dup-2021", true)
+	writeMirrored(t, previous, "2022/dup.json", "dup-2022", true)
+	changes := "2022-01-01T00:00:00Z,2021/dup.json\n" +
+		"2022-02-01T00:00:00Z,2022/dup.json\n"
+	if err := os.WriteFile(
+		filepath.Join(previous, "white", "changes.csv"), []byte(changes), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := &worker{
+		previousDir: previous,
+		previous:    map[string]map[string]previousFile{},
+	}
+
+	files := w.previousFiles("white")
+	var paths []string
+	for p, pf := range files {
+		if pf.label != "white" || pf.path != p {
+			t.Errorf("%s: unexpected entry %+v", p, pf)
+		}
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	if want := []string{"2021/dup.json", "2022/dup.json"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("expected paths %v, got %v", want, paths)
+	}
+	if len(w.previousFiles("green")) != 0 {
+		t.Error("expected no advisories for missing label")
+	}
+
+	found := w.previousByName([]string{"white"}, "dup.json")
+	if len(found) != 2 || found[0].path != "2021/dup.json" || found[1].path != "2022/dup.json" {
+		t.Errorf("unexpected advisories by name: %+v", found)
+	}
+	if found := w.previousByName([]string{"green"}, "dup.json"); len(found) != 0 {
+		t.Errorf("unexpected advisories of other label: %+v", found)
+	}
+}
+
+func TestUnchanged(t *testing.T) {
+	previous := t.TempDir()
+	writeMirrored(t, previous, "2021/dup.json", "dup-2021", true)
+	writeMirrored(t, previous, "2022/dup.json", "dup-2022", true)
+	writeMirrored(t, previous, "2022/nohash.json", "nohash", false)
+	changes := "2022-01-01T00:00:00Z,2021/dup.json\n" +
+		"2022-02-01T00:00:00Z,2022/dup.json\n" +
+		"2022-01-01T00:00:00Z,2022/nohash.json\n"
+	if err := os.WriteFile(
+		filepath.Join(previous, "white", "changes.csv"), []byte(changes), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	hashOf := func(id string) string {
+		sum := sha256.Sum256([]byte(retentionAdvisory(id)))
+		return fmt.Sprintf("%x  x.json\n", sum)
+	}
+
+	var (
+		remote   string // remote .sha256, empty for 404
+		requests int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		requests++
+		if remote == "" {
+			http.NotFound(rw, r)
+			return
+		}
+		fmt.Fprint(rw, remote)
+	}))
+	defer srv.Close()
+
+	updated := time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		name     string
+		labels   []string
+		filename string
+		updated  *time.Time
+		remote   string
+		want     string // path of the local advisory, empty if changed
+		id       string
+		requests int
+	}{
+		{"updated", []string{"white"}, "dup.json", &updated, "", "2022/dup.json", "dup-2022", 1},
+		{"hash match", []string{"white"}, "dup.json", nil, hashOf("dup-2022"), "2022/dup.json", "dup-2022", 1},
+		{"hash match other year", []string{"white"}, "dup.json", nil, hashOf("dup-2021"), "2021/dup.json", "dup-2021", 1},
+		{"hash mismatch", []string{"white"}, "dup.json", nil, hashOf("other"), "", "", 1},
+		{"no remote hash", []string{"white"}, "dup.json", nil, "", "", "", 1},
+		{"no local hash", []string{"white"}, "nohash.json", nil, hashOf("nohash"), "", "", 0},
+		{"other label", []string{"green"}, "dup.json", &updated, "", "", "", 0},
+		{"not mirrored", []string{"white"}, "new.json", nil, hashOf("new"), "", "", 0},
+	} {
+		file := srv.URL + "/" + tc.filename
+		w := &worker{
+			expr:        util.NewPathEval(),
+			client:      srv.Client(),
+			previousDir: previous,
+			previous:    map[string]map[string]previousFile{},
+			updates:     map[string]time.Time{},
+		}
+		if tc.updated != nil {
+			w.updates[file] = *tc.updated
+		}
+		remote, requests = tc.remote, 0
+
+		local, sum := w.unchanged(tc.labels, file, tc.filename)
+		if requests != tc.requests {
+			t.Errorf("%s: expected %d requests, got %d", tc.name, tc.requests, requests)
+		}
+		if tc.want == "" {
+			if local != "" || sum != nil {
+				t.Errorf("%s: expected changed advisory, got %q", tc.name, local)
+			}
+			continue
+		}
+		want := filepath.Join(previous, "white", filepath.FromSlash(tc.want))
+		if local != want {
+			t.Errorf("%s: expected %q, got %q", tc.name, want, local)
+			continue
+		}
+		if sum == nil || sum.ID != tc.id {
+			t.Errorf("%s: expected summary of %s, got %+v", tc.name, tc.id, sum)
+		}
+	}
+}
+
+func TestLinkFiles(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "a.json")
+	for _, ext := range []string{"", ".sha256", ".sha512"} {
+		if err := os.WriteFile(src+ext, []byte(ext), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Without a signature.
+	dst := filepath.Join(t.TempDir(), "a.json")
+	if err := linkFiles(dst, src); err != nil {
+		t.Fatal(err)
+	}
+	for _, ext := range []string{"", ".sha256", ".sha512"} {
+		s, err := os.Stat(src + ext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := os.Stat(dst + ext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !os.SameFile(s, d) {
+			t.Errorf("%s is not linked", dst+ext)
+		}
+	}
+	if _, err := os.Stat(dst + ".asc"); !os.IsNotExist(err) {
+		t.Errorf("expected no signature, got %v", err)
+	}
+
+	// With a signature.
+	if err := os.WriteFile(src+".asc", []byte("sig"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dst = filepath.Join(t.TempDir(), "a.json")
+	if err := linkFiles(dst, src); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(dst + ".asc"); err != nil || string(data) != "sig" {
+		t.Errorf("expected linked signature, got %q %v", data, err)
+	}
+
+	// A missing hash fails.
+	if err := os.Remove(src + ".sha512"); err != nil {
+		t.Fatal(err)
+	}
+	dst = filepath.Join(t.TempDir(), "a.json")
+	if err := linkFiles(dst, src); err == nil || !strings.Contains(err.Error(), ".sha512") {
+		t.Errorf("expected failure for missing hash, got %v", err)
+	}
+}
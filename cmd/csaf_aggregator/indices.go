@@ -13,6 +13,8 @@ import (
 	"encoding/csv"
 	"fmt"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
@@ -29,7 +31,7 @@ func (w *worker) writeInterims(label string, summaries []summary) error {
 	// Filter out the interims.
 	var ss []summary
 	for _, s := range summaries {
-		if s.summary.Status == "interim" {
+		if s.summary.Status == "interim" && !s.retained {
 			ss = append(ss, s)
 		}
 	}
@@ -44,7 +46,7 @@ func (w *worker) writeInterims(label string, summaries []summary) error {
 			ss[j].summary.CurrentReleaseDate)
 	})
 
-	fname := filepath.Join(w.dir, label, "interim.csv")
+	fname := filepath.Join(w.dir, label, "interims.csv")
 	f, err := os.Create(fname)
 	if err != nil {
 		return err
@@ -81,8 +83,7 @@ func (w *worker) writeCSV(label string, summaries []summary) error {
 	copy(ss, summaries)
 
 	sort.SliceStable(ss, func(i, j int) bool {
-		return ss[i].summary.CurrentReleaseDate.After(
-			ss[j].summary.CurrentReleaseDate)
+		return ss[i].changed().After(ss[j].changed())
 	})
 
 	fname := filepath.Join(w.dir, label, "changes.csv")
@@ -96,10 +97,8 @@ func (w *worker) writeCSV(label string, summaries []summary) error {
 
 	for i := range ss {
 		s := &ss[i]
-		record[0] =
-			s.summary.CurrentReleaseDate.Format(time.RFC3339)
-		record[1] =
-			strconv.Itoa(s.summary.InitialReleaseDate.Year()) + "/" + s.filename
+		record[0] = s.changed().Format(time.RFC3339)
+		record[1] = s.path()
 		if err := out.Write(record); err != nil {
 			f.Close()
 			return err
@@ -124,6 +123,10 @@ func (w *worker) writeIndex(label string, summaries []summary) error {
 	out := bufio.NewWriter(f)
 	for i := range summaries {
 		s := &summaries[i]
+		// Archived advisories are only listed as tombstones.
+		if !s.archived.IsZero() {
+			continue
+		}
 		fmt.Fprintf(
 			out, "%d/%s\n",
 			s.summary.InitialReleaseDate.Year(),
@@ -157,9 +160,7 @@ func (w *worker) writeROLIE(label string, summaries []summary) error {
 		s := &summaries[i]
 
 		csafURL := w.cfg.Domain + "/.well-known/csaf-aggregator/" +
-			w.provider.Name + "/" + label + "/" +
-			strconv.Itoa(s.summary.InitialReleaseDate.Year()) + "/" +
-			s.filename
+			w.provider.Name + "/" + label + "/" + s.path()
 
 		entries[i] = &csaf.Entry{
 			ID:        s.summary.ID,
@@ -181,6 +182,9 @@ func (w *worker) writeROLIE(label string, summaries []summary) error {
 				Content: s.summary.Summary,
 			}
 		}
+		if !s.archived.IsZero() {
+			tombstone(entries[i], csafURL, s.archived)
+		}
 	}
 
 	rolie := &csaf.ROLIEFeed{
@@ -232,25 +236,73 @@ func (w *worker) writeIndices() error {
 	return nil
 }
 
-// loadIndex loads baseURL/index.txt and returns a list of files
-// prefixed by baseURL/.
-func (w *worker) loadIndex(baseURL string) ([]string, error) {
-	indexURL := baseURL + "/index.txt"
+// loadIndex loads the index.txt found at base and returns
+// the URLs of the listed advisories.
+func (w *worker) loadIndex(base *url.URL) ([]string, error) {
+	indexURL := base.ResolveReference(&url.URL{Path: "index.txt"}).String()
 	resp, err := w.client.Get(indexURL)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s failed. Status code %d (%s)",
+			indexURL, resp.StatusCode, resp.Status)
+	}
 	var lines []string
 
 	scanner := bufio.NewScanner(resp.Body)
 
 	for scanner.Scan() {
-		lines = append(lines, baseURL+"/"+scanner.Text())
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
 	}
 
 	if err := scanner.Err(); err != nil {
 		return nil, err
 	}
-	return lines, nil
+	return resolveURLs(lines, base), nil
+}
+
+// loadChanges loads the changes.csv found at base and returns
+// the URLs of the listed advisories in the order of the file.
+// The update times are remembered to detect unchanged advisories.
+// Returns errNotFound if there is no changes.csv.
+func (w *worker) loadChanges(base *url.URL) ([]string, error) {
+	changesURL := base.ResolveReference(&url.URL{Path: "changes.csv"}).String()
+	res, err := w.client.Get(changesURL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		// changes.csv is optional.
+		return nil, errNotFound
+	}
+	var files []string
+	if err := parseChanges(res.Body, func(t time.Time, path string) {
+		p, err := url.Parse(path)
+		if err != nil {
+			log.Printf("error: Invalid URL '%s': %v\n", path, err)
+			return
+		}
+		file := base.ResolveReference(p).String()
+		files = append(files, file)
+		w.updates[file] = t
+	}); err != nil {
+		return nil, fmt.Errorf("%s: %v", changesURL, err)
+	}
+	return files, nil
+}
+
+// loadFiles returns the URLs of the advisories found at base.
+// They are taken from the changes.csv if there is one
+// and from the index.txt otherwise.
+func (w *worker) loadFiles(base *url.URL) ([]string, error) {
+	files, err := w.loadChanges(base)
+	if err == errNotFound {
+		return w.loadIndex(base)
+	}
+	return files, err
 }
@@ -21,6 +21,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -30,22 +31,57 @@ import (
 )
 
 type interimJob struct {
-	provider *provider
-	err      error
+	provider  *provider
+	updated   []string
+	finalized []string
+	report    *providerReport
+	err       error
 }
 
+// interimChange is an interim advisory which has changed upstream.
+type interimChange struct {
+	path      string                // path relative to the label folder.
+	summary   *csaf.AdvisorySummary // summary of the new version.
+	retention string                // retention policy if removed upstream.
+	removedAt time.Time             // time of the removal.
+}
+
+// finalized returns true if the advisory is not interim any longer.
+func (ic *interimChange) finalized() bool {
+	return ic.summary != nil && ic.summary.Status != "interim"
+}
+
+// removed returns true if the advisory was removed upstream.
+func (ic *interimChange) removed() bool {
+	return ic.retention != ""
+}
+
+// unlink removes a file in the transaction folder before it is
+// rewritten. The files are hard linked with the ones of the
+// current mirror which must not be modified.
+func unlink(fname string) error {
+	if err := os.Remove(fname); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// checkInterims compares the interim advisories of a label with
+// their remote versions. Changed advisories are written into the
+// transaction folder. It returns the changed advisories.
 func (w *worker) checkInterims(
 	tx *lazyTransaction,
 	label string,
 	interims [][2]string,
-) ([]string, error) {
+) ([]interimChange, error) {
 
 	var data bytes.Buffer
 
 	labelPath := filepath.Join(tx.Src(), label)
 
-	// advisories which are not interim any longer.
-	var finalized []string
+	var changes []interimChange
+
+	w.report.Discovered += len(interims)
 
 	for _, interim := range interims {
 
@@ -55,32 +91,48 @@ func (w *worker) checkInterims(
 		// Load local SHA256 of the advisory
 		localHash, err := util.HashFromFile(local + ".sha256")
 		if err != nil {
-			return nil, nil
+			return nil, err
 		}
 
 		res, err := w.client.Get(url)
 		if err != nil {
 			return nil, err
 		}
+		if res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone {
+			res.Body.Close()
+			change, err := w.removeInterim(tx, label, interim[0])
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, change)
+			continue
+		}
 		if res.StatusCode != http.StatusOK {
+			res.Body.Close()
 			return nil, fmt.Errorf("fetching %s failed: Status code %d (%s)",
 				url, res.StatusCode, res.Status)
 		}
 
-		s256 := sha256.New()
 		data.Reset()
-		hasher := io.MultiWriter(s256, &data)
 
+		// Read it completely as the hashes and the
+		// signature are made over all of its bytes.
 		var doc interface{}
 		if err := func() error {
 			defer res.Body.Close()
-			tee := io.TeeReader(res.Body, hasher)
-			return json.NewDecoder(tee).Decode(&doc)
+			if _, err := io.Copy(&data, res.Body); err != nil {
+				return err
+			}
+			return json.Unmarshal(data.Bytes(), &doc)
 		}(); err != nil {
 			return nil, err
 		}
 
-		remoteHash := s256.Sum(nil)
+		w.report.Downloaded++
+		w.report.DownloadedBytes += int64(data.Len())
+
+		s256, s512 := sha256.Sum256(data.Bytes()), sha512.Sum512(data.Bytes())
+		remoteHash := s256[:]
 
 		// If the hashes are equal then we can ignore this advisory.
 		if bytes.Equal(localHash, remoteHash) {
@@ -92,9 +144,42 @@ func (w *worker) checkInterims(
 			return nil, fmt.Errorf("failed to validate %s: %v", url, err)
 		}
 
-		// XXX: Should we return an error here?
-		for _, e := range errors {
-			log.Printf("validation error: %s: %v\n", url, e)
+		// Keep the old version if the new one is not valid.
+		if len(errors) > 0 {
+			w.invalid(url, fmt.Errorf("validation errors: %s",
+				strings.Join(errors, "; ")))
+			continue
+		}
+
+		if tests := w.cfg.tests(); tests.Enabled() {
+			results, err := tests.Run(doc)
+			if err != nil {
+				return nil, fmt.Errorf("failed to test %s: %v", url, err)
+			}
+			if errs := w.findings(url, results); len(errs) > 0 {
+				w.invalid(url, fmt.Errorf("fails tests: %s",
+					strings.Join(errs.Strings(), "; ")))
+				continue
+			}
+		}
+
+		sum, err := csaf.NewAdvisorySummary(w.expr, doc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", url, err)
+		}
+
+		bytes := data.Bytes()
+
+		// Verify against the hashes and the signature of the provider.
+		if err := w.interimKeys(); err != nil {
+			return nil, err
+		}
+		sig, err := w.verify(url, bytes, remoteHash, s512[:])
+		if err != nil {
+			// Keep the old version.
+			w.reject(url, err)
+			w.quarantine(filepath.Base(interim[0]), bytes, err)
+			continue
 		}
 
 		// We need to write the changed content.
@@ -108,7 +193,11 @@ func (w *worker) checkInterims(
 		// Overwrite in the cloned folder.
 		nlocal := filepath.Join(dst, label, interim[0])
 
-		bytes := data.Bytes()
+		for _, ext := range []string{"", ".sha256", ".sha512", ".asc"} {
+			if err := unlink(nlocal + ext); err != nil {
+				return nil, err
+			}
+		}
 
 		if err := os.WriteFile(nlocal, bytes, 0644); err != nil {
 			return nil, err
@@ -116,8 +205,8 @@ func (w *worker) checkInterims(
 
 		name := filepath.Base(nlocal)
 
-		if err := util.WriteHashToFile(
-			nlocal+".sha512", name, sha512.New(), bytes,
+		if err := util.WriteHashSumToFile(
+			nlocal+".sha512", name, s512[:],
 		); err != nil {
 			return nil, err
 		}
@@ -127,17 +216,67 @@ func (w *worker) checkInterims(
 			return nil, err
 		}
 
-		// Download the signature
-		sigURL := url + ".asc"
-		ascFile := nlocal + ".asc"
-
-		// Download the signature or sign it our self.
-		if err := w.downloadSignatureOrSign(sigURL, ascFile, bytes); err != nil {
+		// Write the signature of the provider or sign it our self.
+		if err := w.writeSignatureOrSign(nlocal+".asc", sig, bytes); err != nil {
 			return nil, err
 		}
+
+		changes = append(changes, interimChange{
+			path:    interim[0],
+			summary: sum,
+		})
+	}
+
+	return changes, nil
+}
+
+// removeInterim applies the retention policy of the provider
+// to an interim advisory which was removed upstream.
+func (w *worker) removeInterim(
+	tx *lazyTransaction,
+	label, path string,
+) (interimChange, error) {
+
+	retention := w.provider.retention(w.cfg)
+	w.removed(label+"/"+path, retention)
+
+	change := interimChange{
+		path:      path,
+		retention: retention,
+		removedAt: time.Now().UTC(),
+	}
+
+	// Even if kept it is removed from the interims.csv.
+	dst, err := tx.Dst()
+	if err != nil {
+		return change, err
 	}
 
-	return finalized, nil
+	local := filepath.Join(dst, label, filepath.FromSlash(path))
+
+	switch retention {
+	case retentionDelete:
+		for _, ext := range []string{"", ".sha256", ".sha512", ".asc"} {
+			if err := unlink(local + ext); err != nil {
+				return change, err
+			}
+		}
+	case retentionArchive:
+		archive := filepath.Join(dst, label, archiveFolder, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(archive), 0755); err != nil {
+			return change, err
+		}
+		for _, ext := range []string{"", ".sha256", ".sha512", ".asc"} {
+			if err := os.Rename(local+ext, archive+ext); err != nil {
+				// The signature may be missing.
+				if ext == ".asc" && os.IsNotExist(err) {
+					continue
+				}
+				return change, err
+			}
+		}
+	}
+	return change, nil
 }
 
 // setupProviderInterim prepares the worker for a specific provider.
@@ -147,11 +286,31 @@ func (w *worker) setupProviderInterim(provider *provider) {
 
 	w.dir = ""
 	w.provider = provider
+	w.rejected = nil
+	w.keysLoaded = false
+	w.report = newProviderReport(provider, "interim")
 
 	// Each job needs a separate client.
 	w.client = w.cfg.httpClient(provider)
 }
 
+// interimKeys locates the provider metadata and downloads the
+// public OpenPGP keys of the provider to verify the interim
+// advisories. This is done once per provider if needed.
+func (w *worker) interimKeys() error {
+	if w.keysLoaded {
+		return nil
+	}
+	if err := w.locateProviderMetadata(w.provider.Domain); err != nil {
+		return err
+	}
+	if err := w.downloadPGPKeys(); err != nil {
+		return err
+	}
+	w.keysLoaded = true
+	return nil
+}
+
 func (w *worker) interimWork(wg *sync.WaitGroup, jobs <-chan *interimJob) {
 	defer wg.Done()
 	path := filepath.Join(w.cfg.Web, ".well-known", "csaf-aggregator")
@@ -166,14 +325,7 @@ func (w *worker) interimWork(wg *sync.WaitGroup, jobs <-chan *interimJob) {
 			defer tx.rollback()
 
 			// Try all the labels
-			for _, label := range []string{
-				csaf.TLPLabelUnlabeled,
-				csaf.TLPLabelWhite,
-				csaf.TLPLabelGreen,
-				csaf.TLPLabelAmber,
-				csaf.TLPLabelRed,
-			} {
-				label = strings.ToLower(label)
+			for _, label := range mirrorLabels {
 				labelPath := filepath.Join(providerPath, label)
 
 				interimsCSV := filepath.Join(labelPath, "interims.csv")
@@ -189,25 +341,47 @@ func (w *worker) interimWork(wg *sync.WaitGroup, jobs <-chan *interimJob) {
 				}
 
 				// Compare locals against remotes.
-				finalized, err := w.checkInterims(tx, label, interims)
+				changes, err := w.checkInterims(tx, label, interims)
 				if err != nil {
 					return err
 				}
 
-				if len(finalized) > 0 {
-					// We want to write in the transaction folder.
-					dst, err := tx.Dst()
-					if err != nil {
-						return err
-					}
-					interimsCSV := filepath.Join(dst, label, "interims.csv")
-					if err := writeInterims(interimsCSV, finalized); err != nil {
-						return err
+				if len(changes) == 0 {
+					continue
+				}
+
+				// We want to write in the transaction folder.
+				dst, err := tx.Dst()
+				if err != nil {
+					return err
+				}
+				if err := updateIndices(
+					filepath.Join(dst, label), label, changes,
+				); err != nil {
+					return err
+				}
+
+				for i := range changes {
+					path := label + "/" + changes[i].path
+					switch {
+					case changes[i].removed():
+						// Already reported.
+					case changes[i].finalized():
+						j.finalized = append(j.finalized, path)
+					default:
+						j.updated = append(j.updated, path)
 					}
 				}
 			}
 			return tx.commit()
 		}()
+
+		j.report = w.report
+		j.report.Rejected = len(w.rejected)
+		j.report.RejectedReasons = w.rejected
+		j.report.Updated = len(j.updated)
+		j.report.Finalized = len(j.finalized)
+		j.report.finish(j.err)
 	}
 }
 
@@ -227,26 +401,33 @@ func joinErrors(errs []error) error {
 	return errors.New(b.String())
 }
 
-// interim performs the short interim check/update.
-func (p *processor) interim() error {
+// interim performs the short interim check/update of the given providers.
+// It returns the names of the providers which failed.
+func (p *processor) interim(providers []*provider) (map[string]bool, error) {
 
 	if !p.cfg.runAsMirror() {
-		return errors.New("iterim in lister mode does not work")
+		return nil, errors.New("iterim in lister mode does not work")
 	}
 
 	queue := make(chan *interimJob)
 	var wg sync.WaitGroup
 
-	log.Printf("Starting %d workers.\n", p.cfg.Workers)
-	for i := 1; i <= p.cfg.Workers; i++ {
+	// No need for more workers than providers.
+	workers := p.cfg.Workers
+	if workers > len(providers) {
+		workers = len(providers)
+	}
+
+	log.Printf("Starting %d workers.\n", workers)
+	for i := 1; i <= workers; i++ {
 		wg.Add(1)
 		w := newWorker(i, p.cfg)
 		go w.interimWork(&wg, queue)
 	}
 
-	jobs := make([]interimJob, len(p.cfg.Providers))
+	jobs := make([]interimJob, len(providers))
 
-	for i, p := range p.cfg.Providers {
+	for i, p := range providers {
 		jobs[i] = interimJob{provider: p}
 		queue <- &jobs[i]
 	}
@@ -255,78 +436,287 @@ func (p *processor) interim() error {
 	wg.Wait()
 
 	var errs []error
+	failed := make(map[string]bool)
 
 	for i := range jobs {
-		if err := jobs[i].err; err != nil {
-			errs = append(errs, err)
+		j := &jobs[i]
+		p.report.add(j.report)
+		p.recordMetrics(j.report)
+		if j.err != nil {
+			// Changes are not committed on errors.
+			errs = append(errs, j.err)
+			failed[j.provider.Name] = true
+			continue
+		}
+		for _, path := range j.updated {
+			log.Printf("'%s': updated interim advisory %s\n",
+				j.provider.Name, path)
+		}
+		for _, path := range j.finalized {
+			log.Printf("'%s': finalized advisory %s\n",
+				j.provider.Name, path)
 		}
+		log.Printf("'%s': %d interim advisories updated, %d finalized\n",
+			j.provider.Name, len(j.updated), len(j.finalized))
 	}
 
-	return joinErrors(errs)
+	return failed, joinErrors(errs)
 }
 
-func writeInterims(interimsCSV string, finalized []string) error {
+// updateIndices updates the changes.csv, the interims.csv, the index.txt
+// and the ROLIE feed in the given label folder with the changed advisories.
+func updateIndices(labelPath, label string, changes []interimChange) error {
 
-	// In case this is a longer list (unlikely).
-	removed := make(map[string]bool, len(finalized))
-	for _, f := range finalized {
-		removed[f] = true
+	byPath := make(map[string]*interimChange, len(changes))
+	for i := range changes {
+		byPath[changes[i].path] = &changes[i]
+	}
+
+	if err := updateChanges(
+		filepath.Join(labelPath, "changes.csv"), byPath,
+	); err != nil {
+		return err
 	}
+	if err := updateInterims(
+		filepath.Join(labelPath, "interims.csv"), byPath,
+	); err != nil {
+		return err
+	}
+	if err := updateIndex(
+		filepath.Join(labelPath, "index.txt"), byPath,
+	); err != nil {
+		return err
+	}
+	return updateROLIE(
+		filepath.Join(labelPath, "csaf-feed-tlp-"+label+".json"), changes)
+}
 
-	lines, err := func() ([][]string, error) {
-		interimsF, err := os.Open(interimsCSV)
+// timedRecord is a CSV record with a parsed time in the first column.
+type timedRecord struct {
+	time   time.Time
+	record []string
+}
+
+// readTimedRecords reads CSV records with a time in the first column.
+func readTimedRecords(fname string, fields int) ([]timedRecord, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	c := csv.NewReader(f)
+	c.FieldsPerRecord = fields
+
+	var records []timedRecord
+	for {
+		record, err := c.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		t, err := time.Parse(time.RFC3339, record[0])
 		if err != nil {
 			return nil, err
 		}
-		defer interimsF.Close()
-		c := csv.NewReader(interimsF)
-		c.FieldsPerRecord = 3
+		records = append(records, timedRecord{time: t, record: record})
+	}
+}
 
-		var lines [][]string
-		for {
-			record, err := c.Read()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				return nil, err
-			}
-			// If not finalized it survives
-			if !removed[record[1]] {
-				lines = append(lines, record)
+// writeTimedRecords sorts the records youngest first and
+// writes them to a CSV file. It's save to overwrite
+// because we are in a transaction.
+func writeTimedRecords(fname string, records []timedRecord) error {
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].time.After(records[j].time)
+	})
+
+	if err := unlink(fname); err != nil {
+		return err
+	}
+	f, err := os.Create(fname)
+	if err != nil {
+		return err
+	}
+	c := csv.NewWriter(f)
+
+	for i := range records {
+		if err := c.Write(records[i].record); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	c.Flush()
+	err1 := c.Error()
+	err2 := f.Close()
+	if err1 != nil {
+		return err1
+	}
+	return err2
+}
+
+// updateChanges sets the times of the changed advisories
+// in a changes.csv. Removed advisories are deleted or
+// turned into tombstones according to their retention.
+func updateChanges(changesCSV string, changes map[string]*interimChange) error {
+	records, err := readTimedRecords(changesCSV, 2)
+	if err != nil {
+		return err
+	}
+	lines := records[:0]
+	for _, r := range records {
+		if c := changes[r.record[1]]; c != nil {
+			switch c.retention {
+			case "":
+				r.time = c.summary.CurrentReleaseDate
+			case retentionDelete:
+				continue
+			case retentionArchive:
+				r.time = c.removedAt
+				r.record[1] = archiveFolder + "/" + r.record[1]
 			}
+			r.record[0] = r.time.Format(time.RFC3339)
 		}
-		return lines, nil
-	}()
+		lines = append(lines, r)
+	}
+	return writeTimedRecords(changesCSV, lines)
+}
 
+// updateIndex removes the deleted and archived advisories from an index.txt.
+func updateIndex(indexTXT string, changes map[string]*interimChange) error {
+	data, err := os.ReadFile(indexTXT)
 	if err != nil {
 		return err
 	}
+	var b bytes.Buffer
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			continue
+		}
+		if c := changes[line]; c != nil &&
+			(c.retention == retentionDelete || c.retention == retentionArchive) {
+			continue
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	if err := unlink(indexTXT); err != nil {
+		return err
+	}
+	return os.WriteFile(indexTXT, b.Bytes(), 0644)
+}
+
+// updateInterims removes the finalized advisories from an interims.csv
+// and sets the times of the ones which are still interim.
+func updateInterims(interimsCSV string, changes map[string]*interimChange) error {
+	records, err := readTimedRecords(interimsCSV, 3)
+	if err != nil {
+		return err
+	}
+
+	lines := records[:0]
+	for _, r := range records {
+		if c := changes[r.record[1]]; c != nil {
+			// If finalized or removed it does not survive.
+			if c.finalized() || c.removed() {
+				continue
+			}
+			r.time = c.summary.CurrentReleaseDate
+			r.record[0] = r.time.Format(time.RFC3339)
+		}
+		lines = append(lines, r)
+	}
 
 	// All interims are finalized now -> remove file.
 	if len(lines) == 0 {
 		return os.RemoveAll(interimsCSV)
 	}
 
-	// Overwrite old. It's save because we are in a transaction.
+	return writeTimedRecords(interimsCSV, lines)
+}
+
+// updateROLIE updates the entries of the changed advisories
+// in a ROLIE feed.
+func updateROLIE(feedFile string, changes []interimChange) error {
 
-	f, err := os.Create(interimsCSV)
+	feed, err := func() (*csaf.ROLIEFeed, error) {
+		f, err := os.Open(feedFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return csaf.LoadROLIEFeed(f)
+	}()
 	if err != nil {
 		return err
 	}
-	c := csv.NewWriter(f)
 
-	if err := c.WriteAll(lines); err != nil {
-		return f.Close()
+	entries := feed.Feed.Entry[:0]
+	for _, entry := range feed.Feed.Entry {
+		var c *interimChange
+		for i := range changes {
+			if changes[i].removed() && entryHasPath(entry, changes[i].path) {
+				c = &changes[i]
+				break
+			}
+		}
+		switch {
+		case c == nil:
+		case c.retention == retentionDelete:
+			continue
+		case c.retention == retentionArchive:
+			href := entry.Content.Src
+			tombstone(entry,
+				strings.TrimSuffix(href, c.path)+archiveFolder+"/"+c.path,
+				c.removedAt)
+		}
+		entries = append(entries, entry)
 	}
+	feed.Feed.Entry = entries
 
-	c.Flush()
-	err1 := c.Error()
-	err2 := f.Close()
-	if err1 != nil {
-		return err1
+	for i := range changes {
+		if changes[i].removed() {
+			continue
+		}
+		sum := changes[i].summary
+		entry := feed.EntryByID(sum.ID)
+		if entry == nil {
+			log.Printf("warning: %s: no entry for %s\n", feedFile, sum.ID)
+			continue
+		}
+		entry.Titel = sum.Title
+		entry.Updated = csaf.TimeStamp(sum.CurrentReleaseDate)
+		if sum.Summary != "" {
+			entry.Summary = &csaf.Summary{Content: sum.Summary}
+		} else {
+			entry.Summary = nil
+		}
 	}
-	return err2
+
+	feed.Feed.Updated = csaf.TimeStamp(time.Now().UTC())
+	feed.SortEntriesByUpdated()
+
+	if err := unlink(feedFile); err != nil {
+		return err
+	}
+	return util.WriteToFile(feedFile, feed)
+}
+
+// entryHasPath checks if a ROLIE feed entry links
+// to the advisory with the given path in the label folder.
+func entryHasPath(entry *csaf.Entry, path string) bool {
+	if strings.HasSuffix(entry.Content.Src, "/"+path) {
+		return true
+	}
+	for i := range entry.Link {
+		if strings.HasSuffix(entry.Link[i].HRef, "/"+path) {
+			return true
+		}
+	}
+	return false
 }
 
 // readInterims scans a interims.csv file for matching
@@ -47,7 +47,7 @@ func (lt *lazyTransaction) Dst() (string, error) {
 	}
 
 	// Copy old content into new.
-	if err := util.DeepCopy(lt.dst, lt.src); err != nil {
+	if err := util.DeepCopy(dst, lt.src); err != nil {
 		os.RemoveAll(dst)
 		return "", err
 	}
@@ -71,16 +71,23 @@ func (lt *lazyTransaction) commit() error {
 	}
 	defer func() { lt.dst = "" }()
 
+	// Resolve old to be removed later.
+	old, err := filepath.EvalSymlinks(lt.src)
+	if err != nil {
+		os.RemoveAll(lt.dst)
+		return err
+	}
+
 	// Switch directories.
 	symlink := filepath.Join(lt.dstDir, filepath.Base(lt.src))
-	if err := os.Symlink(lt.dstDir, symlink); err != nil {
-		os.RemoveAll(lt.dstDir)
+	if err := os.Symlink(lt.dst, symlink); err != nil {
+		os.RemoveAll(lt.dst)
 		return err
 	}
 	if err := os.Rename(symlink, lt.src); err != nil {
-		os.RemoveAll(lt.dstDir)
+		os.RemoveAll(lt.dst)
 		return err
 	}
 
-	return os.RemoveAll(lt.src)
+	return os.RemoveAll(old)
 }
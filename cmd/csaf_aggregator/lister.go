@@ -25,7 +25,8 @@ func (w *worker) listAllowed() bool {
 
 func (w *worker) lister() (*csaf.AggregatorCSAFProvider, error) {
 	// Check if we are allowed to mirror this domain.
-	if !w.listAllowed() {
+	w.report.Allowed = w.listAllowed()
+	if !w.report.Allowed {
 		return nil, fmt.Errorf(
 			"no listing of '%s' allowed", w.provider.Name)
 	}
@@ -9,6 +9,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -22,6 +23,9 @@ type options struct {
 	Config  string `short:"c" long:"config" description:"File name of the configuration file" value-name:"CFG-FILE" default:"aggregator.toml"`
 	Version bool   `long:"version" description:"Display version of the binary"`
 	Interim bool   `short:"i" long:"interim" description:"Perform an interim scan"`
+	Daemon  bool   `short:"d" long:"daemon" description:"Run full and interim scans periodically as configured"`
+	Report  string `short:"r" long:"report" description:"File name to write a JSON report of the run to" value-name:"REPORT-FILE"`
+	DryRun  bool   `short:"n" long:"dry-run" description:"Report what a full run would do without changing anything"`
 }
 
 func errCheck(err error) {
@@ -71,6 +75,28 @@ func main() {
 		cfg.Interim = true
 	}
 
+	if opts.Report != "" {
+		cfg.Report = opts.Report
+	}
+
 	p := processor{cfg: cfg}
+
+	if opts.DryRun {
+		if cfg.Interim || opts.Daemon {
+			errCheck(errors.New("dry runs are only supported for full scans"))
+		}
+		cfg.dryRun = true
+		errCheck(p.dryRun())
+		return
+	}
+
+	if opts.Daemon {
+		if cfg.Interim {
+			errCheck(errors.New("interim scans are scheduled in daemon mode"))
+		}
+		errCheck(p.daemon())
+		return
+	}
+
 	errCheck(lock(cfg.LockFile, p.process))
 }
@@ -0,0 +1,145 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/csaf-poc/csaf_distribution/util"
+)
+
+// newMetrics creates the registry of the metrics of the aggregator.
+func newMetrics() *util.Metrics {
+	m := util.NewMetrics()
+	m.Counter("csaf_aggregator_downloads_total",
+		"Number of advisories downloaded.")
+	m.Counter("csaf_aggregator_download_bytes_total",
+		"Number of bytes of the downloaded advisories.")
+	m.Counter("csaf_aggregator_http_responses_total",
+		"Number of HTTP responses by status class.")
+	m.Counter("csaf_aggregator_validation_failures_total",
+		"Number of advisories failing the schema validation or the tests.")
+	m.Counter("csaf_aggregator_rejected_total",
+		"Number of advisories refused to be mirrored.")
+	m.Counter("csaf_aggregator_runs_total",
+		"Number of runs by result.")
+	m.Gauge("csaf_aggregator_run_duration_seconds",
+		"Duration of the last run.")
+	m.Gauge("csaf_aggregator_last_success_timestamp_seconds",
+		"Unix time of the last successful run.")
+	m.Gauge("csaf_aggregator_advisories",
+		"Number of advisories after the last successful full run.")
+	return m
+}
+
+// recordMetrics records the outcome of the run of a provider.
+func (p *processor) recordMetrics(pr *providerReport) {
+	m := p.cfg.metrics
+	if m == nil || pr == nil {
+		return
+	}
+	name := pr.Name
+	m.Add("csaf_aggregator_downloads_total",
+		float64(pr.Downloaded), "provider", name)
+	m.Add("csaf_aggregator_download_bytes_total",
+		float64(pr.DownloadedBytes), "provider", name)
+	m.Add("csaf_aggregator_validation_failures_total",
+		float64(pr.Invalid), "provider", name)
+	m.Add("csaf_aggregator_rejected_total",
+		float64(pr.Rejected), "provider", name)
+	m.Set("csaf_aggregator_run_duration_seconds",
+		pr.Duration, "provider", name, "mode", pr.Mode)
+
+	if pr.Error != "" {
+		m.Add("csaf_aggregator_runs_total",
+			1, "provider", name, "mode", pr.Mode, "result", "failure")
+		return
+	}
+	m.Add("csaf_aggregator_runs_total",
+		1, "provider", name, "mode", pr.Mode, "result", "success")
+	m.Set("csaf_aggregator_last_success_timestamp_seconds",
+		float64(pr.Finished.Unix()), "provider", name, "mode", pr.Mode)
+	if pr.Mode == "full" {
+		m.Set("csaf_aggregator_advisories",
+			float64(pr.Advisories), "provider", name)
+	}
+}
+
+// writeMetrics writes the metrics to the configured file.
+func (p *processor) writeMetrics() {
+	if p.cfg.MetricsFile == "" {
+		return
+	}
+	if err := p.cfg.metrics.WriteFile(p.cfg.MetricsFile); err != nil {
+		log.Printf("error: writing metrics failed: %v\n", err)
+	}
+}
+
+// serveMetrics serves the metrics via HTTP in the background
+// if an address is configured.
+func (p *processor) serveMetrics() {
+	if p.cfg.MetricsAddress == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", p.cfg.metrics)
+	go func() {
+		log.Printf("serving metrics on %s\n", p.cfg.MetricsAddress)
+		if err := http.ListenAndServe(p.cfg.MetricsAddress, mux); err != nil {
+			log.Printf("error: serving metrics failed: %v\n", err)
+		}
+	}()
+}
+
+// metricsClient is a client counting the HTTP responses
+// of a provider by their status classes.
+type metricsClient struct {
+	util.Client
+	metrics  *util.Metrics
+	provider string
+}
+
+func (mc *metricsClient) record(res *http.Response, err error) (*http.Response, error) {
+	class := "error"
+	if err == nil {
+		class = strconv.Itoa(res.StatusCode/100) + "xx"
+	}
+	mc.metrics.Add("csaf_aggregator_http_responses_total",
+		1, "provider", mc.provider, "class", class)
+	return res, err
+}
+
+// Do implements the respective method of the Client interface.
+func (mc *metricsClient) Do(req *http.Request) (*http.Response, error) {
+	return mc.record(mc.Client.Do(req))
+}
+
+// Get implements the respective method of the Client interface.
+func (mc *metricsClient) Get(url string) (*http.Response, error) {
+	return mc.record(mc.Client.Get(url))
+}
+
+// Head implements the respective method of the Client interface.
+func (mc *metricsClient) Head(url string) (*http.Response, error) {
+	return mc.record(mc.Client.Head(url))
+}
+
+// Post implements the respective method of the Client interface.
+func (mc *metricsClient) Post(url, contentType string, body io.Reader) (*http.Response, error) {
+	return mc.record(mc.Client.Post(url, contentType, body))
+}
+
+// PostForm implements the respective method of the Client interface.
+func (mc *metricsClient) PostForm(url string, data url.Values) (*http.Response, error) {
+	return mc.record(mc.Client.PostForm(url, data))
+}
@@ -12,14 +12,15 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"crypto/sha512"
-	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strconv"
@@ -54,6 +55,7 @@ func (w *worker) handleROLIE(
 			up, err := url.Parse(string(*feed.URL))
 			if err != nil {
 				log.Printf("Invalid URL %s in feed: %v.", *feed.URL, err)
+				w.incomplete = true
 				continue
 			}
 			feedURL := base.ResolveReference(up).String()
@@ -62,22 +64,27 @@ func (w *worker) handleROLIE(
 			fb, err := util.BaseURL(feedURL)
 			if err != nil {
 				log.Printf("error: Invalid feed base URL '%s': %v\n", fb, err)
+				w.incomplete = true
 				continue
 			}
 			feedBaseURL, err := url.Parse(fb)
 			if err != nil {
 				log.Printf("error: Cannot parse feed base URL '%s': %v\n", fb, err)
+				w.incomplete = true
 				continue
 			}
 
 			res, err := w.client.Get(feedURL)
 			if err != nil {
 				log.Printf("error: Cannot get feed '%s'\n", err)
+				w.incomplete = true
 				continue
 			}
 			if res.StatusCode != http.StatusOK {
+				res.Body.Close()
 				log.Printf("error: Fetching %s failed. Status code %d (%s)",
 					feedURL, res.StatusCode, res.Status)
+				w.incomplete = true
 				continue
 			}
 			rfeed, err := func() (*csaf.ROLIEFeed, error) {
@@ -86,9 +93,23 @@ func (w *worker) handleROLIE(
 			}()
 			if err != nil {
 				log.Printf("Loading ROLIE feed failed: %v.", err)
+				w.incomplete = true
 				continue
 			}
 			files := resolveURLs(rfeed.Files(), feedBaseURL)
+
+			// Remember the entries to detect unchanged advisories
+			// and to filter them before downloading.
+			for _, e := range rfeed.Feed.Entry {
+				for i := range e.Link {
+					if u, err := url.Parse(e.Link[i].HRef); err == nil {
+						file := feedBaseURL.ResolveReference(u).String()
+						w.updates[file] = time.Time(e.Updated)
+						w.entries[file] = e
+					}
+				}
+			}
+
 			if err := process(feed.TLPLabel, files); err != nil {
 				return err
 			}
@@ -97,6 +118,58 @@ func (w *worker) handleROLIE(
 	return nil
 }
 
+// handleDirectories processes the advisories of the given directory
+// distributions. The TLP label of a directory is derived from its name.
+// If this fails the TLP labels are taken from the advisories.
+func (w *worker) handleDirectories(
+	dirs []string,
+	process func(*csaf.TLPLabel, []string) error,
+) error {
+	base, err := url.Parse(w.loc)
+	if err != nil {
+		return err
+	}
+	log.Printf("Found %d directory distribution(s).\n", len(dirs))
+
+	for _, dir := range dirs {
+		up, err := url.Parse(dir)
+		if err != nil {
+			log.Printf("Invalid directory URL %s: %v.\n", dir, err)
+			w.incomplete = true
+			continue
+		}
+		dirURL := base.ResolveReference(up)
+		// Make sure relative references are resolved inside the directory.
+		if !strings.HasSuffix(dirURL.Path, "/") {
+			dirURL.Path += "/"
+		}
+		log.Printf("Directory URL: %s\n", dirURL)
+
+		files, err := w.loadFiles(dirURL)
+		if err != nil {
+			log.Printf("error: Cannot load advisories from %s: %v\n", dirURL, err)
+			w.incomplete = true
+			continue
+		}
+
+		if err := process(tlpLabelFromDirectory(dirURL), files); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tlpLabelFromDirectory derives the TLP label from the last
+// path element of a directory URL. Returns nil if it is no label.
+func tlpLabelFromDirectory(u *url.URL) *csaf.TLPLabel {
+	name := path.Base(strings.TrimSuffix(u.Path, "/"))
+	var label csaf.TLPLabel
+	if err := label.UnmarshalText([]byte(strings.ToUpper(name))); err != nil {
+		return nil
+	}
+	return &label
+}
+
 // mirrorAllowed checks if mirroring is allowed.
 func (w *worker) mirrorAllowed() bool {
 	var b bool
@@ -119,7 +192,8 @@ func (w *worker) mirror() (*csaf.AggregatorCSAFProvider, error) {
 func (w *worker) mirrorInternal() (*csaf.AggregatorCSAFProvider, error) {
 
 	// Check if we are allowed to mirror this domain.
-	if !w.mirrorAllowed() {
+	w.report.Allowed = w.mirrorAllowed()
+	if !w.report.Allowed {
 		return nil, fmt.Errorf(
 			"no mirroring of '%s' allowed", w.provider.Name)
 	}
@@ -127,6 +201,20 @@ func (w *worker) mirrorInternal() (*csaf.AggregatorCSAFProvider, error) {
 	// Collecting the summaries of the advisories.
 	w.summaries = make(map[string][]summary)
 
+	// Advisories not changed since the last run are taken from there.
+	w.previousDir = w.previousMirror()
+	w.previous = make(map[string]map[string]previousFile)
+	w.previousNames = nil
+	w.updates = make(map[string]time.Time)
+	w.entries = make(map[string]*csaf.Entry)
+	w.listed = make(map[string]bool)
+	w.incomplete = false
+
+	// Fetch the keys to verify the signatures of the advisories.
+	if err := w.downloadPGPKeys(); err != nil {
+		return nil, err
+	}
+
 	// Check if we have ROLIE feeds.
 	rolie, err := w.expr.Eval(
 		"$.distributions[*].rolie.feeds", w.metadataProvider)
@@ -138,27 +226,58 @@ func (w *worker) mirrorInternal() (*csaf.AggregatorCSAFProvider, error) {
 	fs, hasRolie := rolie.([]interface{})
 	hasRolie = hasRolie && len(fs) > 0
 
-	if hasRolie {
+	// Check if we have directory distributions.
+	var dirs []string
+	if !hasRolie {
+		if err := w.expr.Extract(
+			"$.distributions[*].directory_url",
+			util.ReMarshalMatcher(&dirs), true, w.metadataProvider,
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case hasRolie:
 		if err := w.handleROLIE(rolie, w.mirrorFiles); err != nil {
 			return nil, err
 		}
-	} else {
-		// No rolie feeds -> try to load files from index.txt
-		baseURL, err := util.BaseURL(w.loc)
+	case len(dirs) > 0:
+		if err := w.handleDirectories(dirs, w.mirrorFiles); err != nil {
+			return nil, err
+		}
+	default:
+		// No rolie feeds or directories -> try to load files
+		// from changes.csv or index.txt next to the provider metadata.
+		base, err := url.Parse(w.loc)
 		if err != nil {
 			return nil, err
 		}
-		files, err := w.loadIndex(baseURL)
+		files, err := w.loadFiles(base)
 		if err != nil {
 			return nil, err
 		}
-		_ = files
-		// XXX: Is treating as white okay? better look into the advisories?
-		white := csaf.TLPLabel(csaf.TLPLabelWhite)
-		if err := w.mirrorFiles(&white, files); err != nil {
+		// The TLP labels are taken from the advisories.
+		if err := w.mirrorFiles(nil, files); err != nil {
 			return nil, err
 		}
-	} // TODO: else scan directories?
+	}
+
+	// Handle the advisories removed by the provider.
+	if err := w.retainRemoved(); err != nil {
+		return nil, err
+	}
+
+	if w.cfg.dryRun {
+		// Nothing is written in a dry run.
+		return w.createAggregatorProvider()
+	}
+
+	// The folder is created when advisories are mirrored.
+	// Create it if there were none.
+	if _, err := w.createDir(); err != nil {
+		return nil, err
+	}
 
 	if err := w.writeIndices(); err != nil {
 		return nil, err
@@ -193,7 +312,8 @@ func (w *worker) mirrorInternal() (*csaf.AggregatorCSAFProvider, error) {
 func (w *worker) labelsFromSummaries() []csaf.TLPLabel {
 	labels := make([]csaf.TLPLabel, 0, len(w.summaries))
 	for label := range w.summaries {
-		labels = append(labels, csaf.TLPLabel(label))
+		// The summaries are keyed by the lower case folder names.
+		labels = append(labels, csaf.TLPLabel(strings.ToUpper(label)))
 	}
 	sort.Slice(labels, func(i, j int) bool { return labels[i] < labels[j] })
 	return labels
@@ -243,7 +363,7 @@ func (w *worker) writeProviderMetadata() error {
 	return util.WriteToFile(fname, pm)
 }
 
-// mirrorPGPKeys creates a local openpgp folder and downloads the referenced
+// mirrorPGPKeys creates a local openpgp folder and stores the referenced
 // OpenPGP keys into it. The own key is also inserted.
 func (w *worker) mirrorPGPKeys(pm *csaf.ProviderMetadata) error {
 	openPGPFolder := filepath.Join(w.dir, "openpgp")
@@ -256,56 +376,37 @@ func (w *worker) mirrorPGPKeys(pm *csaf.ProviderMetadata) error {
 			w.cfg.Domain, w.provider.Name, fingerprint)
 	}
 
+	keys := make([]csaf.PGPKey, 0, len(pm.PGPKeys))
+
 	for i := range pm.PGPKeys {
 		pgpKey := &pm.PGPKeys[i]
-		if pgpKey.URL == nil {
-			log.Printf("ignoring PGP key without URL: %s\n", pgpKey.Fingerprint)
-			continue
-		}
-		if _, err := hex.DecodeString(string(pgpKey.Fingerprint)); err != nil {
-			log.Printf("ignoring PGP with invalid fingerprint: %s\n", *pgpKey.URL)
-			continue
-		}
 
-		// Fetch remote key.
-		res, err := w.client.Get(*pgpKey.URL)
-		if err != nil {
-			os.RemoveAll(openPGPFolder)
-			return err
-		}
+		fingerprint := strings.ToUpper(string(pgpKey.Fingerprint))
 
-		if res.StatusCode != http.StatusOK {
-			os.RemoveAll(openPGPFolder)
-			return fmt.Errorf("cannot fetch PGP key %s: %s (%d)",
-				*pgpKey.URL, res.Status, res.StatusCode)
+		// Only keys which were successfully loaded are mirrored.
+		data := w.pgpKeys[fingerprint]
+		if data == nil {
+			log.Printf("ignoring PGP key which could not be loaded: %s\n",
+				pgpKey.Fingerprint)
+			continue
 		}
 
-		fingerprint := strings.ToUpper(string(pgpKey.Fingerprint))
-
 		localFile := filepath.Join(openPGPFolder, fingerprint+".asc")
 
-		// Download the remote key into our new folder.
-		if err := func() error {
-			defer res.Body.Close()
-			out, err := os.Create(localFile)
-			if err != nil {
-				return err
-			}
-			_, err1 := io.Copy(out, res.Body)
-			err2 := out.Close()
-			if err1 != nil {
-				return err1
-			}
-			return err2
-		}(); err != nil {
+		// Write the remote key into our new folder.
+		if err := os.WriteFile(localFile, data, 0644); err != nil {
 			os.RemoveAll(openPGPFolder)
 			return err
 		}
 
 		// replace the URL
 		url := localKeyURL(fingerprint)
-		pgpKey.URL = &url
+		keys = append(keys, csaf.PGPKey{
+			Fingerprint: csaf.Fingerprint(fingerprint),
+			URL:         &url,
+		})
 	}
+	pm.PGPKeys = keys
 
 	// If we have public key configured copy it into the new folder
 
@@ -345,6 +446,12 @@ func (w *worker) mirrorPGPKeys(pm *csaf.ProviderMetadata) error {
 // createAggregatorProvider fills the "metadata" section in the "csaf_providers" of
 // the aggregator document.
 func (w *worker) createAggregatorProvider() (*csaf.AggregatorCSAFProvider, error) {
+	// If the provider metadata was taken from a mirror
+	// use the entry of the provider in the aggregator of the source.
+	if md := w.provider.metadata; md != nil && md.URL != nil && string(*md.URL) != w.loc {
+		return &csaf.AggregatorCSAFProvider{Metadata: md}, nil
+	}
+
 	const (
 		lastUpdatedExpr = `$.last_updated`
 		publisherExpr   = `$.publisher`
@@ -450,11 +557,15 @@ func (w *worker) downloadSignature(path string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	if res.StatusCode != http.StatusOK {
-		return "", errNotFound
-	}
 	data, err := func() ([]byte, error) {
 		defer res.Body.Close()
+		switch res.StatusCode {
+		case http.StatusOK:
+		case http.StatusNotFound:
+			return nil, errNotFound
+		default:
+			return nil, fmt.Errorf("%s (%d)", res.Status, res.StatusCode)
+		}
 		return io.ReadAll(res.Body)
 	}()
 	if err != nil {
@@ -490,25 +601,84 @@ func (w *worker) sign(data []byte) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	w.report.Signed++
 	return sig.GetArmored()
 }
 
-func (w *worker) mirrorFiles(tlpLabel *csaf.TLPLabel, files []string) error {
-	label := "unknown"
+// publicLabels are the TLP labels of advisories which may be
+// found on a public index without a ROLIE feed.
+var publicLabels = []string{
+	strings.ToLower(csaf.TLPLabelUnlabeled),
+	strings.ToLower(csaf.TLPLabelWhite),
+	strings.ToLower(csaf.TLPLabelGreen),
+}
+
+// advisoryLabel returns the label folder of an advisory.
+// If no TLP label is given by the feed the advisory is found in
+// the label is taken from the document. Documents from such
+// public indices without a label or with a restricted label are refused.
+func advisoryLabel(tlpLabel *csaf.TLPLabel, sum *csaf.AdvisorySummary) (string, error) {
 	if tlpLabel != nil {
-		label = strings.ToLower(string(*tlpLabel))
+		return strings.ToLower(string(*tlpLabel)), nil
+	}
+	if sum.TLPLabel == "" {
+		return "", errors.New("document has no TLP label")
+	}
+	label := strings.ToLower(sum.TLPLabel)
+	for _, public := range publicLabels {
+		if label == public {
+			return label, nil
+		}
 	}
+	return "", fmt.Errorf("TLP:%s document found on public index", sum.TLPLabel)
+}
 
-	summaries := w.summaries[label]
+// mirrorFiles mirrors the given advisories. If tlpLabel is nil
+// the TLP labels are taken from the advisories.
+func (w *worker) mirrorFiles(tlpLabel *csaf.TLPLabel, files []string) error {
 
-	dir, err := w.createDir()
-	if err != nil {
-		return err
+	// The labels to look for unchanged advisories of the last run.
+	labels := publicLabels
+	if tlpLabel != nil {
+		labels = []string{strings.ToLower(string(*tlpLabel))}
+	}
+
+	// Nothing is written in a dry run.
+	var dir string
+	if !w.cfg.dryRun {
+		var err error
+		if dir, err = w.createDir(); err != nil {
+			return err
+		}
 	}
 
 	var content bytes.Buffer
 
-	yearDirs := make(map[int]string)
+	w.report.Discovered += len(files)
+
+	yearDirs := make(map[string]string)
+
+	mkYearDir := func(label string, year int) (string, error) {
+		key := label + "/" + strconv.Itoa(year)
+		yearDir := yearDirs[key]
+		if yearDir == "" {
+			yearDir = filepath.Join(dir, label, strconv.Itoa(year))
+			if err := os.MkdirAll(yearDir, 0755); err != nil {
+				return "", err
+			}
+			//log.Printf("created %s\n", yearDir)
+			yearDirs[key] = yearDir
+		}
+		return yearDir, nil
+	}
+
+	addSummary := func(label, filename, file string, sum *csaf.AdvisorySummary) {
+		w.summaries[label] = append(w.summaries[label], summary{
+			filename: filename,
+			summary:  sum,
+			url:      file,
+		})
+	}
 
 	for _, file := range files {
 		u, err := url.Parse(file)
@@ -520,100 +690,159 @@ func (w *worker) mirrorFiles(tlpLabel *csaf.TLPLabel, files []string) error {
 		// Ignore not confirming filenames.
 		filename := filepath.Base(u.Path)
 		if !util.ConfirmingFileName(filename) {
+			w.report.NonConforming++
 			log.Printf("Not confirming filename %q. Ignoring.\n", filename)
 			continue
 		}
 
+		w.listed[filename] = true
+
+		if !w.provider.preFilter(filename, tlpLabel, w.entries[file]) {
+			w.filtered(file)
+			continue
+		}
+
+		// Take unchanged advisories from the last run.
+		if local, sum := w.unchanged(labels, file, filename); sum != nil {
+			label, err := advisoryLabel(tlpLabel, sum)
+			if err != nil {
+				w.reject(file, err)
+				continue
+			}
+			if !w.provider.postFilter(filename, label, sum) {
+				w.filtered(file)
+				continue
+			}
+			addSummary(label, filename, file, sum)
+			w.report.Unchanged++
+			if w.cfg.dryRun {
+				continue
+			}
+			yearDir, err := mkYearDir(label, sum.InitialReleaseDate.Year())
+			if err != nil {
+				return err
+			}
+			if err := linkFiles(filepath.Join(yearDir, filename), local); err != nil {
+				return err
+			}
+			continue
+		}
+
 		var advisory interface{}
 
-		s256 := sha256.New()
-		s512 := sha512.New()
 		content.Reset()
-		hasher := io.MultiWriter(s256, s512, &content)
 
+		// Read it completely as the hashes and the
+		// signature are made over all of its bytes.
 		download := func(r io.Reader) error {
-			tee := io.TeeReader(r, hasher)
-			return json.NewDecoder(tee).Decode(&advisory)
+			if _, err := io.Copy(&content, r); err != nil {
+				return err
+			}
+			return json.Unmarshal(content.Bytes(), &advisory)
 		}
 
 		if err := downloadJSON(w.client, file, download); err != nil {
+			w.report.DownloadErrors++
 			log.Printf("error: %v\n", err)
 			continue
 		}
 
+		w.report.Downloaded++
+		w.report.DownloadedBytes += int64(content.Len())
+
 		errors, err := csaf.ValidateCSAF(advisory)
 		if err != nil {
-			log.Printf("error: %s: %v", file, err)
+			w.invalid(file, err)
 			continue
 		}
 		if len(errors) > 0 {
-			log.Printf("CSAF file %s has %d validation errors.",
-				file, len(errors))
+			w.invalid(file, fmt.Errorf("%d validation errors", len(errors)))
 			continue
 		}
 
+		if tests := w.cfg.tests(); tests.Enabled() {
+			results, err := tests.Run(advisory)
+			if err != nil {
+				w.invalid(file, err)
+				continue
+			}
+			if errs := w.findings(file, results); len(errs) > 0 {
+				w.invalid(file, fmt.Errorf("fails tests: %s",
+					strings.Join(errs.Strings(), "; ")))
+				continue
+			}
+		}
+
 		sum, err := csaf.NewAdvisorySummary(w.expr, advisory)
 		if err != nil {
-			log.Printf("error: %s: %v\n", file, err)
+			w.invalid(file, err)
 			continue
 		}
-		summaries = append(summaries, summary{
-			filename: filename,
-			summary:  sum,
-			url:      file,
-		})
 
-		year := sum.InitialReleaseDate.Year()
+		label, err := advisoryLabel(tlpLabel, sum)
+		if err != nil {
+			w.reject(file, err)
+			continue
+		}
 
-		yearDir := yearDirs[year]
-		if yearDir == "" {
-			yearDir = filepath.Join(dir, label, strconv.Itoa(year))
-			if err := os.MkdirAll(yearDir, 0755); err != nil {
-				return err
-			}
-			//log.Printf("created %s\n", yearDir)
-			yearDirs[year] = yearDir
+		if !w.provider.postFilter(filename, label, sum) {
+			w.filtered(file)
+			continue
+		}
+
+		data := content.Bytes()
+		s256, s512 := sha256.Sum256(data), sha512.Sum512(data)
+		sum256, sum512 := s256[:], s512[:]
+
+		// Verify against the hashes and the signature of the provider.
+		sig, err := w.verify(file, data, sum256, sum512)
+		if err != nil {
+			w.reject(file, err)
+			w.quarantine(filename, data, err)
+			continue
+		}
+
+		addSummary(label, filename, file, sum)
+		w.newOrChanged(label, filename, sum)
+
+		if w.cfg.dryRun {
+			continue
+		}
+
+		yearDir, err := mkYearDir(label, sum.InitialReleaseDate.Year())
+		if err != nil {
+			return err
 		}
 
 		fname := filepath.Join(yearDir, filename)
 		//log.Printf("write: %s\n", fname)
-		data := content.Bytes()
 		if err := writeFileHashes(
 			fname, filename,
-			data, s256.Sum(nil), s512.Sum(nil),
+			data, sum256, sum512,
 		); err != nil {
 			return err
 		}
 
-		// Try to fetch signature file.
-		sigURL := file + ".asc"
-		ascFile := fname + ".asc"
-		if err := w.downloadSignatureOrSign(sigURL, ascFile, data); err != nil {
+		// Write the signature of the provider or sign it our self.
+		if err := w.writeSignatureOrSign(fname+".asc", sig, data); err != nil {
 			return err
 		}
 	}
-	w.summaries[label] = summaries
 
 	return nil
 }
 
-// downloadSignatureOrSign first tries to download a signature.
-// If this fails it creates a signature itself with the configured key.
-func (w *worker) downloadSignatureOrSign(url, fname string, data []byte) error {
-	sig, err := w.downloadSignature(url)
-
-	if err != nil {
-		if err != errNotFound {
-			log.Printf("error: %s: %v\n", url, err)
-		}
-		// Sign it our self.
+// writeSignatureOrSign writes the given signature. If it is empty
+// the data is signed with the configured key.
+func (w *worker) writeSignatureOrSign(fname, sig string, data []byte) error {
+	if sig == "" {
+		var err error
 		if sig, err = w.sign(data); err != nil {
 			return err
 		}
+		if sig == "" {
+			return nil
+		}
 	}
-
-	if sig != "" {
-		err = os.WriteFile(fname, []byte(sig), 0644)
-	}
-	return err
+	return os.WriteFile(fname, []byte(sig), 0644)
 }
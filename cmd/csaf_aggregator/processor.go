@@ -13,6 +13,8 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
+	"time"
 
 	"github.com/ProtonMail/gopenpgp/v2/crypto"
 	"github.com/csaf-poc/csaf_distribution/csaf"
@@ -21,12 +23,37 @@ import (
 
 type processor struct {
 	cfg *config
+
+	// aggregated are the last results of the providers.
+	aggregated map[string]*csaf.AggregatorCSAFProvider
+
+	// report is the report of the current run if configured.
+	report *runReport
 }
 
 type summary struct {
 	filename string
 	summary  *csaf.AdvisorySummary
 	url      string
+	retained bool      // taken from the last run without being listed.
+	archived time.Time // time of the removal if archived.
+}
+
+// path returns the path of the advisory relative to its label folder.
+func (s *summary) path() string {
+	p := strconv.Itoa(s.summary.InitialReleaseDate.Year()) + "/" + s.filename
+	if !s.archived.IsZero() {
+		p = archiveFolder + "/" + p
+	}
+	return p
+}
+
+// changed returns the time of the last change of the advisory.
+func (s *summary) changed() time.Time {
+	if !s.archived.IsZero() {
+		return s.archived
+	}
+	return s.summary.CurrentReleaseDate
 }
 
 type worker struct {
@@ -41,6 +68,21 @@ type worker struct {
 	loc              string               // URL of current provider-metadata.json
 	dir              string               // Directory to store data to.
 	summaries        map[string][]summary // the summaries of the advisories.
+
+	keys       *crypto.KeyRing   // public OpenPGP keys of current provider
+	pgpKeys    map[string][]byte // armored public OpenPGP keys by fingerprint
+	keysLoaded bool              // keys are loaded in the interim run
+	rejected   []string          // advisories refused to be mirrored
+
+	previousDir   string                             // mirror of the last run
+	previous      map[string]map[string]previousFile // advisories of the last run by label and path
+	previousNames map[string][]previousFile          // advisories of the last run by filename
+	updates       map[string]time.Time               // update times given by provider
+	entries       map[string]*csaf.Entry             // ROLIE entries of the advisories
+	listed        map[string]bool                    // filenames listed by the provider
+	incomplete    bool                               // not all advisories could be listed
+
+	report *providerReport // report of the current provider
 }
 
 func newWorker(num int, config *config) *worker {
@@ -73,10 +115,38 @@ func (w *worker) createDir() (string, error) {
 
 func (w *worker) locateProviderMetadata(domain string) error {
 
+	// Providers from sources know the locations of their metadata.
+	for _, u := range w.provider.metadataURLs {
+		lpmd := csaf.LoadProviderMetadataFromURL(w.client, u)
+		if lpmd == nil {
+			w.report.Discovery = append(w.report.Discovery, u+" not found.")
+			log.Printf("Looking for provider-metadata.json of '%s': %s not found.\n",
+				w.provider.Name, u)
+			continue
+		}
+		if len(lpmd.Messages) > 0 {
+			w.report.Discovery = append(w.report.Discovery, lpmd.Messages...)
+			for _, msg := range lpmd.Messages {
+				log.Printf("Looking for provider-metadata.json of '%s': %s\n",
+					w.provider.Name, msg)
+			}
+			continue
+		}
+		w.metadataProvider = lpmd.Document
+		w.loc = lpmd.URL
+		w.report.Metadata = lpmd.URL
+		return nil
+	}
+	if len(w.provider.metadataURLs) > 0 {
+		return fmt.Errorf("no provider-metadata.json found for '%s'", w.provider.Name)
+	}
+
 	lpmd := csaf.LoadProviderMetadataForDomain(
 		w.client, domain, func(format string, args ...interface{}) {
+			msg := fmt.Sprintf(format, args...)
+			w.report.Discovery = append(w.report.Discovery, msg)
 			log.Printf(
-				"Looking for provider-metadata.json of '"+domain+"': "+format+"\n", args...)
+				"Looking for provider-metadata.json of '%s': %s\n", domain, msg)
 		})
 
 	if lpmd == nil {
@@ -85,6 +155,7 @@ func (w *worker) locateProviderMetadata(domain string) error {
 
 	w.metadataProvider = lpmd.Document
 	w.loc = lpmd.URL
+	w.report.Metadata = lpmd.URL
 
 	return nil
 }
@@ -175,8 +246,9 @@ func (p *processor) removeOrphans() error {
 	return nil
 }
 
-// process is the main driver of the jobs handled by work.
-func (p *processor) process() error {
+// prepare ensures the folders exist and removes the
+// mirrors of providers which are not configured any longer.
+func (p *processor) prepare() error {
 	if err := ensureDir(p.cfg.Folder); err != nil {
 		return err
 	}
@@ -184,14 +256,42 @@ func (p *processor) process() error {
 	if err := ensureDir(web); err != nil {
 		return err
 	}
+	return p.removeOrphans()
+}
 
-	if err := p.removeOrphans(); err != nil {
+// startReport starts the report of a run if configured.
+func (p *processor) startReport() {
+	if p.cfg.Report != "" {
+		p.report = newRunReport()
+	}
+}
+
+// writeReport writes the report of the current run if configured.
+func (p *processor) writeReport() {
+	if p.report == nil {
+		return
+	}
+	if err := p.report.write(p.cfg.Report); err != nil {
+		log.Printf("error: writing report failed: %v\n", err)
+	}
+	p.report = nil
+}
+
+// process is the main driver of the jobs handled by work.
+func (p *processor) process() error {
+	if err := p.prepare(); err != nil {
 		return err
 	}
 
+	p.startReport()
+	defer p.writeReport()
+	defer p.writeMetrics()
+
 	if p.cfg.Interim {
-		return p.interim()
+		_, err := p.interim(p.cfg.Providers)
+		return err
 	}
 
-	return p.full()
+	_, err := p.full(p.cfg.Providers)
+	return err
 }
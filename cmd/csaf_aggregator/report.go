@@ -0,0 +1,113 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/csaf-poc/csaf_distribution/util"
+)
+
+// runReport is the machine readable report of a run.
+type runReport struct {
+	Started   time.Time         `json:"started"`
+	Finished  time.Time         `json:"finished"`
+	Providers []*providerReport `json:"providers"`
+}
+
+// providerReport is the report of a full or an interim run of a provider.
+type providerReport struct {
+	Name            string            `json:"name"`
+	Mode            string            `json:"mode"`
+	Started         time.Time         `json:"started"`
+	Finished        time.Time         `json:"finished"`
+	Duration        float64           `json:"duration_seconds"`
+	Metadata        string            `json:"provider_metadata,omitempty"`
+	Discovery       []string          `json:"discovery,omitempty"`
+	Allowed         bool              `json:"allowed"`
+	Discovered      int               `json:"discovered"`
+	Downloaded      int               `json:"downloaded"`
+	DownloadedBytes int64             `json:"downloaded_bytes"`
+	Unchanged       int               `json:"unchanged"`
+	DownloadErrors  int               `json:"download_errors"`
+	Invalid         int               `json:"invalid"`
+	Rejected        int               `json:"rejected"`
+	Signed          int               `json:"signed"`
+	NonConforming   int               `json:"nonconforming_filenames"`
+	Filtered        int               `json:"filtered"`
+	Advisories      int               `json:"advisories"`
+	Labels          map[string]int    `json:"labels,omitempty"`
+	New             []string          `json:"new,omitempty"`
+	Changed         []string          `json:"changed,omitempty"`
+	Updated         int               `json:"updated,omitempty"`
+	Finalized       int               `json:"finalized,omitempty"`
+	RejectedReasons []string          `json:"rejected_reasons,omitempty"`
+	InvalidReasons  []string          `json:"invalid_reasons,omitempty"`
+	Findings        []string          `json:"findings,omitempty"`
+	MissingHashes   []string          `json:"missing_hashes,omitempty"`
+	Removed         []removedAdvisory `json:"removed,omitempty"`
+	Error           string            `json:"error,omitempty"`
+}
+
+// newProviderReport starts the report of a run of a provider.
+func newProviderReport(p *provider, mode string) *providerReport {
+	return &providerReport{
+		Name:    p.Name,
+		Mode:    mode,
+		Started: time.Now().UTC(),
+	}
+}
+
+// finish completes the report with the outcome of the run.
+func (pr *providerReport) finish(err error) {
+	pr.Finished = time.Now().UTC()
+	pr.Duration = pr.Finished.Sub(pr.Started).Seconds()
+	if err != nil {
+		pr.Error = err.Error()
+	}
+}
+
+// newRunReport starts the report of a run.
+func newRunReport() *runReport {
+	return &runReport{Started: time.Now().UTC()}
+}
+
+// add adds the reports of providers to the run.
+func (rr *runReport) add(prs ...*providerReport) {
+	if rr != nil {
+		rr.Providers = append(rr.Providers, prs...)
+	}
+}
+
+// write completes the report and writes it to the given file.
+func (rr *runReport) write(fname string) error {
+	rr.Finished = time.Now().UTC()
+
+	tmp, file, err := util.MakeUniqFile(fname + ".tmp")
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(file)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(rr); err != nil {
+		file.Close()
+		os.RemoveAll(tmp)
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, fname)
+}
@@ -0,0 +1,204 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/csaf-poc/csaf_distribution/csaf"
+)
+
+// The retention policies for advisories removed by the provider.
+const (
+	// retentionDelete removes the advisories from the mirror.
+	retentionDelete = "delete"
+	// retentionKeep keeps the advisories in the mirror.
+	retentionKeep = "keep"
+	// retentionArchive moves the advisories into the archive
+	// folder of their label and leaves a tombstone entry in
+	// the changes.csv and the ROLIE feed.
+	retentionArchive = "archive"
+)
+
+// archiveFolder is the folder of the archived advisories
+// inside a label folder.
+const archiveFolder = "archive"
+
+// tombstoneSummary is the summary of the ROLIE feed
+// entry of an archived advisory.
+const tombstoneSummary = "This advisory was removed by the provider. " +
+	"This is an archived copy."
+
+// mirrorLabels are the label folders of a mirror.
+var mirrorLabels = []string{
+	strings.ToLower(csaf.TLPLabelUnlabeled),
+	strings.ToLower(csaf.TLPLabelWhite),
+	strings.ToLower(csaf.TLPLabelGreen),
+	strings.ToLower(csaf.TLPLabelAmber),
+	strings.ToLower(csaf.TLPLabelRed),
+}
+
+// validRetention checks if the given retention policy is known.
+func validRetention(retention string) bool {
+	switch retention {
+	case retentionDelete, retentionKeep, retentionArchive:
+		return true
+	}
+	return false
+}
+
+// removedAdvisory is an advisory not listed by the provider any longer.
+type removedAdvisory struct {
+	Path      string `json:"path"`
+	Retention string `json:"retention"`
+}
+
+// archived checks if a path relative to a label folder
+// is the one of an archived advisory.
+func archived(path string) bool {
+	return strings.HasPrefix(path, archiveFolder+"/")
+}
+
+// removed reports an advisory which is not listed
+// by the provider any longer.
+func (w *worker) removed(path, retention string) {
+	log.Printf("%s: %s removed by provider, retention: %s\n",
+		w.provider.Name, path, retention)
+	w.report.Removed = append(w.report.Removed, removedAdvisory{
+		Path:      path,
+		Retention: retention,
+	})
+}
+
+// loadSummary loads the summary of a local advisory.
+func (w *worker) loadSummary(fname string) (*csaf.AdvisorySummary, error) {
+	var doc interface{}
+	if err := func() error {
+		f, err := os.Open(fname)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return json.NewDecoder(f).Decode(&doc)
+	}(); err != nil {
+		return nil, err
+	}
+	return csaf.NewAdvisorySummary(w.expr, doc)
+}
+
+// retainRemoved takes the advisories of the last run which are not
+// listed by the provider any longer into the new mirror according
+// to the retention policy of the provider. Archived advisories of
+// the last run are kept unless the policy is to delete them.
+func (w *worker) retainRemoved() error {
+
+	if w.previousDir == "" {
+		return nil
+	}
+
+	retention := w.provider.retention(w.cfg)
+
+	if w.incomplete {
+		log.Printf("%s: the advisories could not be listed completely. "+
+			"Keeping the ones not listed.\n", w.provider.Name)
+	}
+
+	now := time.Now().UTC()
+
+	for _, label := range mirrorLabels {
+		files := w.previousFiles(label)
+
+		// Sort for stable reports.
+		paths := make([]string, 0, len(files))
+		for p := range files {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+
+		for _, p := range paths {
+			filename := path.Base(p)
+			if w.listed[filename] {
+				continue
+			}
+			pf := files[p]
+
+			src := filepath.Join(w.previousDir, label, filepath.FromSlash(pf.path))
+			dst := pf.path
+			// Archived advisories keep the time of their removal.
+			removed := pf.updated
+
+			switch {
+			case w.incomplete:
+				// Keep as it is. Decide in the next run.
+			case archived(pf.path):
+				if retention == retentionDelete {
+					log.Printf("%s: deleting archived %s/%s\n",
+						w.provider.Name, label, pf.path)
+					continue
+				}
+			default:
+				w.removed(label+"/"+pf.path, retention)
+				switch retention {
+				case retentionDelete:
+					continue
+				case retentionArchive:
+					dst, removed = archiveFolder+"/"+pf.path, now
+				}
+			}
+
+			if w.cfg.dryRun {
+				continue
+			}
+
+			sum, err := w.loadSummary(src)
+			if err != nil {
+				log.Printf("error: %s: %v\n", src, err)
+				continue
+			}
+
+			dir, err := w.createDir()
+			if err != nil {
+				return err
+			}
+			fname := filepath.Join(dir, label, filepath.FromSlash(dst))
+			if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+				return err
+			}
+			if err := linkFiles(fname, src); err != nil {
+				return err
+			}
+
+			s := summary{
+				filename: filename,
+				summary:  sum,
+				retained: true,
+			}
+			if archived(dst) {
+				s.archived = removed
+			}
+			w.summaries[label] = append(w.summaries[label], s)
+		}
+	}
+	return nil
+}
+
+// tombstone turns a ROLIE feed entry into the one of an archived advisory.
+func tombstone(entry *csaf.Entry, archiveURL string, removed time.Time) {
+	entry.Updated = csaf.TimeStamp(removed)
+	entry.Link = []csaf.Link{{Rel: "self", HRef: archiveURL}}
+	entry.Content.Src = archiveURL
+	entry.Summary = &csaf.Summary{Content: tombstoneSummary}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/csaf-poc/csaf_distribution/csaf"
+	"github.com/csaf-poc/csaf_distribution/util"
+)
+
+// retentionAdvisory returns a minimal advisory with the given id.
+func retentionAdvisory(id string) string {
+	return fmt.Sprintf(`{"document": {
+  "category": "csaf_base",
+  "csaf_version": "2.0",
+  "distribution": {"tlp": {"label": "WHITE"}},
+  "publisher": {"category": "vendor", "name": "ACME", "namespace": "https://example.com"},
+  "title": "%[1]s",
+  "tracking": {
+    "id": "%[1]s", "status": "final", "version": "1",
+    "initial_release_date": "2022-01-01T00:00:00Z",
+    "current_release_date": "2022-01-01T00:00:00Z",
+    "revision_history": [{"date": "2022-01-01T00:00:00Z", "number": "1", "summary": "Initial."}]
+  }
+}}`, id)
+}
+
+// writePrevious writes a mirror of a last run with the
+// advisories at the given paths of the white label folder.
+func writePrevious(t *testing.T, dir string, paths []string) {
+	white := filepath.Join(dir, "white")
+	var changes strings.Builder
+	for _, p := range paths {
+		fname := filepath.Join(white, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+			t.Fatal(err)
+		}
+		name := strings.TrimSuffix(filepath.Base(p), ".json")
+		for _, ext := range []string{"", ".sha256", ".sha512"} {
+			if err := os.WriteFile(fname+ext, []byte(retentionAdvisory(name)), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		fmt.Fprintf(&changes, "%s,%s\n", "2022-01-01T00:00:00Z", p)
+	}
+	if err := os.WriteFile(
+		filepath.Join(white, "changes.csv"), []byte(changes.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidRetention(t *testing.T) {
+	for _, tc := range []struct {
+		retention string
+		want      bool
+	}{
+		{"delete", true},
+		{"keep", true},
+		{"archive", true},
+		{"", false},
+		{"Keep", false},
+		{"forever", false},
+	} {
+		if got := validRetention(tc.retention); got != tc.want {
+			t.Errorf("%q: expected %t, got %t", tc.retention, tc.want, got)
+		}
+	}
+}
+
+func TestArchived(t *testing.T) {
+	if !archived("archive/2022/a.json") {
+		t.Error("expected archive/2022/a.json to be archived")
+	}
+	if archived("2022/archive.json") {
+		t.Error("expected 2022/archive.json not to be archived")
+	}
+}
+
+func TestRetainRemoved(t *testing.T) {
+	previous := t.TempDir()
+	writePrevious(t, previous, []string{
+		"2022/listed.json",
+		"2022/removed.json",
+		"archive/2022/archived.json",
+	})
+
+	for _, tc := range []struct {
+		retention  string
+		incomplete bool
+		dryRun     bool
+		paths      []string // paths of the retained advisories
+		removed    []string // reported removed advisories
+	}{
+		{retentionDelete, false, false, nil, []string{"white/2022/removed.json"}},
+		{retentionKeep, false, false,
+			[]string{"2022/removed.json", "archive/2022/archived.json"},
+			[]string{"white/2022/removed.json"}},
+		{retentionArchive, false, false,
+			[]string{"archive/2022/removed.json", "archive/2022/archived.json"},
+			[]string{"white/2022/removed.json"}},
+		{retentionDelete, true, false,
+			[]string{"2022/removed.json", "archive/2022/archived.json"}, nil},
+		{retentionArchive, false, true, nil, []string{"white/2022/removed.json"}},
+	} {
+		name := tc.retention
+		if tc.incomplete {
+			name += " incomplete"
+		}
+		if tc.dryRun {
+			name += " dry run"
+		}
+
+		folder := t.TempDir()
+		w := &worker{
+			cfg: &config{
+				Folder:    folder,
+				Retention: retentionKeep,
+				dryRun:    tc.dryRun,
+			},
+			expr:        util.NewPathEval(),
+			provider:    &provider{Name: "test", Retention: tc.retention},
+			previousDir: previous,
+			previous:    map[string]map[string]previousFile{},
+			listed:      map[string]bool{"listed.json": true},
+			incomplete:  tc.incomplete,
+			summaries:   map[string][]summary{},
+			report:      &providerReport{},
+		}
+		if err := w.retainRemoved(); err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+
+		var removed []string
+		for _, r := range w.report.Removed {
+			if r.Retention != tc.retention {
+				t.Errorf("%s: %s reported with retention %s", name, r.Path, r.Retention)
+			}
+			removed = append(removed, r.Path)
+		}
+		if !reflect.DeepEqual(removed, tc.removed) {
+			t.Errorf("%s: expected removed %v, got %v", name, tc.removed, removed)
+		}
+
+		var paths []string
+		for _, s := range w.summaries["white"] {
+			if !s.retained {
+				t.Errorf("%s: %s not marked as retained", name, s.filename)
+			}
+			if archived(s.path()) == s.archived.IsZero() {
+				t.Errorf("%s: %s has unexpected removal time %v",
+					name, s.path(), s.archived)
+			}
+			paths = append(paths, s.path())
+
+			fname := filepath.Join(w.dir, "white", filepath.FromSlash(s.path()))
+			if _, err := os.Stat(fname); err != nil {
+				t.Errorf("%s: %v", name, err)
+			}
+		}
+		sort.Strings(paths)
+		sort.Strings(tc.paths)
+		if !reflect.DeepEqual(paths, tc.paths) {
+			t.Errorf("%s: expected retained %v, got %v", name, tc.paths, paths)
+		}
+	}
+}
+
+func TestTombstone(t *testing.T) {
+	const archiveURL = "https://example.com/white/archive/2022/a.json"
+	removed := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+	entry := &csaf.Entry{
+		ID:   "A",
+		Link: []csaf.Link{{Rel: "self", HRef: "https://example.com/white/2022/a.json"}},
+	}
+	tombstone(entry, archiveURL, removed)
+
+	if !time.Time(entry.Updated).Equal(removed) {
+		t.Errorf("unexpected updated %v", time.Time(entry.Updated))
+	}
+	if len(entry.Link) != 1 || entry.Link[0].HRef != archiveURL || entry.Content.Src != archiveURL {
+		t.Errorf("entry does not point to the archive: %v %s", entry.Link, entry.Content.Src)
+	}
+	if entry.Summary == nil || entry.Summary.Content != tombstoneSummary {
+		t.Error("entry has no tombstone summary")
+	}
+}
@@ -0,0 +1,232 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/csaf-poc/csaf_distribution/csaf"
+	"github.com/csaf-poc/csaf_distribution/util"
+)
+
+// source is a remote aggregator or lister whose
+// CSAF providers are added to the providers.
+type source struct {
+	URL string `toml:"url"`
+	// Include are glob patterns. If given only providers
+	// with matching names are added.
+	Include []string `toml:"include"`
+	// Exclude are glob patterns of the names of
+	// providers which are not added.
+	Exclude []string `toml:"exclude"`
+	// PreferMirror downloads from the mirrors listed for
+	// a provider first and falls back to the provider itself.
+	PreferMirror bool `toml:"prefer_mirror"`
+	// Rate and Insecure apply to the added providers
+	// like in a provider entry.
+	Rate     *float64 `toml:"rate"`
+	Insecure *bool    `toml:"insecure"`
+
+	// last are the providers of the last successful load.
+	last []*provider
+}
+
+// matches checks if a provider of the given name is
+// selected by the include and exclude patterns.
+func (s *source) matches(name string) bool {
+	match := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+		return false
+	}
+	return (len(s.Include) == 0 || match(s.Include)) && !match(s.Exclude)
+}
+
+// check checks the configuration of the source.
+func (s *source) check() error {
+	if s.URL == "" {
+		return errors.New("no url given for source")
+	}
+	for _, pattern := range append(s.Include, s.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern '%s' in source '%s': %v",
+				pattern, s.URL, err)
+		}
+	}
+	return nil
+}
+
+// load fetches and validates the aggregator.json of the source.
+func (s *source) load(client util.Client) (*csaf.Aggregator, error) {
+	res, err := client.Get(s.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot fetch %s: %s (%d)",
+			s.URL, res.Status, res.StatusCode)
+	}
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("cannot decode %s: %v", s.URL, err)
+	}
+
+	errs, err := csaf.ValidateAggregator(doc)
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf(
+			"%s has %d validation issues", s.URL, len(errs))
+	}
+
+	var agg csaf.Aggregator
+	if err := util.ReMarshalJSON(&agg, doc); err != nil {
+		return nil, err
+	}
+	return &agg, nil
+}
+
+// providers turns the CSAF providers of the aggregator into providers.
+// The host of the provider metadata is used as the name of a provider.
+func (s *source) providers(agg *csaf.Aggregator) []*provider {
+	var prvs []*provider
+	for _, acp := range agg.CSAFProviders {
+		if acp == nil || acp.Metadata == nil || acp.Metadata.URL == nil {
+			continue
+		}
+		metadataURL := string(*acp.Metadata.URL)
+		u, err := url.Parse(metadataURL)
+		if err != nil || u.Host == "" {
+			log.Printf("ignoring provider with invalid URL '%s' from %s\n",
+				metadataURL, s.URL)
+			continue
+		}
+		name := u.Hostname()
+		if !s.matches(name) {
+			continue
+		}
+
+		urls := []string{metadataURL}
+		if s.PreferMirror {
+			mirrors := make([]string, len(acp.Mirrors))
+			for i, mirror := range acp.Mirrors {
+				mirrors[i] = string(mirror)
+			}
+			urls = append(mirrors, metadataURL)
+		}
+
+		prvs = append(prvs, &provider{
+			Name:         name,
+			Domain:       u.Host,
+			Rate:         s.Rate,
+			Insecure:     s.Insecure,
+			metadataURLs: urls,
+			metadata:     acp.Metadata,
+		})
+	}
+	return prvs
+}
+
+// uniqueName returns the name of a provider from a source which
+// is not used by another provider from a source, yet. Providers with
+// the same host name are told apart by a hash of their metadata URL.
+func uniqueName(p *provider, used map[string]string) string {
+	metadataURL := p.metadataURLs[len(p.metadataURLs)-1]
+	if other, ok := used[p.Name]; !ok || other == metadataURL {
+		return p.Name
+	}
+	hash := sha256.Sum256([]byte(metadataURL))
+	return p.Name + "-" + hex.EncodeToString(hash[:4])
+}
+
+// resolveSources sets the providers to the configured ones and
+// the ones of the sources. Configured providers take precedence over
+// providers of the same name from the sources. It is called before
+// each full run. Sources which cannot be loaded are logged and their
+// providers of the last successful load are taken.
+func (c *config) resolveSources() error {
+
+	if c.configured == nil {
+		c.configured = append([]*provider{}, c.Providers...)
+	}
+	providers := append([]*provider(nil), c.configured...)
+
+	configured := make(map[string]bool)
+	for _, p := range c.configured {
+		configured[p.Name] = true
+	}
+	// The metadata URLs of the providers from the sources by name.
+	used := make(map[string]string)
+
+	for _, s := range c.Sources {
+		client := c.httpClient(&provider{
+			Name:     s.URL,
+			Rate:     s.Rate,
+			Insecure: s.Insecure,
+		})
+		if agg, err := s.load(client); err != nil {
+			log.Printf("error: source '%s': %v\n", s.URL, err)
+			if s.last == nil {
+				continue
+			}
+			log.Printf("taking %d providers of last load of %s\n", len(s.last), s.URL)
+		} else {
+			s.last = s.providers(agg)
+		}
+		var added int
+		for _, p := range s.last {
+			if configured[p.Name] {
+				log.Printf("ignoring provider '%s' from %s: already configured\n",
+					p.Name, s.URL)
+				continue
+			}
+			if name := uniqueName(p, used); name != p.Name {
+				log.Printf("provider '%s' from %s is named '%s': name already used\n",
+					p.Name, s.URL, name)
+				p.Name = name
+			}
+			if _, ok := used[p.Name]; ok {
+				log.Printf("ignoring provider '%s' from %s: already added\n",
+					p.Name, s.URL)
+				continue
+			}
+			used[p.Name] = p.metadataURLs[len(p.metadataURLs)-1]
+			providers = append(providers, p)
+			added++
+		}
+		log.Printf("added %d providers from %s\n", added, s.URL)
+	}
+
+	old := c.Providers
+	c.Providers = providers
+	if err := c.checkProviders(); err != nil {
+		c.Providers = old
+		return err
+	}
+	return nil
+}
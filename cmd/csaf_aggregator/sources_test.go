@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// aggregatorJSON returns an aggregator.json listing
+// providers with the given metadata URLs.
+func aggregatorJSON(urls ...string) string {
+	providers := make([]string, len(urls))
+	for i, u := range urls {
+		providers[i] = fmt.Sprintf(`{"metadata": {
+  "last_updated": "2022-01-01T00:00:00Z",
+  "publisher": {"category": "vendor", "name": "ACME", "namespace": "https://example.com"},
+  "url": "%s"
+}}`, u)
+	}
+	return fmt.Sprintf(`{
+  "aggregator": {"category": "lister", "name": "Lister", "namespace": "https://example.com"},
+  "aggregator_version": "2.0",
+  "canonical_url": "https://example.com/.well-known/csaf-aggregator/aggregator.json",
+  "csaf_providers": [%s],
+  "last_updated": "2022-01-01T00:00:00Z"
+}`, strings.Join(providers, ","))
+}
+
+func TestUniqueName(t *testing.T) {
+	used := map[string]string{
+		"example.com": "https://example.com/a/provider-metadata.json",
+	}
+	other := "https://example.com/b/provider-metadata.json"
+	hash := sha256.Sum256([]byte(other))
+
+	for _, tc := range []struct {
+		name, url, want string
+	}{
+		{"other.com", "https://other.com/provider-metadata.json", "other.com"},
+		{"example.com", "https://example.com/a/provider-metadata.json", "example.com"},
+		{"example.com", other, fmt.Sprintf("example.com-%x", hash[:4])},
+	} {
+		got := uniqueName(&provider{Name: tc.name, metadataURLs: []string{tc.url}}, used)
+		if got != tc.want {
+			t.Errorf("%s: expected name %q, got %q", tc.url, tc.want, got)
+		}
+	}
+}
+
+func TestResolveSources(t *testing.T) {
+	var (
+		code    int
+		content string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(code)
+		fmt.Fprint(rw, content)
+	}))
+	defer srv.Close()
+
+	cfg := &config{
+		AllowSingleProvider: true,
+		Providers:           []*provider{{Name: "configured.com", Domain: "configured.com"}},
+		Sources:             []*source{{URL: srv.URL + "/aggregator.json"}},
+	}
+
+	names := func() []string {
+		var names []string
+		for _, p := range cfg.Providers {
+			names = append(names, p.Name)
+		}
+		return names
+	}
+
+	code, content = http.StatusOK, aggregatorJSON(
+		"https://configured.com/provider-metadata.json",
+		"https://a.com/provider-metadata.json",
+		"https://b.com/one/provider-metadata.json",
+		"https://b.com/two/provider-metadata.json",
+	)
+	if err := cfg.resolveSources(); err != nil {
+		t.Fatal(err)
+	}
+	got := names()
+	if len(got) != 4 || got[0] != "configured.com" || got[1] != "a.com" ||
+		got[2] != "b.com" || !strings.HasPrefix(got[3], "b.com-") {
+		t.Fatalf("unexpected providers %v", got)
+	}
+
+	// A failing source keeps the providers of its last load.
+	code, content = http.StatusInternalServerError, ""
+	if err := cfg.resolveSources(); err != nil {
+		t.Fatal(err)
+	}
+	if again := names(); strings.Join(again, " ") != strings.Join(got, " ") {
+		t.Errorf("expected providers %v, got %v", got, again)
+	}
+
+	code, content = http.StatusOK, aggregatorJSON("https://c.com/provider-metadata.json")
+	if err := cfg.resolveSources(); err != nil {
+		t.Fatal(err)
+	}
+	if got := names(); strings.Join(got, " ") != "configured.com c.com" {
+		t.Errorf("unexpected providers %v", got)
+	}
+
+	// A source failing from the start adds no providers.
+	cfg = &config{
+		AllowSingleProvider: true,
+		Providers:           []*provider{{Name: "configured.com", Domain: "configured.com"}},
+		Sources:             []*source{{URL: srv.URL + "/aggregator.json"}},
+	}
+	code, content = http.StatusNotFound, ""
+	if err := cfg.resolveSources(); err != nil {
+		t.Fatal(err)
+	}
+	if got := names(); strings.Join(got, " ") != "configured.com" {
+		t.Errorf("unexpected providers %v", got)
+	}
+}
@@ -0,0 +1,269 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/csaf-poc/csaf_distribution/csaf"
+	"github.com/csaf-poc/csaf_distribution/util"
+)
+
+// downloadPGPKeys downloads the public OpenPGP keys listed in the
+// provider metadata of the current provider. Keys which cannot be
+// loaded or which do not match their fingerprints are ignored.
+func (w *worker) downloadPGPKeys() error {
+
+	w.keys = nil
+	w.pgpKeys = make(map[string][]byte)
+
+	var keys []csaf.PGPKey
+	if err := w.expr.Extract(
+		`$.public_openpgp_keys`, util.ReMarshalMatcher(&keys), true, w.metadataProvider,
+	); err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		log.Printf("%s: no public OpenPGP keys found.\n", w.provider.Name)
+		return nil
+	}
+
+	base, err := url.Parse(w.loc)
+	if err != nil {
+		return err
+	}
+
+	keyring, err := crypto.NewKeyRing(nil)
+	if err != nil {
+		return err
+	}
+
+	for i := range keys {
+		pgpKey := &keys[i]
+		if pgpKey.URL == nil {
+			log.Printf("ignoring PGP key without URL: %s\n", pgpKey.Fingerprint)
+			continue
+		}
+		if _, err := hex.DecodeString(string(pgpKey.Fingerprint)); err != nil {
+			log.Printf("ignoring PGP with invalid fingerprint: %s\n", *pgpKey.URL)
+			continue
+		}
+		up, err := url.Parse(*pgpKey.URL)
+		if err != nil {
+			log.Printf("ignoring PGP key with invalid URL '%s': %v\n", *pgpKey.URL, err)
+			continue
+		}
+		u := base.ResolveReference(up).String()
+
+		res, err := w.client.Get(u)
+		if err != nil {
+			log.Printf("error: cannot fetch PGP key %s: %v\n", u, err)
+			continue
+		}
+		if res.StatusCode != http.StatusOK {
+			res.Body.Close()
+			log.Printf("error: cannot fetch PGP key %s: %s (%d)\n",
+				u, res.Status, res.StatusCode)
+			continue
+		}
+
+		data, err := func() ([]byte, error) {
+			defer res.Body.Close()
+			return io.ReadAll(res.Body)
+		}()
+		if err != nil {
+			log.Printf("error: cannot fetch PGP key %s: %v\n", u, err)
+			continue
+		}
+
+		key, err := crypto.NewKeyFromArmoredReader(bytes.NewReader(data))
+		if err != nil {
+			log.Printf("error: cannot load PGP key %s: %v\n", u, err)
+			continue
+		}
+
+		fingerprint := strings.ToUpper(string(pgpKey.Fingerprint))
+
+		if !strings.EqualFold(key.GetFingerprint(), fingerprint) {
+			log.Printf("error: fingerprint of PGP key %s does not match %s\n",
+				u, fingerprint)
+			continue
+		}
+
+		if err := keyring.AddKey(key); err != nil {
+			log.Printf("error: cannot use PGP key %s: %v\n", u, err)
+			continue
+		}
+		w.pgpKeys[fingerprint] = data
+	}
+
+	if keyring.CountEntities() > 0 {
+		w.keys = keyring
+	}
+	return nil
+}
+
+// verifyHashes compares the given sums with the hash files
+// of the provider and returns the number of verified hashes.
+// Missing hash files are reported but not treated as errors,
+// all other failures to fetch them are.
+func (w *worker) verifyHashes(file string, s256, s512 []byte) (int, error) {
+	var verified int
+	for _, h := range []struct {
+		ext string
+		sum []byte
+	}{
+		{".sha256", s256},
+		{".sha512", s512},
+	} {
+		hashURL := file + h.ext
+		res, err := w.client.Get(hashURL)
+		if err != nil {
+			return 0, err
+		}
+		remote, err := func() ([]byte, error) {
+			defer res.Body.Close()
+			switch res.StatusCode {
+			case http.StatusOK:
+			case http.StatusNotFound:
+				return nil, errNotFound
+			default:
+				return nil, fmt.Errorf("%s (%d)", res.Status, res.StatusCode)
+			}
+			return util.HashFromReader(res.Body)
+		}()
+		switch {
+		case err == errNotFound:
+			log.Printf("%s: hash %s is missing\n", w.provider.Name, hashURL)
+			w.report.MissingHashes = append(w.report.MissingHashes, hashURL)
+			continue
+		case err != nil:
+			return 0, fmt.Errorf("cannot read %s: %v", hashURL, err)
+		case !bytes.Equal(remote, h.sum):
+			return 0, fmt.Errorf("hash %s does not match", hashURL)
+		}
+		verified++
+	}
+	return verified, nil
+}
+
+// verifySignature checks the armored signature of the data
+// against the public OpenPGP keys of the provider.
+func (w *worker) verifySignature(sig string, data []byte) error {
+	if w.keys == nil {
+		return errors.New("no public OpenPGP keys to verify signatures")
+	}
+	pgpSig, err := crypto.NewPGPSignatureFromArmored(sig)
+	if err != nil {
+		return err
+	}
+	pm := crypto.NewPlainMessage(data)
+	return w.keys.VerifyDetached(pm, pgpSig, crypto.GetUnixTime())
+}
+
+// verify checks the downloaded advisory against the hashes
+// and the signature of the provider. It returns the signature
+// of the provider. Advisories without a signature or without
+// keys to verify it are refused unless unsigned ones are allowed.
+// In this case an empty signature is returned to sign it our self.
+// Even then advisories are refused if neither a hash nor
+// the signature could be verified.
+func (w *worker) verify(file string, data, s256, s512 []byte) (string, error) {
+	hashes, err := w.verifyHashes(file, s256, s512)
+	if err != nil {
+		return "", err
+	}
+	unverified := func() (string, error) {
+		if hashes == 0 {
+			return "", fmt.Errorf("neither hashes nor signature of %s could be verified", file)
+		}
+		return "", nil
+	}
+	sigURL := file + ".asc"
+	sig, err := w.downloadSignature(sigURL)
+	switch {
+	case err == errNotFound:
+		if w.provider.allowUnsigned(w.cfg) {
+			return unverified()
+		}
+		return "", fmt.Errorf("signature %s is missing", sigURL)
+	case err != nil:
+		return "", fmt.Errorf("cannot load signature %s: %v", sigURL, err)
+	}
+	if w.keys == nil && w.provider.allowUnsigned(w.cfg) {
+		log.Printf("%s: no public OpenPGP keys to verify %s, signing it.\n",
+			w.provider.Name, sigURL)
+		return unverified()
+	}
+	if err := w.verifySignature(sig, data); err != nil {
+		return "", fmt.Errorf("signature %s does not match: %v", sigURL, err)
+	}
+	return sig, nil
+}
+
+// reject reports an advisory which is refused to be mirrored.
+func (w *worker) reject(file string, reason error) {
+	log.Printf("error: %s: rejected: %v\n", file, reason)
+	w.rejected = append(w.rejected, fmt.Sprintf("%s: %v", file, reason))
+}
+
+// invalid reports an advisory failing the validation or the tests.
+func (w *worker) invalid(file string, reason error) {
+	log.Printf("error: %s: invalid: %v\n", file, reason)
+	w.report.Invalid++
+	w.report.InvalidReasons = append(w.report.InvalidReasons,
+		fmt.Sprintf("%s: %v", file, reason))
+}
+
+// findings reports the warnings and the informative findings of
+// the tests run on an advisory and returns the failed tests.
+func (w *worker) findings(file string, results csaf.TestResults) csaf.TestResults {
+	for _, severity := range []csaf.TestSeverity{csaf.TestWarning, csaf.TestInfo} {
+		for _, finding := range results.BySeverity(severity).Strings() {
+			log.Printf("%s: %s: %s\n", severity, file, finding)
+			w.report.Findings = append(w.report.Findings,
+				fmt.Sprintf("%s: %s: %s", file, severity, finding))
+		}
+	}
+	return results.BySeverity(csaf.TestError)
+}
+
+// quarantine stores an advisory which failed the verification
+// into the quarantine folder if one is configured.
+func (w *worker) quarantine(filename string, data []byte, reason error) {
+	if w.cfg.Quarantine == "" || w.cfg.dryRun {
+		return
+	}
+	dir := filepath.Join(w.cfg.Quarantine, w.provider.Name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("error: %v\n", err)
+		return
+	}
+	fname := filepath.Join(dir, filename)
+	if err := os.WriteFile(fname, data, 0644); err != nil {
+		log.Printf("error: %v\n", err)
+		return
+	}
+	reasonFile := fname + ".reason"
+	if err := os.WriteFile(reasonFile, []byte(reason.Error()+"\n"), 0644); err != nil {
+		log.Printf("error: %v\n", err)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+)
+
+func TestVerify(t *testing.T) {
+	data := []byte(`{"document": {}}` + "\n")
+	s256, s512 := sha256.Sum256(data), sha512.Sum512(data)
+
+	key, err := crypto.GenerateKey("test", "test@example.com", "x25519", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ring, err := crypto.NewKeyRing(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := ring.SignDetached(crypto.NewPlainMessage(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	armored, err := sig.GetArmored()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ring.SignDetached(crypto.NewPlainMessage([]byte("other")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherArmored, err := other.GetArmored()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type file struct {
+		code    int
+		content string
+	}
+	// files maps the served paths to the status codes and the contents.
+	var files map[string]file
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		f, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(rw, r)
+			return
+		}
+		rw.WriteHeader(f.code)
+		fmt.Fprint(rw, f.content)
+	}))
+	defer srv.Close()
+
+	hashes := map[string]file{
+		"/a.json.sha256": {http.StatusOK, fmt.Sprintf("%x a.json\n", s256)},
+		"/a.json.sha512": {http.StatusOK, fmt.Sprintf("%x a.json\n", s512)},
+	}
+	with := func(extra map[string]file) map[string]file {
+		m := map[string]file{}
+		for k, v := range hashes {
+			m[k] = v
+		}
+		for k, v := range extra {
+			m[k] = v
+		}
+		return m
+	}
+	signed := map[string]file{"/a.json.asc": {http.StatusOK, armored}}
+
+	for _, tc := range []struct {
+		name          string
+		files         map[string]file
+		keys          *crypto.KeyRing
+		allowUnsigned bool
+		wantSig       string
+		fail          bool
+		missing       int
+	}{
+		{"signed", with(signed), ring, false, armored, false, 0},
+		{"no hashes", signed, ring, false, armored, false, 2},
+		{"sha256 only", map[string]file{
+			"/a.json.sha256": hashes["/a.json.sha256"],
+			"/a.json.asc":    {http.StatusOK, armored},
+		}, ring, false, armored, false, 1},
+		{"wrong hash", with(map[string]file{
+			"/a.json.sha256": {http.StatusOK, fmt.Sprintf("%x a.json\n", s512[:32])},
+			"/a.json.asc":    {http.StatusOK, armored},
+		}), ring, false, "", true, 0},
+		{"hash forbidden", with(map[string]file{
+			"/a.json.sha512": {http.StatusForbidden, ""},
+			"/a.json.asc":    {http.StatusOK, armored},
+		}), ring, false, "", true, 0},
+		{"wrong signature", with(map[string]file{
+			"/a.json.asc": {http.StatusOK, otherArmored},
+		}), ring, false, "", true, 0},
+		{"signature failing", with(map[string]file{
+			"/a.json.asc": {http.StatusInternalServerError, ""},
+		}), ring, true, "", true, 0},
+		{"unsigned", hashes, ring, false, "", true, 0},
+		{"unsigned allowed", hashes, ring, true, "", false, 0},
+		{"unsigned allowed without hashes", nil, ring, true, "", true, 2},
+		{"no keys", with(signed), nil, false, "", true, 0},
+		{"no keys allowed", with(signed), nil, true, "", false, 0},
+		{"no keys allowed without hashes", signed, nil, true, "", true, 2},
+	} {
+		files = tc.files
+		w := &worker{
+			cfg:      &config{AllowUnsigned: tc.allowUnsigned},
+			provider: &provider{Name: "test"},
+			client:   srv.Client(),
+			keys:     tc.keys,
+			report:   &providerReport{},
+		}
+		got, err := w.verify(srv.URL+"/a.json", data, s256[:], s512[:])
+		if n := len(w.report.MissingHashes); n != tc.missing {
+			t.Errorf("%s: expected %d missing hashes, got %d", tc.name, tc.missing, n)
+		}
+		if (err != nil) != tc.fail {
+			t.Errorf("%s: expected failure %t, got %v", tc.name, tc.fail, err)
+			continue
+		}
+		if got != tc.wantSig {
+			t.Errorf("%s: unexpected signature %q", tc.name, got)
+		}
+	}
+}
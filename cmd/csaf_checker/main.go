@@ -18,6 +18,7 @@ import (
 	"log"
 	"os"
 
+	"github.com/csaf-poc/csaf_distribution/csaf"
 	"github.com/csaf-poc/csaf_distribution/util"
 	"github.com/jessevdk/go-flags"
 )
@@ -34,6 +35,20 @@ type options struct {
 	Version    bool     `long:"version" description:"Display version of the binary"`
 	Verbose    bool     `long:"verbose" short:"v" description:"Verbose output"`
 	Rate       *float64 `long:"rate" short:"r" description:"The average upper limit of https operations per second"`
+	Retries    int      `long:"retries" description:"Number of retries of requests failed because of network or transient server errors" value-name:"NUM"`
+	Cache      bool     `long:"cache" description:"Send conditional requests for responses with an ETag or Last-Modified header"`
+	Mandatory  bool     `long:"mandatory-tests" short:"m" description:"Run the mandatory tests of the CSAF standard on the advisories"`
+	Optional   bool     `long:"optional-tests" description:"Run the optional and informative tests of the CSAF standard on the advisories"`
+	Enforce    []string `long:"enforce-test" description:"Report findings of this optional or informative test as errors (can be given multiple times)" value-name:"TEST-ID"`
+}
+
+// tests returns the configuration of the CSAF tests run on the advisories.
+func (o *options) tests() *csaf.TestConfig {
+	return &csaf.TestConfig{
+		Mandatory: o.Mandatory,
+		Optional:  o.Optional,
+		Enforce:   o.Enforce,
+	}
 }
 
 func errCheck(err error) {
@@ -314,17 +314,38 @@ func (p *processor) httpClient() util.Client {
 		client = &hClient
 	}
 
-	if p.opts.Rate == nil {
-		p.client = client
-		return client
+	if p.opts.Cache {
+		client = &util.CachingClient{Client: client, Cache: &util.HTTPCache{}}
 	}
 
-	p.client = &util.LimitingClient{
-		Client:  client,
-		Limiter: rate.NewLimiter(rate.Limit(*p.opts.Rate), 1),
+	if p.opts.Rate != nil {
+		client = &util.LimitingClient{
+			Client:  client,
+			Limiter: rate.NewLimiter(rate.Limit(*p.opts.Rate), 1),
+		}
+	}
+
+	if p.opts.Retries > 0 {
+		client = &util.RetryingClient{
+			Client:  client,
+			Retries: p.opts.Retries,
+		}
 	}
 
-	return p.client
+	p.client = client
+	return client
+}
+
+// testMessageType maps the severity of a test finding to a message type.
+func testMessageType(severity csaf.TestSeverity) MessageType {
+	switch severity {
+	case csaf.TestError:
+		return ErrorType
+	case csaf.TestWarning:
+		return WarnType
+	default:
+		return InfoType
+	}
 }
 
 var yearFromURL = regexp.MustCompile(`.*/(\d{4})/[^/]+$`)
@@ -390,6 +411,20 @@ func (p *processor) integrity(
 			lg(ErrorType, "CSAF file %s has %d validation errors.", u, len(errors))
 		}
 
+		// Run the tests of the CSAF standard.
+		if tests := p.opts.tests(); tests.Enabled() {
+			results, err := tests.Run(doc)
+			if err != nil {
+				lg(ErrorType, "Failed to run tests on %s: %v", u, err)
+			} else {
+				for i := range results {
+					r := &results[i]
+					lg(testMessageType(r.Severity), "CSAF file %s fails test %s",
+						u, r.String())
+				}
+			}
+		}
+
 		// Check if file is in the right folder.
 		p.badFolders.use()
 
@@ -0,0 +1,594 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/csaf-poc/csaf_distribution/csaf"
+	"github.com/csaf-poc/csaf_distribution/util"
+	"golang.org/x/time/rate"
+)
+
+type downloader struct {
+	opts   *options
+	client util.Client
+	keys   *crypto.KeyRing
+}
+
+var errNotFound = errors.New("not found")
+
+func newDownloader(opts *options) (*downloader, error) {
+
+	var tlsConfig tls.Config
+	if opts.Insecure {
+		tlsConfig.InsecureSkipVerify = true
+	}
+
+	if opts.ClientCert != nil && opts.ClientKey != nil {
+		cert, err := tls.LoadX509KeyPair(*opts.ClientCert, *opts.ClientKey)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	hClient := http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tlsConfig,
+		},
+	}
+
+	var client util.Client
+
+	if opts.Verbose {
+		client = &util.LoggingClient{Client: &hClient}
+	} else {
+		client = &hClient
+	}
+
+	if opts.Cache {
+		client = &util.CachingClient{Client: client, Cache: &util.HTTPCache{}}
+	}
+
+	if opts.Rate != nil {
+		client = &util.LimitingClient{
+			Client:  client,
+			Limiter: rate.NewLimiter(rate.Limit(*opts.Rate), 1),
+		}
+	}
+
+	if opts.Retries > 0 {
+		client = &util.RetryingClient{
+			Client:  client,
+			Retries: opts.Retries,
+		}
+	}
+
+	return &downloader{
+		opts:   opts,
+		client: client,
+	}, nil
+}
+
+// run downloads the advisories of the given domains.
+// Failing domains are logged and skipped. An error
+// is returned if any of the domains failed.
+func (d *downloader) run(domains []string) error {
+	var failed int
+	for _, domain := range domains {
+		if err := d.download(domain); err != nil {
+			log.Printf("error: %s: %v\n", domain, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d domain(s) failed", failed, len(domains))
+	}
+	return nil
+}
+
+func (d *downloader) download(domain string) error {
+
+	lpmd := csaf.LoadProviderMetadataForDomain(
+		d.client, domain, func(format string, args ...interface{}) {
+			log.Printf(
+				"Looking for provider-metadata.json of '"+domain+"': "+format+"\n", args...)
+		})
+
+	if lpmd == nil {
+		return fmt.Errorf("no provider-metadata.json found for '%s'", domain)
+	}
+
+	base, err := url.Parse(lpmd.URL)
+	if err != nil {
+		return fmt.Errorf("invalid URL '%s': %v", lpmd.URL, err)
+	}
+
+	expr := util.NewPathEval()
+
+	if err := d.loadOpenPGPKeys(base, lpmd.Document, expr); err != nil {
+		return err
+	}
+
+	// Check if we have ROLIE feeds.
+	rolie, err := expr.Eval(
+		"$.distributions[*].rolie.feeds", lpmd.Document)
+	if err != nil {
+		return err
+	}
+
+	fs, hasRolie := rolie.([]interface{})
+	hasRolie = hasRolie && len(fs) > 0
+
+	if hasRolie {
+		return d.handleROLIE(base, rolie)
+	}
+
+	// No rolie feeds -> try to load files from index.txt
+	files, err := d.loadIndex(base)
+	if err != nil {
+		return err
+	}
+	// Without a feed the TLP label is taken from the advisories.
+	return d.downloadFiles(nil, files)
+}
+
+// loadOpenPGPKeys loads the public OpenPGP keys listed in
+// the provider metadata. Keys which cannot be loaded or
+// which do not match their fingerprints are ignored.
+func (d *downloader) loadOpenPGPKeys(
+	base *url.URL,
+	doc interface{},
+	expr *util.PathEval,
+) error {
+
+	d.keys = nil
+
+	src, err := expr.Eval("$.public_openpgp_keys", doc)
+	if err != nil {
+		// no keys.
+		log.Printf("No public OpenPGP keys found: %v.\n", err)
+		return nil
+	}
+
+	var keys []csaf.PGPKey
+	if err := util.ReMarshalJSON(&keys, src); err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		log.Println("No public OpenPGP keys found.")
+		return nil
+	}
+
+	keyring, err := crypto.NewKeyRing(nil)
+	if err != nil {
+		return err
+	}
+
+	for i := range keys {
+		key := &keys[i]
+		if key.URL == nil {
+			continue
+		}
+		up, err := url.Parse(*key.URL)
+		if err != nil {
+			log.Printf("Invalid URL '%s': %v\n", *key.URL, err)
+			continue
+		}
+
+		u := base.ResolveReference(up).String()
+
+		res, err := d.client.Get(u)
+		if err != nil {
+			log.Printf("Fetching public OpenPGP key %s failed: %v.\n", u, err)
+			continue
+		}
+		if res.StatusCode != http.StatusOK {
+			log.Printf("Fetching public OpenPGP key %s status code: %d (%s)\n",
+				u, res.StatusCode, res.Status)
+			continue
+		}
+
+		ckey, err := func() (*crypto.Key, error) {
+			defer res.Body.Close()
+			return crypto.NewKeyFromArmoredReader(res.Body)
+		}()
+
+		if err != nil {
+			log.Printf("Reading public OpenPGP key %s failed: %v\n", u, err)
+			continue
+		}
+
+		if !strings.EqualFold(ckey.GetFingerprint(), string(key.Fingerprint)) {
+			log.Printf(
+				"Fingerprint of public OpenPGP key %s does not match remotely loaded.\n", u)
+			continue
+		}
+		if err := keyring.AddKey(ckey); err != nil {
+			log.Printf("Adding public OpenPGP key %s failed: %v\n", u, err)
+			continue
+		}
+	}
+
+	if keyring.CountEntities() == 0 {
+		log.Println("No OpenPGP keys loaded.")
+		return nil
+	}
+	d.keys = keyring
+	return nil
+}
+
+func (d *downloader) handleROLIE(base *url.URL, rolie interface{}) error {
+
+	var feeds [][]csaf.Feed
+	if err := util.ReMarshalJSON(&feeds, rolie); err != nil {
+		return err
+	}
+	log.Printf("Found %d ROLIE feed(s).\n", len(feeds))
+
+	var failed int
+	fail := func(format string, args ...interface{}) {
+		log.Printf("error: "+format+"\n", args...)
+		failed++
+	}
+
+	for _, fs := range feeds {
+		for i := range fs {
+			feed := &fs[i]
+			if feed.URL == nil {
+				continue
+			}
+			up, err := url.Parse(string(*feed.URL))
+			if err != nil {
+				fail("Invalid URL %s in feed: %v", *feed.URL, err)
+				continue
+			}
+			feedURL := base.ResolveReference(up)
+			log.Printf("Feed URL: %s\n", feedURL)
+
+			fb, err := util.BaseURL(feedURL.String())
+			if err != nil {
+				fail("Invalid feed base URL '%s': %v", fb, err)
+				continue
+			}
+			feedBaseURL, err := url.Parse(fb)
+			if err != nil {
+				fail("Cannot parse feed base URL '%s': %v", fb, err)
+				continue
+			}
+
+			res, err := d.client.Get(feedURL.String())
+			if err != nil {
+				fail("Cannot get feed '%s'", err)
+				continue
+			}
+			if res.StatusCode != http.StatusOK {
+				res.Body.Close()
+				fail("Fetching %s failed. Status code %d (%s)",
+					feedURL, res.StatusCode, res.Status)
+				continue
+			}
+			rfeed, err := func() (*csaf.ROLIEFeed, error) {
+				defer res.Body.Close()
+				return csaf.LoadROLIEFeed(res.Body)
+			}()
+			if err != nil {
+				fail("Loading ROLIE feed %s failed: %v", feedURL, err)
+				continue
+			}
+
+			files := make([]string, 0, len(rfeed.Files()))
+			for _, f := range rfeed.Files() {
+				// Only the advisories itself. Hashes and signatures
+				// are derived from their URLs.
+				if strings.HasSuffix(f, ".json") {
+					if u, err := url.Parse(f); err == nil {
+						files = append(files, feedBaseURL.ResolveReference(u).String())
+					}
+				}
+			}
+
+			if err := d.downloadFiles(feed.TLPLabel, files); err != nil {
+				fail("Feed %s: %v", feedURL, err)
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d ROLIE feed(s) failed", failed)
+	}
+	return nil
+}
+
+// loadIndex loads the URLs of the advisories from the index.txt
+// next to the provider metadata.
+func (d *downloader) loadIndex(base *url.URL) ([]string, error) {
+	indexURL := base.ResolveReference(&url.URL{Path: "index.txt"})
+	resp, err := d.client.Get(indexURL.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s failed. Status code %d (%s)",
+			indexURL, resp.StatusCode, resp.Status)
+	}
+
+	var files []string
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		u, err := url.Parse(line)
+		if err != nil {
+			log.Printf("Invalid URL '%s' in %s: %v\n", line, indexURL, err)
+			continue
+		}
+		files = append(files, indexURL.ResolveReference(u).String())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// downloadFiles downloads the given files concurrently
+// with the configured number of workers. Failing files
+// are logged and skipped. An error is returned if any
+// of the files failed.
+func (d *downloader) downloadFiles(label *csaf.TLPLabel, files []string) error {
+
+	var (
+		wg     sync.WaitGroup
+		jobs   = make(chan string)
+		mu     sync.Mutex
+		failed int
+	)
+
+	for i := 0; i < d.opts.Worker; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			expr := util.NewPathEval()
+			for file := range jobs {
+				if err := d.downloadFile(expr, label, file); err != nil {
+					log.Printf("error: %s: %v\n", file, err)
+					mu.Lock()
+					failed++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for _, file := range files {
+		jobs <- file
+	}
+	close(jobs)
+	wg.Wait()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d advisories failed", failed, len(files))
+	}
+	return nil
+}
+
+// downloadFile downloads a single advisory together with its
+// hashes and signature. Advisories which cannot be verified
+// are not stored unless this is explicitly allowed.
+func (d *downloader) downloadFile(
+	expr *util.PathEval,
+	label *csaf.TLPLabel,
+	file string,
+) error {
+	u, err := url.Parse(file)
+	if err != nil {
+		return err
+	}
+
+	// Ignore not confirming filenames.
+	filename := filepath.Base(u.Path)
+	if !util.ConfirmingFileName(filename) {
+		log.Printf("Not confirming filename %q. Ignoring.\n", filename)
+		return nil
+	}
+
+	var data []byte
+	if err := d.fetch(file, func(r io.Reader) error {
+		var err error
+		data, err = io.ReadAll(r)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	var advisory interface{}
+	if err := json.Unmarshal(data, &advisory); err != nil {
+		return err
+	}
+
+	errs, err := csaf.ValidateCSAF(advisory)
+	if err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("CSAF file has %d validation errors", len(errs))
+	}
+
+	sum, err := csaf.NewAdvisorySummary(expr, advisory)
+	if err != nil {
+		return err
+	}
+
+	if !d.opts.inRange(sum.CurrentReleaseDate) {
+		return nil
+	}
+
+	// Verify the hashes.
+	sha256sum, sha512sum := sha256.Sum256(data), sha512.Sum512(data)
+	var hashes int
+	for _, h := range []struct {
+		ext string
+		sum []byte
+	}{
+		{".sha256", sha256sum[:]},
+		{".sha512", sha512sum[:]},
+	} {
+		if err := d.checkHash(file+h.ext, h.sum); err != nil {
+			if err == errNotFound {
+				log.Printf("No %s hash found for %s.\n", h.ext[1:], file)
+				continue
+			}
+			return err
+		}
+		hashes++
+	}
+	if hashes == 0 {
+		if !d.opts.AllowUnverified {
+			return errors.New("no hashes to verify")
+		}
+		log.Printf("Storing %s without verified hashes.\n", file)
+	}
+
+	// Verify the signature.
+	sig, err := d.checkSignature(file+".asc", data)
+	switch {
+	case err == errNotFound:
+		if !d.opts.AllowUnverified {
+			return errors.New("no signature found")
+		}
+		log.Printf("Storing %s without signature.\n", file)
+	case err != nil:
+		return err
+	}
+
+	var lbl string
+	switch {
+	case label != nil:
+		lbl = string(*label)
+	case sum.TLPLabel != "":
+		lbl = sum.TLPLabel
+	default:
+		lbl = "unknown"
+	}
+	lbl = strings.ToLower(lbl)
+
+	yearDir := filepath.Join(
+		d.opts.Directory, lbl, strconv.Itoa(sum.InitialReleaseDate.Year()))
+	if err := os.MkdirAll(yearDir, 0755); err != nil {
+		return err
+	}
+
+	fname := filepath.Join(yearDir, filename)
+	if err := os.WriteFile(fname, data, 0644); err != nil {
+		return err
+	}
+	if err := util.WriteHashSumToFile(fname+".sha256", filename, sha256sum[:]); err != nil {
+		return err
+	}
+	if err := util.WriteHashSumToFile(fname+".sha512", filename, sha512sum[:]); err != nil {
+		return err
+	}
+	if sig != nil {
+		if err := os.WriteFile(fname+".asc", sig, 0644); err != nil {
+			return err
+		}
+	} else if err := os.Remove(fname + ".asc"); err != nil && !os.IsNotExist(err) {
+		// Do not keep a signature of an older version.
+		return err
+	}
+	log.Printf("Downloaded %s\n", fname)
+	return nil
+}
+
+// fetch downloads the given URL and hands the body over to found.
+// It returns errNotFound if the URL is not available.
+func (d *downloader) fetch(url string, found func(io.Reader) error) error {
+	res, err := d.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return errNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching %s failed. Status code %d (%s)",
+			url, res.StatusCode, res.Status)
+	}
+	return found(res.Body)
+}
+
+// checkHash compares the remote hash at url with the given sum.
+func (d *downloader) checkHash(url string, sum []byte) error {
+	var remote []byte
+	if err := d.fetch(url, func(r io.Reader) error {
+		var err error
+		remote, err = util.HashFromReader(r)
+		return err
+	}); err != nil {
+		return err
+	}
+	if !bytes.Equal(remote, sum) {
+		return fmt.Errorf("%s does not match", url)
+	}
+	return nil
+}
+
+// checkSignature downloads the signature at url and verifies
+// data against it with the keys of the provider.
+// It returns the armored signature. Without keys this fails
+// unless unverified advisories are explicitly allowed.
+func (d *downloader) checkSignature(url string, data []byte) ([]byte, error) {
+	var armored []byte
+	if err := d.fetch(url, func(r io.Reader) error {
+		var err error
+		armored, err = io.ReadAll(r)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	sig, err := crypto.NewPGPSignatureFromArmored(string(armored))
+	if err != nil {
+		return nil, fmt.Errorf("loading signature from %s failed: %v", url, err)
+	}
+	if d.keys == nil {
+		if !d.opts.AllowUnverified {
+			return nil, errors.New("no OpenPGP keys to verify the signature")
+		}
+		log.Printf("No OpenPGP keys to verify %s.\n", url)
+		return armored, nil
+	}
+	pm := crypto.NewPlainMessage(data)
+	if err := d.keys.VerifyDetached(pm, sig, crypto.GetUnixTime()); err != nil {
+		return nil, fmt.Errorf("signature %s could not be verified: %v", url, err)
+	}
+	return armored, nil
+}
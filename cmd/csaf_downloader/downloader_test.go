@@ -0,0 +1,155 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/csaf-poc/csaf_distribution/util"
+)
+
+const testAdvisory = `{"document": {
+  "category": "csaf_base",
+  "csaf_version": "2.0",
+  "distribution": {"tlp": {"label": "WHITE"}},
+  "publisher": {"category": "vendor", "name": "ACME", "namespace": "https://example.com"},
+  "title": "Test",
+  "tracking": {
+    "id": "acme-2022-0001", "status": "final", "version": "1",
+    "initial_release_date": "2022-01-01T00:00:00Z",
+    "current_release_date": "2022-01-01T00:00:00Z",
+    "revision_history": [{"date": "2022-01-01T00:00:00Z", "number": "1", "summary": "Initial."}]
+  }
+}}`
+
+func TestDownloadFile(t *testing.T) {
+	const filename = "acme-2022-0001.json"
+	data := []byte(testAdvisory)
+	s256, s512 := sha256.Sum256(data), sha512.Sum512(data)
+
+	key, err := crypto.GenerateKey("test", "test@example.com", "x25519", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := crypto.NewKeyRing(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := keys.SignDetached(crypto.NewPlainMessage(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	armored, err := sig.GetArmored()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		hash256  = fmt.Sprintf("%x  %s\n", s256, filename)
+		hash512  = fmt.Sprintf("%x  %s\n", s512, filename)
+		mismatch = fmt.Sprintf("%x  %s\n", sha256.Sum256([]byte("other")), filename)
+	)
+
+	// files maps the served paths to their contents.
+	var files map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(rw, r)
+			return
+		}
+		fmt.Fprint(rw, content)
+	}))
+	defer srv.Close()
+
+	str := func(s string) *string { return &s }
+
+	for _, x := range []struct {
+		name            string
+		files           map[string]string
+		keys            bool
+		allowUnverified bool
+		to              *string
+		fail            string
+		stored          bool
+		signed          bool
+	}{
+		{
+			name:  "verified",
+			files: map[string]string{".sha256": hash256, ".sha512": hash512, ".asc": armored},
+			keys:  true, stored: true, signed: true,
+		}, {
+			name:  "no hashes",
+			files: map[string]string{".asc": armored},
+			keys:  true, fail: "no hashes to verify",
+		}, {
+			name:  "no signature",
+			files: map[string]string{".sha256": hash256},
+			keys:  true, fail: "no signature found",
+		}, {
+			name:  "no keys",
+			files: map[string]string{".sha256": hash256, ".asc": armored},
+			fail:  "no OpenPGP keys",
+		}, {
+			name:  "hash mismatch",
+			files: map[string]string{".sha256": mismatch, ".asc": armored},
+			keys:  true, allowUnverified: true, fail: "does not match",
+		}, {
+			name:            "unverified allowed",
+			files:           map[string]string{},
+			allowUnverified: true, stored: true,
+		}, {
+			name:            "unverified allowed without keys",
+			files:           map[string]string{".sha512": hash512, ".asc": armored},
+			allowUnverified: true, stored: true, signed: true,
+		}, {
+			name:  "out of range",
+			files: map[string]string{},
+			to:    str("2021-12-31"),
+		},
+	} {
+		files = map[string]string{"/" + filename: testAdvisory}
+		for ext, content := range x.files {
+			files["/"+filename+ext] = content
+		}
+
+		opts := &options{
+			Directory:       t.TempDir(),
+			AllowUnverified: x.allowUnverified,
+			To:              x.to,
+		}
+		if err := opts.check(); err != nil {
+			t.Fatal(err)
+		}
+		d, err := newDownloader(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if x.keys {
+			d.keys = keys
+		}
+
+		err = d.downloadFile(util.NewPathEval(), nil, srv.URL+"/"+filename)
+		switch {
+		case x.fail == "" && err != nil:
+			t.Errorf("%s: unexpected error: %v", x.name, err)
+		case x.fail != "" && (err == nil || !strings.Contains(err.Error(), x.fail)):
+			t.Errorf("%s: expected error %q, got %v", x.name, x.fail, err)
+		}
+
+		fname := filepath.Join(opts.Directory, "white", "2022", filename)
+		if _, err := os.Stat(fname); (err == nil) != x.stored {
+			t.Errorf("%s: expected stored %t, got %v", x.name, x.stored, err)
+		}
+		if _, err := os.Stat(fname + ".asc"); (err == nil) != x.signed {
+			t.Errorf("%s: expected signature stored %t, got %v", x.name, x.signed, err)
+		}
+	}
+}
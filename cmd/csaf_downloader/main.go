@@ -0,0 +1,124 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/csaf-poc/csaf_distribution/util"
+	"github.com/jessevdk/go-flags"
+)
+
+const (
+	defaultWorkers   = 2
+	dateRangeFormat  = "2006-01-02"
+	defaultDirectory = "."
+)
+
+type options struct {
+	Directory       string   `short:"d" long:"directory" description:"Directory to store the downloaded files in" value-name:"DIR" default:"."`
+	Insecure        bool     `long:"insecure" description:"Do not check TLS certificates from provider"`
+	ClientCert      *string  `long:"client-cert" description:"TLS client certificate file (PEM encoded data)" value-name:"CERT-FILE"`
+	ClientKey       *string  `long:"client-key" description:"TLS client private key file (PEM encoded data)" value-name:"KEY-FILE"`
+	Version         bool     `long:"version" description:"Display version of the binary"`
+	Verbose         bool     `long:"verbose" short:"v" description:"Verbose output"`
+	Rate            *float64 `long:"rate" short:"r" description:"The average upper limit of https operations per second"`
+	Retries         int      `long:"retries" description:"Number of retries of requests failed because of network or transient server errors" value-name:"NUM"`
+	Cache           bool     `long:"cache" description:"Send conditional requests for responses with an ETag or Last-Modified header"`
+	Worker          int      `long:"worker" short:"w" description:"Number of concurrent downloads" value-name:"NUM" default:"2"`
+	From            *string  `long:"from" description:"Only download advisories released on or after this date" value-name:"YYYY-MM-DD"`
+	To              *string  `long:"to" description:"Only download advisories released on or before this date" value-name:"YYYY-MM-DD"`
+	AllowUnverified bool     `long:"allow-unverified" description:"Store advisories without hashes, signatures or OpenPGP keys to verify them"`
+
+	from, to time.Time
+}
+
+// inRange checks if the given time is in the configured date range.
+func (o *options) inRange(t time.Time) bool {
+	if !o.from.IsZero() && t.Before(o.from) {
+		return false
+	}
+	// The upper bound is inclusive for the whole day.
+	if !o.to.IsZero() && !t.Before(o.to.AddDate(0, 0, 1)) {
+		return false
+	}
+	return true
+}
+
+// check validates the options and prepares the date range.
+func (o *options) check() error {
+	if o.ClientCert != nil && o.ClientKey == nil || o.ClientCert == nil && o.ClientKey != nil {
+		return fmt.Errorf("both client-key and client-cert options must be set for the authentication")
+	}
+	if o.Worker <= 0 {
+		o.Worker = defaultWorkers
+	}
+	if o.Directory == "" {
+		o.Directory = defaultDirectory
+	}
+	parse := func(s *string, dst *time.Time) error {
+		if s == nil {
+			return nil
+		}
+		t, err := time.Parse(dateRangeFormat, *s)
+		if err != nil {
+			return fmt.Errorf("invalid date '%s': %v", *s, err)
+		}
+		*dst = t
+		return nil
+	}
+	if err := parse(o.From, &o.from); err != nil {
+		return err
+	}
+	if err := parse(o.To, &o.to); err != nil {
+		return err
+	}
+	if !o.from.IsZero() && !o.to.IsZero() && o.to.Before(o.from) {
+		return fmt.Errorf("date range is empty: %s is after %s", *o.From, *o.To)
+	}
+	return nil
+}
+
+func errCheck(err error) {
+	if err != nil {
+		if e, ok := err.(*flags.Error); ok && e.Type == flags.ErrHelp {
+			os.Exit(0)
+		}
+		log.Fatalf("error: %v\n", err)
+	}
+}
+
+func main() {
+	opts := new(options)
+
+	parser := flags.NewParser(opts, flags.Default)
+	parser.Usage = "[OPTIONS] domain..."
+	domains, err := parser.Parse()
+	errCheck(err)
+
+	if opts.Version {
+		fmt.Println(util.SemVersion)
+		return
+	}
+
+	if len(domains) == 0 {
+		log.Println("No domains given.")
+		return
+	}
+
+	errCheck(opts.check())
+
+	d, err := newDownloader(opts)
+	errCheck(err)
+
+	errCheck(d.run(domains))
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOptionsCheck(t *testing.T) {
+	str := func(s string) *string { return &s }
+
+	for _, x := range []struct {
+		name     string
+		from, to *string
+		fail     bool
+	}{
+		{"no range", nil, nil, false},
+		{"from only", str("2022-01-01"), nil, false},
+		{"to only", nil, str("2022-01-01"), false},
+		{"single day", str("2022-01-01"), str("2022-01-01"), false},
+		{"range", str("2022-01-01"), str("2022-12-31"), false},
+		{"empty range", str("2022-02-01"), str("2022-01-31"), true},
+		{"invalid from", str("01.01.2022"), nil, true},
+		{"invalid to", nil, str("2022-13-01"), true},
+	} {
+		o := options{From: x.from, To: x.to}
+		if err := o.check(); (err != nil) != x.fail {
+			t.Errorf("%s: expected failure %t, got %v", x.name, x.fail, err)
+		}
+	}
+
+	o := options{ClientCert: str("cert.pem")}
+	if err := o.check(); err == nil {
+		t.Error("client cert without key: expected failure")
+	}
+
+	o = options{}
+	if err := o.check(); err != nil {
+		t.Fatal(err)
+	}
+	if o.Worker != defaultWorkers || o.Directory != defaultDirectory {
+		t.Errorf("expected defaults, got %d workers and directory %q",
+			o.Worker, o.Directory)
+	}
+}
+
+func TestOptionsInRange(t *testing.T) {
+	str := func(s string) *string { return &s }
+	at := func(s string) time.Time {
+		d, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	for _, x := range []struct {
+		name     string
+		from, to *string
+		date     string
+		want     bool
+	}{
+		{"no range", nil, nil, "2022-01-01T00:00:00Z", true},
+		{"before from", str("2022-01-01"), nil, "2021-12-31T23:59:59Z", false},
+		{"at from", str("2022-01-01"), nil, "2022-01-01T00:00:00Z", true},
+		{"start of to", nil, str("2022-01-31"), "2022-01-31T00:00:00Z", true},
+		{"end of to", nil, str("2022-01-31"), "2022-01-31T23:59:59Z", true},
+		{"after to", nil, str("2022-01-31"), "2022-02-01T00:00:00Z", false},
+		{"single day", str("2022-01-31"), str("2022-01-31"), "2022-01-31T12:00:00Z", true},
+		{"before single day", str("2022-01-31"), str("2022-01-31"), "2022-01-30T12:00:00Z", false},
+		{"after single day", str("2022-01-31"), str("2022-01-31"), "2022-02-01T12:00:00Z", false},
+	} {
+		o := options{From: x.from, To: x.to}
+		if err := o.check(); err != nil {
+			t.Fatalf("%s: %v", x.name, err)
+		}
+		if got := o.inRange(at(x.date)); got != x.want {
+			t.Errorf("%s: %s: expected %t, got %t", x.name, x.date, x.want, got)
+		}
+	}
+}
@@ -50,42 +50,31 @@ func (c *controller) loadCSAF(r *http.Request) (string, []byte, error) {
 	return util.CleanFileName(handler.Filename), buf.Bytes(), nil
 }
 
-func (c *controller) handleSignature(
-	r *http.Request,
-	data []byte,
-) (string, *crypto.Key, error) {
+// signer signs the uploaded CSAF documents or verifies
+// their signatures if they are uploaded, too.
+type signer struct {
+	key    *crypto.Key
+	ring   *crypto.KeyRing
+	verify bool
+}
 
-	// Was the signature given via request?
-	if c.cfg.UploadSignature {
-		sigText := r.FormValue("signature")
-		if sigText == "" {
-			return "", nil, errors.New("missing signature in request")
-		}
-
-		pgpSig, err := crypto.NewPGPSignatureFromArmored(sigText)
-		if err != nil {
-			return "", nil, err
-		}
+// newSigner loads the public key to verify the uploaded signatures
+// or the private key to sign the documents ourself. The latter is
+// unlocked with the passphrase from the request.
+func (c *controller) newSigner(r *http.Request) (*signer, error) {
 
+	// Is the signature given via request?
+	if c.cfg.UploadSignature {
 		// Use the public key
 		key, err := loadCryptoKeyFromFile(c.cfg.OpenPGPPublicKey)
 		if err != nil {
-			return "", nil, err
+			return nil, err
 		}
-
-		signRing, err := crypto.NewKeyRing(key)
+		ring, err := crypto.NewKeyRing(key)
 		if err != nil {
-			return "", nil, err
-		}
-
-		if err := signRing.VerifyDetached(
-			crypto.NewPlainMessage(data),
-			pgpSig, crypto.GetUnixTime(),
-		); err != nil {
-			return "", nil, err
+			return nil, err
 		}
-
-		return sigText, key, nil
+		return &signer{key: key, ring: ring, verify: true}, nil
 	}
 
 	// Sign ourself
@@ -93,31 +82,80 @@ func (c *controller) handleSignature(
 	// Use the private key
 	key, err := loadCryptoKeyFromFile(c.cfg.OpenPGPPrivateKey)
 	if err != nil {
-		return "", nil, err
+		return nil, err
 	}
 
 	if passwd := r.FormValue("passphrase"); !c.cfg.NoPassphrase && passwd != "" {
 		if key, err = key.Unlock([]byte(passwd)); err != nil {
-			return "", nil, err
+			return nil, err
 		}
 	}
 
-	signRing, err := crypto.NewKeyRing(key)
+	ring, err := crypto.NewKeyRing(key)
 	if err != nil {
-		return "", nil, err
+		return nil, err
+	}
+	return &signer{key: key, ring: ring}, nil
+}
+
+// sign returns the armored signature of a document. If signatures
+// are uploaded the given one is verified and returned instead.
+func (s *signer) sign(data []byte, sigText string) (string, error) {
+
+	if s.verify {
+		if sigText == "" {
+			return "", errors.New("missing signature in request")
+		}
+
+		pgpSig, err := crypto.NewPGPSignatureFromArmored(sigText)
+		if err != nil {
+			return "", err
+		}
+
+		if err := s.ring.VerifyDetached(
+			crypto.NewPlainMessage(data),
+			pgpSig, crypto.GetUnixTime(),
+		); err != nil {
+			return "", err
+		}
+
+		return sigText, nil
 	}
 
-	sig, err := signRing.SignDetached(crypto.NewPlainMessage(data))
+	sig, err := s.ring.SignDetached(crypto.NewPlainMessage(data))
+	if err != nil {
+		return "", err
+	}
+
+	return sig.GetArmored()
+}
+
+func (c *controller) handleSignature(
+	r *http.Request,
+	data []byte,
+) (string, *crypto.Key, error) {
+
+	// Check for the signature first to spare loading the key.
+	if c.cfg.UploadSignature && r.FormValue("signature") == "" {
+		return "", nil, errors.New("missing signature in request")
+	}
+
+	s, err := c.newSigner(r)
 	if err != nil {
 		return "", nil, err
 	}
 
-	armored, err := sig.GetArmored()
-	return armored, key, err
+	armored, err := s.sign(data, r.FormValue("signature"))
+	return armored, s.key, err
 }
 
 func (c *controller) tlpParam(r *http.Request) (tlp, error) {
-	t := tlp(strings.ToLower(r.FormValue("tlp")))
+	return c.checkTLP(r.FormValue("tlp"))
+}
+
+// checkTLP checks if the given TLP is one of the configured ones.
+func (c *controller) checkTLP(s string) (tlp, error) {
+	t := tlp(strings.ToLower(s))
 	for _, x := range c.cfg.TLPs {
 		if x == t {
 			return t, nil
@@ -126,10 +164,23 @@ func (c *controller) tlpParam(r *http.Request) (tlp, error) {
 	return "", fmt.Errorf("unsupported TLP type '%s'", t)
 }
 
+// loadFeed loads a ROLIE feed. It returns nil if it does not exist.
+func loadFeed(feed string) (*csaf.ROLIEFeed, error) {
+	f, err := os.Open(feed)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	return csaf.LoadROLIEFeed(f)
+}
+
 // create calls the "ensureFolders" functions to create the directories and files.
 // It returns a struct by success, otherwise an error.
 func (c *controller) create(*http.Request) (interface{}, error) {
-	if err := ensureFolders(c.cfg); err != nil {
+	if err := c.cfg.lock(func() error { return ensureFolders(c.cfg) }); err != nil {
 		return nil, err
 	}
 	return &struct {
@@ -140,193 +191,280 @@ func (c *controller) create(*http.Request) (interface{}, error) {
 	}, nil
 }
 
-func (c *controller) upload(r *http.Request) (interface{}, error) {
+// upload is a CSAF document which passed the checks
+// and is ready to be published.
+type upload struct {
+	name     string
+	data     []byte
+	summary  *csaf.AdvisorySummary
+	tlp      tlp
+	armored  string
+	warnings []string
+}
 
-	newCSAF, data, err := c.loadCSAF(r)
-	if err != nil {
-		return nil, err
-	}
+// prepare validates an uploaded CSAF document and runs the tests of
+// the standard on it. On failure the stage it failed in is returned
+// as reason along with the error.
+func (c *controller) prepare(name string, data []byte) (*upload, string, error) {
 
 	var content interface{}
 	if err := json.Unmarshal(data, &content); err != nil {
-		return nil, err
+		return nil, "request", err
 	}
 
 	// Validate againt JSON schema.
 	if !c.cfg.NoValidation {
 		validationErrors, err := csaf.ValidateCSAF(content)
 		if err != nil {
-			return nil, err
+			return nil, "schema", err
 		}
 
 		if len(validationErrors) > 0 {
-			return nil, multiError(validationErrors)
+			return nil, "schema", multiError(validationErrors)
 		}
 	}
 
-	ex, err := csaf.NewAdvisorySummary(util.NewPathEval(), content)
-	if err != nil {
-		return nil, err
+	var warnings []string
+
+	// Run the tests of the CSAF standard.
+	if tc := c.cfg.tests(); tc.Enabled() {
+		results, err := tc.Run(content)
+		if err != nil {
+			return nil, "tests", err
+		}
+		if errs := results.BySeverity(csaf.TestError); len(errs) > 0 {
+			return nil, "tests", multiError(errs.Strings())
+		}
+		warnings = append(warnings, results.BySeverity(csaf.TestWarning).Strings()...)
+		for _, info := range results.BySeverity(csaf.TestInfo).Strings() {
+			warnings = append(warnings, "info: "+info)
+		}
 	}
 
-	t, err := c.tlpParam(r)
+	ex, err := csaf.NewAdvisorySummary(util.NewPathEval(), content)
 	if err != nil {
-		return nil, err
+		return nil, "summary", err
 	}
 
+	return &upload{
+		name:     name,
+		data:     data,
+		summary:  ex,
+		warnings: warnings,
+	}, "", nil
+}
+
+// resolveTLP sets the TLP of the upload to the requested one.
+// With "csaf" it is taken from the document.
+func (u *upload) resolveTLP(t tlp) error {
 	// Extract real TLP from document.
 	if t == tlpCSAF {
-		if t = tlp(strings.ToLower(ex.TLPLabel)); !t.valid() || t == tlpCSAF {
-			return nil, fmt.Errorf(
+		if t = tlp(strings.ToLower(u.summary.TLPLabel)); !t.valid() || t == tlpCSAF {
+			return fmt.Errorf(
 				"valid TLP label missing in document (found '%s')", t)
 		}
 	}
+	u.tlp = t
+	return nil
+}
 
-	armored, key, err := c.handleSignature(r, data)
+// publish stores the uploads of a TLP in the given folder and adds them
+// to the ROLIE feed and the indices. The feed and the indices are only
+// written once for all of them.
+func (c *controller) publish(
+	folder string,
+	pmd *csaf.ProviderMetadata,
+	t tlp,
+	uploads []*upload,
+	key *crypto.Key,
+) error {
+
+	// Load the feed
+	ts := string(t)
+	feedName := "csaf-feed-tlp-" + ts + ".json"
+
+	feed := filepath.Join(folder, feedName)
+	rolie, err := loadFeed(feed)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var warnings []string
-	warn := func(msg string) { warnings = append(warnings, msg) }
+	feedURL := csaf.JSONURL(
+		c.cfg.CanonicalURLPrefix +
+			"/.well-known/csaf/" + ts + "/" + feedName)
+
+	tlpLabel := csaf.TLPLabel(strings.ToUpper(ts))
+
+	// Create new if does not exists.
+	if rolie == nil {
+		rolie = &csaf.ROLIEFeed{
+			Feed: csaf.FeedData{
+				ID:    "csaf-feed-tlp-" + ts,
+				Title: "CSAF feed (TLP:" + string(tlpLabel) + ")",
+				Link: []csaf.Link{{
+					Rel:  "self",
+					HRef: string(feedURL),
+				}},
+				Category: []csaf.ROLIECategory{{
+					Scheme: "urn:ietf:params:rolie:category:information-type",
+					Term:   "csaf",
+				}},
+			},
+		}
+	}
 
-	if err := doTransaction(
-		c.cfg, t,
-		func(folder string, pmd *csaf.ProviderMetadata) error {
+	rolie.Feed.Updated = csaf.TimeStamp(time.Now().UTC())
+
+	updates := make([]change, 0, len(uploads))
+	paths := make([]string, 0, len(uploads))
+
+	for _, u := range uploads {
+		ex := u.summary
+
+		year := strconv.Itoa(ex.InitialReleaseDate.Year())
+
+		csafURL := c.cfg.CanonicalURLPrefix +
+			"/.well-known/csaf/" + ts + "/" + year + "/" + u.name
 
-			// Load the feed
-			ts := string(t)
-			feedName := "csaf-feed-tlp-" + ts + ".json"
-
-			feed := filepath.Join(folder, feedName)
-			var rolie *csaf.ROLIEFeed
-			if err := func() error {
-				f, err := os.Open(feed)
-				if err != nil {
-					if os.IsNotExist(err) {
-						return nil
-					}
+		e := rolie.EntryByID(ex.ID)
+		if e == nil {
+			e = &csaf.Entry{ID: ex.ID}
+			rolie.Feed.Entry = append(rolie.Feed.Entry, e)
+		}
+
+		e.Titel = ex.Title
+		e.Published = csaf.TimeStamp(ex.InitialReleaseDate)
+		e.Updated = csaf.TimeStamp(ex.CurrentReleaseDate)
+		e.Link = []csaf.Link{{
+			Rel:  "self",
+			HRef: csafURL,
+		}}
+		e.Format = csaf.Format{
+			Schema:  "https://docs.oasis-open.org/csaf/csaf/v2.0/csaf_json_schema.json",
+			Version: "2.0",
+		}
+		e.Content = csaf.Content{
+			Type: "application/json",
+			Src:  csafURL,
+		}
+		if ex.Summary != "" {
+			e.Summary = &csaf.Summary{Content: ex.Summary}
+		} else {
+			e.Summary = nil
+		}
+
+		// Create yearly subfolder
+
+		subDir := filepath.Join(folder, year)
+
+		// Create folder if it does not exists.
+		if _, err := os.Stat(subDir); err != nil {
+			if os.IsNotExist(err) {
+				if err := os.Mkdir(subDir, 0755); err != nil {
 					return err
 				}
-				defer f.Close()
-				rolie, err = csaf.LoadROLIEFeed(f)
-				return err
-			}(); err != nil {
+			} else {
 				return err
 			}
+		}
 
-			feedURL := csaf.JSONURL(
-				c.cfg.CanonicalURLPrefix +
-					"/.well-known/csaf/" + ts + "/" + feedName)
-
-			tlpLabel := csaf.TLPLabel(strings.ToUpper(ts))
-
-			// Create new if does not exists.
-			if rolie == nil {
-				rolie = &csaf.ROLIEFeed{
-					Feed: csaf.FeedData{
-						ID:    "csaf-feed-tlp-" + ts,
-						Title: "CSAF feed (TLP:" + string(tlpLabel) + ")",
-						Link: []csaf.Link{{
-							Rel:  "self",
-							HRef: string(feedURL),
-						}},
-						Category: []csaf.ROLIECategory{{
-							Scheme: "urn:ietf:params:rolie:category:information-type",
-							Term:   "csaf",
-						}},
-					},
-				}
-			}
+		fname := filepath.Join(subDir, u.name)
 
-			rolie.Feed.Updated = csaf.TimeStamp(time.Now().UTC())
+		if err := writeHashedFile(fname, u.name, u.data, u.armored); err != nil {
+			return err
+		}
 
-			year := strconv.Itoa(ex.InitialReleaseDate.Year())
+		path := filepath.Join(year, u.name)
+		updates = append(updates, change{ex.CurrentReleaseDate, path})
+		paths = append(paths, path)
+
+		// Take over publisher
+		switch {
+		case pmd.Publisher == nil:
+			u.warnings = append(u.warnings,
+				"Publisher in provider metadata is not initialized. Forgot to configure?")
+			if c.cfg.DynamicProviderMetaData {
+				u.warnings = append(u.warnings, "Taking publisher from CSAF")
+				pmd.Publisher = ex.Publisher
+			}
+		case !pmd.Publisher.Equals(ex.Publisher):
+			u.warnings = append(u.warnings,
+				"Publishers in provider metadata and CSAF do not match.")
+		}
+	}
 
-			csafURL := c.cfg.CanonicalURLPrefix +
-				"/.well-known/csaf/" + ts + "/" + year + "/" + newCSAF
+	// Sort by descending updated order.
+	rolie.SortEntriesByUpdated()
 
-			e := rolie.EntryByID(ex.ID)
-			if e == nil {
-				e = &csaf.Entry{ID: ex.ID}
-				rolie.Feed.Entry = append(rolie.Feed.Entry, e)
-			}
+	// Store the feed. Remove first to break hard link.
+	if err := unlink(feed); err != nil {
+		return err
+	}
+	if err := util.WriteToFile(feed, rolie); err != nil {
+		return err
+	}
 
-			e.Titel = ex.Title
-			e.Published = csaf.TimeStamp(ex.InitialReleaseDate)
-			e.Updated = csaf.TimeStamp(ex.CurrentReleaseDate)
-			e.Link = []csaf.Link{{
-				Rel:  "self",
-				HRef: csafURL,
-			}}
-			e.Format = csaf.Format{
-				Schema:  "https://docs.oasis-open.org/csaf/csaf/v2.0/csaf_json_schema.json",
-				Version: "2.0",
-			}
-			e.Content = csaf.Content{
-				Type: "application/json",
-				Src:  csafURL,
-			}
-			if ex.Summary != "" {
-				e.Summary = &csaf.Summary{Content: ex.Summary}
-			} else {
-				e.Summary = nil
-			}
+	if err := updateIndices(folder, updates...); err != nil {
+		return err
+	}
 
-			// Sort by descending updated order.
-			rolie.SortEntriesByUpdated()
+	// Withdrawn advisories are published again.
+	if err := removeWithdrawn(folder, paths...); err != nil {
+		return err
+	}
 
-			// Store the feed
-			if err := util.WriteToFile(feed, rolie); err != nil {
-				return err
-			}
+	fingerprint := strings.ToUpper(key.GetFingerprint())
+	pmd.SetPGP(fingerprint, c.cfg.openPGPPublicURL(fingerprint))
 
-			// Create yearly subfolder
+	return nil
+}
 
-			subDir := filepath.Join(folder, year)
+func (c *controller) upload(r *http.Request) (_ interface{}, err error) {
 
-			// Create folder if it does not exists.
-			if _, err := os.Stat(subDir); err != nil {
-				if os.IsNotExist(err) {
-					if err := os.Mkdir(subDir, 0755); err != nil {
-						return err
-					}
-				} else {
-					return err
-				}
-			}
+	// The stage of the upload, used as reason if it fails.
+	reason := "request"
+	var t tlp
+	var took time.Duration
+	defer func() { c.recordUpload(t, reason, took, err) }()
 
-			fname := filepath.Join(subDir, newCSAF)
+	newCSAF, data, err := c.loadCSAF(r)
+	if err != nil {
+		return nil, err
+	}
 
-			if err := writeHashedFile(fname, newCSAF, data, armored); err != nil {
-				return err
-			}
+	u, reason, err := c.prepare(newCSAF, data)
+	if err != nil {
+		return nil, err
+	}
 
-			if err := updateIndices(
-				folder, filepath.Join(year, newCSAF),
-				ex.CurrentReleaseDate,
-			); err != nil {
-				return err
-			}
+	reason = "tlp"
+	requested, err := c.tlpParam(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.resolveTLP(requested); err != nil {
+		return nil, err
+	}
+	t = u.tlp
 
-			// Take over publisher
-			switch {
-			case pmd.Publisher == nil:
-				warn("Publisher in provider metadata is not initialized. Forgot to configure?")
-				if c.cfg.DynamicProviderMetaData {
-					warn("Taking publisher from CSAF")
-					pmd.Publisher = ex.Publisher
-				}
-			case !pmd.Publisher.Equals(ex.Publisher):
-				warn("Publishers in provider metadata and CSAF do not match.")
-			}
+	reason = "signature"
+	armored, key, err := c.handleSignature(r, data)
+	if err != nil {
+		return nil, err
+	}
+	u.armored = armored
 
-			fingerprint := strings.ToUpper(key.GetFingerprint())
-			pmd.SetPGP(fingerprint, c.cfg.openPGPPublicURL(fingerprint))
+	reason = "transaction"
+	start := time.Now()
 
-			return nil
+	err = doTransaction(
+		c.cfg, t,
+		func(folder string, pmd *csaf.ProviderMetadata) error {
+			return c.publish(folder, pmd, t, []*upload{u}, key)
 		},
-	); err != nil {
+	)
+	took = time.Since(start)
+	if err != nil {
 		return nil, err
 	}
 
@@ -337,8 +475,8 @@ func (c *controller) upload(r *http.Request) (interface{}, error) {
 		Error       error    `json:"-"`
 	}{
 		Name:        newCSAF,
-		ReleaseDate: ex.CurrentReleaseDate.Format(dateFormat),
-		Warnings:    warnings,
+		ReleaseDate: u.summary.CurrentReleaseDate.Format(dateFormat),
+		Warnings:    u.warnings,
 	}
 
 	return &result, nil
@@ -0,0 +1,402 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/csaf-poc/csaf_distribution/csaf"
+	"github.com/csaf-poc/csaf_distribution/util"
+)
+
+// batchMaxMemory is the part of a batch request kept in memory.
+// The rest is stored in temporary files.
+const batchMaxMemory = 32 << 20
+
+// batchOverhead is the room left for the multipart encoding
+// of a batch request above the batch limit.
+const batchOverhead = 1 << 20
+
+// errTooLarge is the error of a file of a batch exceeding the upload limit.
+var errTooLarge = errors.New("file exceeds the upload limit")
+
+// batchFile is a CSAF document of a batch upload.
+type batchFile struct {
+	name      string
+	data      []byte
+	signature string
+	err       error
+}
+
+// batchResult is the outcome of the upload of a single
+// CSAF document of a batch.
+type batchResult struct {
+	Name        string     `json:"name"`
+	TLP         string     `json:"tlp,omitempty"`
+	ReleaseDate string     `json:"release_date,omitempty"`
+	Warnings    []string   `json:"warnings,omitempty"`
+	Errors      multiError `json:"errors,omitempty"`
+
+	reason string
+	upload *upload
+}
+
+// batchLoader collects the CSAF documents and
+// the signatures of a batch upload.
+type batchLoader struct {
+	cfg        *config
+	files      []*batchFile
+	names      map[string]bool
+	signatures map[string]string
+	sigErrors  map[string]error
+	count      int
+	left       int64 // bytes left below the batch limit, negative if unlimited
+}
+
+// read reads a file of a batch. Files larger than the upload limit
+// are rejected with errTooLarge. It fails if the files of the batch
+// exceed the batch limit together.
+func (bl *batchLoader) read(r io.Reader) ([]byte, error) {
+	var uploadLimit int64
+	if bl.cfg.UploadLimit != nil {
+		uploadLimit = *bl.cfg.UploadLimit
+	}
+	max := bl.left
+	if uploadLimit > 0 && (max < 0 || uploadLimit < max) {
+		max = uploadLimit
+	}
+	if max >= 0 {
+		// Read one byte more to detect an exceeded limit.
+		r = io.LimitReader(r, max+1)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	size := int64(len(data))
+	if uploadLimit > 0 && size > uploadLimit {
+		return nil, errTooLarge
+	}
+	if bl.left >= 0 {
+		if size > bl.left {
+			return nil, fmt.Errorf("batch exceeds the limit of %d bytes", *bl.cfg.BatchLimit)
+		}
+		bl.left -= size
+	}
+	return data, nil
+}
+
+// add adds a file of a batch. Signatures are recognized by their
+// ".asc" suffix, other files than CSAF documents are ignored
+// if they come from an archive.
+func (bl *batchLoader) add(name string, r io.Reader, archived bool) error {
+	name = path.Base(name)
+	isSig := strings.HasSuffix(name, ".asc")
+	if archived && !isSig && !strings.HasSuffix(name, ".json") {
+		return nil
+	}
+	bl.count++
+	if max := bl.cfg.BatchMaxFiles; max != nil && *max > 0 && bl.count > *max {
+		return fmt.Errorf("batch has more than %d files", *max)
+	}
+	data, err := bl.read(r)
+	if err != nil && err != errTooLarge {
+		return err
+	}
+	if isSig {
+		name = strings.TrimSuffix(name, ".asc")
+		if err != nil {
+			bl.sigErrors[name] = errors.New("signature exceeds the upload limit")
+			return nil
+		}
+		bl.signatures[name] = string(data)
+		return nil
+	}
+	bf := &batchFile{name: name, data: data}
+	switch {
+	case err != nil:
+		bf.err = err
+	case !util.ConfirmingFileName(name):
+		bf.err = errors.New("given csaf filename is not confirming")
+	case bl.names[name]:
+		bf.err = errors.New("duplicate csaf filename in batch")
+	}
+	bl.names[name] = true
+	bl.files = append(bl.files, bf)
+	return nil
+}
+
+// addParts adds the files of the parts of a multipart form.
+// If signatures is true the parts have to be signatures named
+// after their CSAF documents with an additional ".asc" suffix.
+func (bl *batchLoader) addParts(headers []*multipart.FileHeader, signatures bool) error {
+	for _, h := range headers {
+		if signatures && !strings.HasSuffix(path.Base(h.Filename), ".asc") {
+			return fmt.Errorf(
+				"signature '%s' is not named after its csaf file with a '.asc' suffix",
+				h.Filename)
+		}
+		if err := func() error {
+			f, err := h.Open()
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return bl.add(h.Filename, f, false)
+		}(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addZip adds the files of a zip archive.
+func (bl *batchLoader) addZip(r io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if err := func() error {
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			defer rc.Close()
+			return bl.add(f.Name, rc, true)
+		}(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addTar adds the files of a tar archive.
+func (bl *batchLoader) addTar(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := bl.add(hdr.Name, tr, true); err != nil {
+			return err
+		}
+	}
+}
+
+// addArchive adds the files of a zip or an optionally
+// gzip compressed tar archive.
+func (bl *batchLoader) addArchive(h *multipart.FileHeader) error {
+	f, err := h.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	name := strings.ToLower(h.Filename)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return bl.addZip(f, h.Size)
+	case strings.HasSuffix(name, ".tar"):
+		return bl.addTar(f)
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		return bl.addTar(gr)
+	}
+	return errors.New("unsupported archive format")
+}
+
+// loadBatch loads the CSAF documents and their signatures from the
+// "csaf" and "signature" parts and the archives in the "archive"
+// parts of a request.
+func (c *controller) loadBatch(r *http.Request) ([]*batchFile, error) {
+	left := int64(-1)
+	if c.cfg.BatchLimit != nil && *c.cfg.BatchLimit > 0 {
+		left = *c.cfg.BatchLimit
+		// The request itself must not exceed the batch limit, too.
+		r.Body = http.MaxBytesReader(nil, r.Body, left+batchOverhead)
+	}
+	if err := r.ParseMultipartForm(batchMaxMemory); err != nil {
+		return nil, err
+	}
+	bl := batchLoader{
+		cfg:        c.cfg,
+		names:      map[string]bool{},
+		signatures: map[string]string{},
+		sigErrors:  map[string]error{},
+		left:       left,
+	}
+	form := r.MultipartForm.File
+	if err := bl.addParts(form["csaf"], false); err != nil {
+		return nil, err
+	}
+	if err := bl.addParts(form["signature"], true); err != nil {
+		return nil, err
+	}
+	for _, h := range form["archive"] {
+		if err := bl.addArchive(h); err != nil {
+			return nil, fmt.Errorf("%s: %v", h.Filename, err)
+		}
+	}
+	if len(bl.files) == 0 {
+		return nil, errors.New("no csaf files in request")
+	}
+	for _, f := range bl.files {
+		f.signature = bl.signatures[f.name]
+		if err := bl.sigErrors[f.name]; err != nil && f.err == nil {
+			f.err = err
+		}
+	}
+	return bl.files, nil
+}
+
+// batchUpload uploads many CSAF documents at once. All of them are
+// checked first. The accepted ones are published with a single
+// transaction per TLP. The outcome is reported for each document.
+func (c *controller) batchUpload(r *http.Request) (interface{}, error) {
+
+	files, err := c.loadBatch(r)
+	if err != nil {
+		return nil, err
+	}
+
+	requested, err := c.tlpParam(r)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*batchResult, len(files))
+
+	fail := func(res *batchResult, reason string, err error) {
+		res.reason, res.Errors, res.upload = reason, asMultiError(err), nil
+	}
+
+	var accepted int
+	for i, f := range files {
+		res := &batchResult{Name: f.name}
+		results[i] = res
+		if f.err != nil {
+			fail(res, "request", f.err)
+			continue
+		}
+		u, reason, err := c.prepare(f.name, f.data)
+		if err != nil {
+			fail(res, reason, err)
+			continue
+		}
+		if err := u.resolveTLP(requested); err != nil {
+			fail(res, "tlp", err)
+			continue
+		}
+		res.upload = u
+		accepted++
+	}
+
+	var tooks []time.Duration
+
+	if accepted > 0 {
+		s, err := c.newSigner(r)
+		if err != nil {
+			return nil, err
+		}
+
+		byTLP := map[tlp][]*batchResult{}
+		for i, res := range results {
+			if res.upload == nil {
+				continue
+			}
+			if res.upload.armored, err = s.sign(res.upload.data, files[i].signature); err != nil {
+				fail(res, "signature", err)
+				continue
+			}
+			byTLP[res.upload.tlp] = append(byTLP[res.upload.tlp], res)
+		}
+
+		tlps := make([]tlp, 0, len(byTLP))
+		for t := range byTLP {
+			tlps = append(tlps, t)
+		}
+		sort.Slice(tlps, func(i, j int) bool { return tlps[i] < tlps[j] })
+
+		for _, t := range tlps {
+			batch := byTLP[t]
+			uploads := make([]*upload, len(batch))
+			for i, res := range batch {
+				uploads[i] = res.upload
+			}
+
+			start := time.Now()
+			err := doTransaction(
+				c.cfg, t,
+				func(folder string, pmd *csaf.ProviderMetadata) error {
+					return c.publish(folder, pmd, t, uploads, s.key)
+				},
+			)
+			// Let the client try again if nothing is published yet.
+			if errors.Is(err, errBusy) && len(tooks) == 0 {
+				return nil, err
+			}
+			tooks = append(tooks, time.Since(start))
+
+			for _, res := range batch {
+				if err != nil {
+					fail(res, "transaction", err)
+					continue
+				}
+				res.TLP = string(t)
+				res.ReleaseDate = res.upload.summary.CurrentReleaseDate.Format(dateFormat)
+				res.Warnings = res.upload.warnings
+			}
+		}
+	}
+
+	c.updateMetrics(func(m *util.Metrics) {
+		for _, took := range tooks {
+			recordTransaction(m, took)
+		}
+		for _, res := range results {
+			if res.Errors != nil {
+				recordOutcome(m, "", res.reason, res.Errors)
+			} else {
+				recordOutcome(m, tlp(res.TLP), "", nil)
+			}
+		}
+	})
+
+	return &struct {
+		Results []*batchResult `json:"results"`
+	}{
+		Results: results,
+	}, nil
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/csaf-poc/csaf_distribution/csaf"
+)
+
+// batchPart is a part of a batch request.
+type batchPart struct {
+	field, name string
+	data        []byte
+}
+
+// batchRequest builds a multipart batch request with the given parts.
+func batchRequest(t *testing.T, parts []batchPart, fields ...string) *http.Request {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range parts {
+		w, err := mw.CreateFormFile(part.field, part.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(part.data)
+	}
+	for i := 0; i+1 < len(fields); i += 2 {
+		mw.WriteField(fields[i], fields[i+1])
+	}
+	mw.Close()
+
+	r := httptest.NewRequest("POST", "/api/batch", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func TestLoadBatch(t *testing.T) {
+	files := []struct{ name, content string }{
+		{"sub/a.json", "a"},
+		{"sub/a.json.asc", "sig a"},
+		{"b.json", "b"},
+		{"README", "ignored"},
+		{"other/b.json", "b again"},
+		{"Bad.json", "bad"},
+	}
+
+	var zbuf, tbuf bytes.Buffer
+	zw, tw := zip.NewWriter(&zbuf), tar.NewWriter(&tbuf)
+	for _, f := range files[:3] {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(f.content))
+	}
+	for _, f := range files[3:] {
+		if err := tw.WriteHeader(&tar.Header{
+			Name: f.name, Mode: 0644, Size: int64(len(f.content)),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(f.content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := batchRequest(t, []batchPart{
+		{"csaf", "c.json", []byte("c")},
+		{"archive", "batch.zip", zbuf.Bytes()},
+		{"archive", "batch.tar", tbuf.Bytes()},
+	})
+
+	c := &controller{cfg: &config{}}
+	loaded, err := c.loadBatch(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		name, data, signature string
+		failed                bool
+	}{
+		{"c.json", "c", "", false},
+		{"a.json", "a", "sig a", false},
+		{"b.json", "b", "", false},
+		{"b.json", "b again", "", true},
+		{"Bad.json", "bad", "", true},
+	}
+	if len(loaded) != len(want) {
+		t.Fatalf("expected %d files, got %d", len(want), len(loaded))
+	}
+	for i, w := range want {
+		f := loaded[i]
+		if f.name != w.name || string(f.data) != w.data ||
+			f.signature != w.signature || (f.err != nil) != w.failed {
+			t.Errorf("%d: unexpected file %s %q %q %v", i, f.name, f.data, f.signature, f.err)
+		}
+	}
+}
+
+func TestLoadBatchSignatureNames(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		sigName   string
+		signature string
+		fail      bool
+	}{
+		{"matching", "a.json.asc", "sig", false},
+		{"in folder", "sub/a.json.asc", "sig", false},
+		{"other document", "b.json.asc", "", false},
+		{"no suffix", "a.json", "", true},
+		{"other suffix", "a.json.sig", "", true},
+	} {
+		c := &controller{cfg: &config{}}
+		loaded, err := c.loadBatch(batchRequest(t, []batchPart{
+			{"csaf", "a.json", []byte("a")},
+			{"signature", tc.sigName, []byte("sig")},
+		}))
+		if (err != nil) != tc.fail {
+			t.Errorf("%s: expected failure %t, got %v", tc.name, tc.fail, err)
+			continue
+		}
+		if tc.fail {
+			continue
+		}
+		if len(loaded) != 1 || loaded[0].signature != tc.signature {
+			t.Errorf("%s: unexpected files %v", tc.name, loaded)
+		}
+	}
+}
+
+func TestLoadBatchLimits(t *testing.T) {
+	uploadLimit, batchLimit, maxFiles := int64(4), int64(10), 3
+
+	for _, tc := range []struct {
+		name   string
+		parts  []batchPart
+		failed []bool
+		fail   bool
+	}{
+		{"below limits", []batchPart{
+			{"csaf", "a.json", []byte("aaaa")},
+			{"csaf", "b.json", []byte("bbbb")},
+		}, []bool{false, false}, false},
+		{"too large file", []batchPart{
+			{"csaf", "a.json", []byte("aaaaa")},
+			{"csaf", "b.json", []byte("bbbb")},
+		}, []bool{true, false}, false},
+		{"too large signature", []batchPart{
+			{"csaf", "a.json", []byte("aaaa")},
+			{"signature", "a.json.asc", []byte("sssss")},
+		}, []bool{true}, false},
+		{"too large batch", []batchPart{
+			{"csaf", "a.json", []byte("aaaa")},
+			{"csaf", "b.json", []byte("bbbb")},
+			{"csaf", "c.json", []byte("cccc")},
+		}, nil, true},
+		{"too many files", []batchPart{
+			{"csaf", "a.json", []byte("a")},
+			{"csaf", "b.json", []byte("b")},
+			{"csaf", "c.json", []byte("c")},
+			{"csaf", "d.json", []byte("d")},
+		}, nil, true},
+	} {
+		c := &controller{cfg: &config{
+			UploadLimit:   &uploadLimit,
+			BatchLimit:    &batchLimit,
+			BatchMaxFiles: &maxFiles,
+		}}
+		loaded, err := c.loadBatch(batchRequest(t, tc.parts))
+		if (err != nil) != tc.fail {
+			t.Errorf("%s: expected failure %t, got %v", tc.name, tc.fail, err)
+			continue
+		}
+		if len(loaded) != len(tc.failed) {
+			t.Errorf("%s: expected %d files, got %d", tc.name, len(tc.failed), len(loaded))
+			continue
+		}
+		for i, f := range loaded {
+			if (f.err != nil) != tc.failed[i] {
+				t.Errorf("%s: %s: unexpected error %v", tc.name, f.name, f.err)
+			}
+		}
+	}
+}
+
+// batchDocument returns a minimal CSAF document with the given id and TLP.
+func batchDocument(id, label string) string {
+	return fmt.Sprintf(`{"document": {
+  "category": "csaf_base",
+  "csaf_version": "2.0",
+  "distribution": {"tlp": {"label": "%[2]s"}},
+  "publisher": {"category": "vendor", "name": "ACME", "namespace": "https://example.com"},
+  "title": "%[1]s",
+  "tracking": {
+    "id": "%[1]s", "status": "final", "version": "1",
+    "initial_release_date": "2022-01-01T00:00:00Z",
+    "current_release_date": "2022-01-01T00:00:00Z",
+    "revision_history": [{"date": "2022-01-01T00:00:00Z", "number": "1", "summary": "Initial."}]
+  }
+}}`, id, label)
+}
+
+func TestBatchUpload(t *testing.T) {
+	dir := t.TempDir()
+
+	key, err := crypto.GenerateKey("test", "test@example.com", "x25519", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, err := key.Armor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := key.GetArmoredPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateFile := filepath.Join(dir, "private.asc")
+	publicFile := filepath.Join(dir, "public.asc")
+	if err := os.WriteFile(privateFile, []byte(private), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(publicFile, []byte(public), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	category, name, namespace := csaf.CSAFCategoryVendor, "ACME", "https://example.com"
+	timeout := duration(time.Second)
+	cfg := &config{
+		OpenPGPPublicKey:   publicFile,
+		OpenPGPPrivateKey:  privateFile,
+		Folder:             filepath.Join(dir, "folder"),
+		Web:                filepath.Join(dir, "web"),
+		TLPs:               []tlp{tlpCSAF, tlpWhite, tlpAmber},
+		CanonicalURLPrefix: "https://example.com",
+		NoPassphrase:       true,
+		ProviderMetaData: &providerMetadataConfig{
+			Publisher: &csaf.Publisher{
+				Category:  &category,
+				Name:      &name,
+				Namespace: &namespace,
+			},
+		},
+		MetricsFile: filepath.Join(dir, "metrics.prom"),
+		LockFile:    filepath.Join(dir, "folder", ".lock"),
+		LockTimeout: &timeout,
+	}
+	if err := os.Mkdir(cfg.Folder, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ensureFolders(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// Let the transaction of amber fail.
+	wellknown := filepath.Join(cfg.Web, ".well-known", "csaf")
+	if err := os.Remove(filepath.Join(wellknown, "amber")); err != nil {
+		t.Fatal(err)
+	}
+
+	r := batchRequest(t, []batchPart{
+		{"csaf", "acme-1.json", []byte(batchDocument("ACME-1", "WHITE"))},
+		{"csaf", "acme-2.json", []byte(batchDocument("ACME-2", "AMBER"))},
+		{"csaf", "acme-3.json", []byte(batchDocument("ACME-3", "WHITE"))},
+		{"csaf", "acme-4.json", []byte(`{"document": {}}`)},
+		{"csaf", "ACME-5.json", []byte(batchDocument("ACME-5", "WHITE"))},
+	}, "tlp", "csaf")
+
+	c := &controller{cfg: cfg}
+	res, err := c.batchUpload(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := res.(*struct {
+		Results []*batchResult `json:"results"`
+	}).Results
+
+	want := []struct {
+		tlp    string
+		reason string
+	}{
+		{"white", ""},
+		{"", "transaction"},
+		{"white", ""},
+		{"", "schema"},
+		{"", "request"},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("expected %d results, got %d", len(want), len(results))
+	}
+	for i, w := range want {
+		res := results[i]
+		if res.TLP != w.tlp || res.reason != w.reason || (res.Errors != nil) != (w.reason != "") {
+			t.Errorf("%s: unexpected result %q %q %v", res.Name, res.TLP, res.reason, res.Errors)
+		}
+	}
+
+	for _, name := range []string{"acme-1.json", "acme-3.json"} {
+		fname := filepath.Join(wellknown, "white", "2022", name)
+		for _, ext := range []string{"", ".sha256", ".sha512", ".asc"} {
+			if _, err := os.Stat(fname + ext); err != nil {
+				t.Errorf("%s%s not published: %v", name, ext, err)
+			}
+		}
+	}
+	lines, err := readIndex(filepath.Join(wellknown, "white", "index.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 {
+		t.Errorf("expected both white advisories in index.txt, got %v", lines)
+	}
+
+	// One transaction for white and the failed one for amber.
+	metrics, err := os.ReadFile(cfg.MetricsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(metrics), "\ncsaf_provider_transactions_total 2\n") {
+		t.Errorf("expected two transactions, got:\n%s", metrics)
+	}
+
+	// Hard link the published files like a transaction
+	// does to check that an update does not change them.
+	white, err := filepath.EvalSymlinks(filepath.Join(wellknown, "white"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	published := []string{
+		filepath.Join("2022", "acme-1.json"),
+		filepath.Join("2022", "acme-1.json.sha256"),
+		filepath.Join("2022", "acme-1.json.asc"),
+		"csaf-feed-tlp-white.json",
+	}
+	linked := t.TempDir()
+	before := map[string]string{}
+	for i, name := range published {
+		data, err := os.ReadFile(filepath.Join(white, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		before[name] = string(data)
+		if err := os.Link(
+			filepath.Join(white, name), filepath.Join(linked, fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	update := strings.Replace(batchDocument("ACME-1", "WHITE"),
+		`"title": "ACME-1"`, `"title": "ACME-1 updated"`, 1)
+	r = batchRequest(t, []batchPart{
+		{"csaf", "acme-1.json", []byte(update)},
+	}, "tlp", "white")
+	if _, err := c.batchUpload(r); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, name := range published {
+		data, err := os.ReadFile(filepath.Join(linked, fmt.Sprint(i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != before[name] {
+			t.Errorf("hard linked %s was modified", name)
+		}
+		now, err := os.ReadFile(filepath.Join(wellknown, "white", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(now) == before[name] {
+			t.Errorf("%s was not updated", name)
+		}
+	}
+}
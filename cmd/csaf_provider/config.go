@@ -12,7 +12,9 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/ProtonMail/gopenpgp/v2/crypto"
@@ -27,11 +29,29 @@ const (
 	defaultConfigPath        = configPrefix + "/config.toml" // Default path to the config file.
 	defaultOpenPGPPrivateKey = configPrefix + "/openpgp_private.asc"
 	defaultOpenPGPPublicKey  = configPrefix + "/openpgp_public.asc"
-	defaultFolder            = "/var/www/"      // Default folder path.
-	defaultWeb               = "/var/www/html"  // Default web path.
-	defaultUploadLimit       = 50 * 1024 * 1024 // Default limit size of the uploaded file.
+	defaultFolder            = "/var/www/"       // Default folder path.
+	defaultWeb               = "/var/www/html"   // Default web path.
+	defaultUploadLimit       = 50 * 1024 * 1024  // Default limit size of the uploaded file.
+	defaultBatchLimit        = 500 * 1024 * 1024 // Default limit size of all files of a batch.
+	defaultBatchMaxFiles     = 1000              // Default maximum number of files of a batch.
+	defaultLockFile          = ".lock"           // Default lock file in the folder.
+	defaultLockTimeout       = 30 * time.Second  // Default time to wait for the lock.
 )
 
+// duration is a time.Duration which can be given
+// as string like "1m30s" in the configuration.
+type duration time.Duration
+
+// UnmarshalText implements the encoding.TextUnmarshaller interface.
+func (d *duration) UnmarshalText(data []byte) error {
+	v, err := time.ParseDuration(string(data))
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
 type providerMetadataConfig struct {
 	ListOnCSAFAggregators   *bool           `toml:"list_on_CSAF_aggregators"`
 	MirrorOnCSAFAggregators *bool           `toml:"mirror_on_CSAF_aggregators"`
@@ -50,11 +70,23 @@ type config struct {
 	CanonicalURLPrefix      string                  `toml:"canonical_url_prefix"`
 	NoPassphrase            bool                    `toml:"no_passphrase"`
 	NoValidation            bool                    `toml:"no_validation"`
+	EnforceMandatoryTests   bool                    `toml:"enforce_mandatory_tests"`
+	OptionalTests           bool                    `toml:"optional_tests"`
+	EnforceOptionalTests    []string                `toml:"enforce_optional_tests"`
 	NoWebUI                 bool                    `toml:"no_web_ui"`
 	DynamicProviderMetaData bool                    `toml:"dynamic_provider_metadata"`
 	ProviderMetaData        *providerMetadataConfig `toml:"provider_metadata"`
 	UploadLimit             *int64                  `toml:"upload_limit"`
+	BatchLimit              *int64                  `toml:"batch_limit"`
+	BatchMaxFiles           *int                    `toml:"batch_max_files"`
 	Issuer                  *string                 `toml:"issuer"`
+	MetricsFile             string                  `toml:"metrics_file"`
+	TLSCertFile             string                  `toml:"tls_cert_file"`
+	TLSKeyFile              string                  `toml:"tls_key_file"`
+	ClientCAFile            string                  `toml:"client_ca_file"`
+	ServeWeb                bool                    `toml:"serve_web"`
+	LockFile                string                  `toml:"lock_file"`
+	LockTimeout             *duration               `toml:"lock_timeout"`
 }
 
 func (pmdc *providerMetadataConfig) apply(pmd *csaf.ProviderMetadata) {
@@ -120,6 +152,15 @@ func (cfg *config) modelTLPs() []csaf.TLPLabel {
 	return tlps
 }
 
+// tests returns the configuration of the CSAF tests run on uploads.
+func (cfg *config) tests() *csaf.TestConfig {
+	return &csaf.TestConfig{
+		Mandatory: cfg.EnforceMandatoryTests,
+		Optional:  cfg.OptionalTests,
+		Enforce:   cfg.EnforceOptionalTests,
+	}
+}
+
 // loadCryptoKeyFromFile loads an armored key from file.
 func loadCryptoKeyFromFile(filename string) (*crypto.Key, error) {
 	f, err := os.Open(filename)
@@ -178,7 +219,9 @@ func loadConfig() (*config, error) {
 	}
 
 	if cfg.CanonicalURLPrefix == "" {
-		cfg.CanonicalURLPrefix = "https://" + os.Getenv("SERVER_NAME")
+		if name := os.Getenv("SERVER_NAME"); name != "" {
+			cfg.CanonicalURLPrefix = "https://" + name
+		}
 	}
 
 	if cfg.TLPs == nil {
@@ -202,5 +245,24 @@ func loadConfig() (*config, error) {
 		cfg.UploadLimit = &ul
 	}
 
+	if cfg.BatchLimit == nil {
+		bl := int64(defaultBatchLimit)
+		cfg.BatchLimit = &bl
+	}
+
+	if cfg.BatchMaxFiles == nil {
+		bm := defaultBatchMaxFiles
+		cfg.BatchMaxFiles = &bm
+	}
+
+	if cfg.LockFile == "" {
+		cfg.LockFile = filepath.Join(cfg.Folder, defaultLockFile)
+	}
+
+	if cfg.LockTimeout == nil {
+		lt := duration(defaultLockTimeout)
+		cfg.LockTimeout = &lt
+	}
+
 	return &cfg, nil
 }
@@ -11,6 +11,7 @@ package main
 import (
 	"embed"
 	"encoding/json"
+	"errors"
 	"html/template"
 	"log"
 	"net/http"
@@ -21,6 +22,10 @@ import (
 //go:embed tmpl
 var tmplFS embed.FS
 
+// retryAfter are the seconds to wait before
+// retrying a request failed as the provider is busy.
+const retryAfter = "5"
+
 type multiError []string
 
 func (me multiError) Error() string {
@@ -42,13 +47,15 @@ func asMultiError(err error) multiError {
 type controller struct {
 	cfg  *config
 	tmpl *template.Template
+	// clientCert returns the TLS client certificate of a request.
+	clientCert func(*http.Request) *clientCert
 }
 
 // newController assigns the given configs to a controller variable and parses the html template
 // if the config value "NoWebUI" is true. It returns the controller variable and nil, otherwise error.
 func newController(cfg *config) (*controller, error) {
 
-	c := controller{cfg: cfg}
+	c := controller{cfg: cfg, clientCert: cgiClientCert}
 	var err error
 
 	if !cfg.NoWebUI {
@@ -67,9 +74,48 @@ func (c *controller) bind(pim *pathInfoMux) {
 		pim.handleFunc("/", c.auth(c.index))
 		pim.handleFunc("/upload", c.auth(c.web(c.upload, "upload.html")))
 		pim.handleFunc("/create", c.auth(c.web(c.create, "create.html")))
+		pim.handleFunc("/delete", postOnly(c.auth(c.web(c.remove, "delete.html"))))
 	}
 	pim.handleFunc("/api/upload", c.auth(api(c.upload)))
+	pim.handleFunc("/api/batch", c.auth(api(c.batchUpload)))
 	pim.handleFunc("/api/create", c.auth(api(c.create)))
+	pim.handleFunc("/api/delete", postOnly(c.auth(api(c.remove))))
+	pim.handleFunc("/api/list", c.auth(api(c.listAdvisories)))
+	pim.handleFunc("/api/get", c.auth(api(c.getAdvisory)))
+}
+
+// postOnly wraps the given http.HandlerFunc and refuses
+// all requests which are not POST requests.
+func postOnly(
+	fn func(http.ResponseWriter, *http.Request),
+) func(http.ResponseWriter, *http.Request) {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			rw.Header().Set("Allow", http.MethodPost)
+			http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		fn(rw, r)
+	}
+}
+
+// clientCert is the outcome of the verification of the TLS client
+// certificate of a request in the terms of the SSL_CLIENT_* variables
+// of nginx. verify is "SUCCESS", "NONE" or "FAILED:reason".
+type clientCert struct {
+	verify  string
+	issuer  string
+	subject string
+}
+
+// cgiClientCert returns the TLS client certificate
+// verified by the webserver calling the CGI program.
+func cgiClientCert(*http.Request) *clientCert {
+	return &clientCert{
+		verify:  os.Getenv("SSL_CLIENT_VERIFY"),
+		issuer:  os.Getenv("SSL_CLIENT_I_DN"),
+		subject: os.Getenv("SSL_CLIENT_S_DN"),
+	}
 }
 
 // auth wraps the given http.HandlerFunc and returns an new one after authenticating the
@@ -80,19 +126,19 @@ func (c *controller) auth(
 ) func(http.ResponseWriter, *http.Request) {
 	return func(rw http.ResponseWriter, r *http.Request) {
 
-		verify := os.Getenv("SSL_CLIENT_VERIFY")
-		log.Printf("SSL_CLIENT_VERIFY: %s\n", verify)
-		if verify == "SUCCESS" || strings.HasPrefix(verify, "FAILED") {
+		cc := c.clientCert(r)
+		log.Printf("SSL_CLIENT_VERIFY: %s\n", cc.verify)
+		if cc.verify == "SUCCESS" || strings.HasPrefix(cc.verify, "FAILED") {
 			// potentially we want to see the Issuer when there is a problem
 			// but it is not clear if we get this far in case of "FAILED".
 			// docs (accessed 2022-03-31 when 1.20.2 was current stable):
 			// https://nginx.org/en/docs/http/ngx_http_ssl_module.html#var_ssl_client_verify
-			log.Printf("SSL_CLIENT_I_DN: %s\n", os.Getenv("SSL_CLIENT_I_DN"))
+			log.Printf("SSL_CLIENT_I_DN: %s\n", cc.issuer)
 		}
 
 		switch {
-		case verify == "SUCCESS" && (c.cfg.Issuer == nil || *c.cfg.Issuer == os.Getenv("SSL_CLIENT_I_DN")):
-			log.Printf("user: %s\n", os.Getenv("SSL_CLIENT_S_DN"))
+		case cc.verify == "SUCCESS" && (c.cfg.Issuer == nil || *c.cfg.Issuer == cc.issuer):
+			log.Printf("user: %s\n", cc.subject)
 		case c.cfg.Password == nil:
 			log.Println("No password set, declining access.")
 			http.Error(rw, http.StatusText(http.StatusForbidden), http.StatusForbidden)
@@ -174,7 +220,10 @@ func api(
 ) func(http.ResponseWriter, *http.Request) {
 
 	return func(rw http.ResponseWriter, r *http.Request) {
-		if content, err := fn(r); err != nil {
+		if content, err := fn(r); errors.Is(err, errBusy) {
+			rw.Header().Set("Retry-After", retryAfter)
+			writeJSON(rw, errorToContent(err), http.StatusServiceUnavailable)
+		} else if err != nil {
 			writeJSON(rw, errorToContent(err), http.StatusBadRequest)
 		} else {
 			writeJSON(rw, content, http.StatusOK)
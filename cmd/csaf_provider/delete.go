@@ -0,0 +1,250 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/csaf-poc/csaf_distribution/csaf"
+	"github.com/csaf-poc/csaf_distribution/util"
+)
+
+const (
+	deleteMode   = "delete"
+	withdrawMode = "withdraw"
+)
+
+// findByFilename looks up the path of an advisory in the index.txt
+// and the withdrawn.csv of a TLP folder by its filename.
+func findByFilename(folder, filename string) (string, error) {
+	lines, err := readIndex(filepath.Join(folder, "index.txt"))
+	if err != nil {
+		return "", err
+	}
+	withdrawn, err := readChanges(filepath.Join(folder, "withdrawn.csv"))
+	if err != nil {
+		return "", err
+	}
+	for _, w := range withdrawn {
+		lines = append(lines, w.path)
+	}
+	var found string
+	for _, line := range lines {
+		if filepath.Base(line) != filename || line == found {
+			continue
+		}
+		if found != "" {
+			return "", fmt.Errorf("filename '%s' is ambiguous", filename)
+		}
+		found = line
+	}
+	if found == "" {
+		return "", fmt.Errorf("advisory '%s' not found", filename)
+	}
+	return found, nil
+}
+
+// findByID looks up the path of an advisory in the ROLIE feed
+// of a TLP by its tracking id. Withdrawn advisories are not
+// listed in the feed. They are looked up by reading the
+// advisories listed in the withdrawn.csv of the TLP folder.
+func findByID(folder string, rolie *csaf.ROLIEFeed, t tlp, id string) (string, error) {
+	var entry *csaf.Entry
+	if rolie != nil {
+		entry = rolie.EntryByID(id)
+	}
+	if entry == nil {
+		return findWithdrawnByID(folder, id)
+	}
+	prefix := "/.well-known/csaf/" + string(t) + "/"
+	idx := strings.Index(entry.Content.Src, prefix)
+	if idx == -1 {
+		return "", fmt.Errorf("unexpected URL '%s' in feed", entry.Content.Src)
+	}
+	fname := path.Clean(entry.Content.Src[idx+len(prefix):])
+	if !util.ConfirmingFileName(path.Base(fname)) || strings.HasPrefix(fname, "..") {
+		return "", fmt.Errorf("unexpected URL '%s' in feed", entry.Content.Src)
+	}
+	return filepath.FromSlash(fname), nil
+}
+
+// findWithdrawnByID looks up the path of a withdrawn advisory
+// of a TLP folder by its tracking id.
+func findWithdrawnByID(folder, id string) (string, error) {
+	withdrawn, err := readChanges(filepath.Join(folder, "withdrawn.csv"))
+	if err != nil {
+		return "", err
+	}
+	expr := util.NewPathEval()
+	for _, w := range withdrawn {
+		data, err := os.ReadFile(filepath.Join(folder, w.path))
+		if err != nil {
+			return "", err
+		}
+		var doc interface{}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return "", fmt.Errorf("%s: %v", w.path, err)
+		}
+		var docID string
+		if err := expr.Extract(
+			`$.document.tracking.id`, util.StringMatcher(&docID), false, doc,
+		); err != nil {
+			return "", fmt.Errorf("%s: %v", w.path, err)
+		}
+		if docID == id {
+			return w.path, nil
+		}
+	}
+	return "", fmt.Errorf("advisory with id '%s' not found", id)
+}
+
+// removeFromFeed removes the entries of an advisory from a ROLIE feed.
+func removeFromFeed(feed string, rolie *csaf.ROLIEFeed, t tlp, fname string) error {
+	if rolie == nil {
+		return nil
+	}
+	suffix := "/.well-known/csaf/" + string(t) + "/" + filepath.ToSlash(fname)
+	entries := rolie.Feed.Entry[:0]
+	for _, e := range rolie.Feed.Entry {
+		if !strings.HasSuffix(e.Content.Src, suffix) {
+			entries = append(entries, e)
+		}
+	}
+	if len(entries) == len(rolie.Feed.Entry) {
+		return nil
+	}
+	rolie.Feed.Entry = entries
+	rolie.Feed.Updated = csaf.TimeStamp(time.Now().UTC())
+
+	// Remove first to break hard link.
+	if err := os.Remove(feed); err != nil {
+		return err
+	}
+	return util.WriteToFile(feed, rolie)
+}
+
+// removeFiles removes an advisory together with its
+// hashes and its signature.
+func removeFiles(fname string) error {
+	if err := os.Remove(fname); err != nil {
+		return err
+	}
+	for _, ext := range []string{".sha256", ".sha512", ".asc"} {
+		if err := os.Remove(fname + ext); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// remove deletes an advisory given by its filename or its tracking id
+// from a TLP folder and removes it from the indices and the feed.
+// In the withdraw mode the files are kept and the advisory is
+// listed in the withdrawn.csv instead. Only the parameters
+// in the body of a POST request are taken into account.
+func (c *controller) remove(r *http.Request) (interface{}, error) {
+
+	if r.Method != http.MethodPost {
+		return nil, errors.New("only POST requests are supported")
+	}
+
+	t, err := c.checkTLP(r.PostFormValue("tlp"))
+	if err != nil {
+		return nil, err
+	}
+	if t == tlpCSAF {
+		return nil, errors.New("the TLP of the advisory is needed")
+	}
+
+	mode := r.PostFormValue("mode")
+	switch mode {
+	case "":
+		mode = deleteMode
+	case deleteMode, withdrawMode:
+	default:
+		return nil, fmt.Errorf("unsupported mode '%s'", mode)
+	}
+
+	filename, id := r.PostFormValue("filename"), r.PostFormValue("id")
+	if (filename == "") == (id == "") {
+		return nil, errors.New("either filename or id is needed")
+	}
+	if filename != "" && !util.ConfirmingFileName(filename) {
+		return nil, errors.New("given filename is not confirming")
+	}
+
+	var fname string
+
+	if err := doTransaction(
+		c.cfg, t,
+		func(folder string, _ *csaf.ProviderMetadata) error {
+
+			feed := filepath.Join(folder, "csaf-feed-tlp-"+string(t)+".json")
+			rolie, err := loadFeed(feed)
+			if err != nil {
+				return err
+			}
+
+			if filename != "" {
+				fname, err = findByFilename(folder, filename)
+			} else {
+				fname, err = findByID(folder, rolie, t, id)
+			}
+			if err != nil {
+				return err
+			}
+
+			withdrawn, err := isWithdrawn(folder, fname)
+			if err != nil {
+				return err
+			}
+			if mode == withdrawMode && withdrawn {
+				return fmt.Errorf("advisory '%s' is already withdrawn", fname)
+			}
+
+			if err := removeFromFeed(feed, rolie, t, fname); err != nil {
+				return err
+			}
+
+			if err := removeFromIndices(folder, fname); err != nil {
+				return err
+			}
+
+			if mode == withdrawMode {
+				return addWithdrawn(folder, fname, time.Now().UTC())
+			}
+
+			if err := removeFiles(filepath.Join(folder, fname)); err != nil {
+				return err
+			}
+			return removeWithdrawn(folder, fname)
+		},
+	); err != nil {
+		return nil, err
+	}
+
+	return &struct {
+		Name  string `json:"name"`
+		Path  string `json:"path"`
+		Mode  string `json:"mode"`
+		Error error  `json:"-"`
+	}{
+		Name: filepath.Base(fname),
+		Path: filepath.ToSlash(fname),
+		Mode: mode,
+	}, nil
+}
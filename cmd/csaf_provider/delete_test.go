@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRemoveFromIndices(t *testing.T) {
+	dir := t.TempDir()
+	release := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, fname := range []string{"2022/a.json", "2022/b.json", "2021/b.json"} {
+		if err := updateIndices(dir, change{release, fname}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := findByFilename(dir, "b.json"); err == nil {
+		t.Fatal("expected ambiguous filename")
+	}
+
+	// Hard link like a transaction does to check that it is broken.
+	other := t.TempDir()
+	if err := os.Link(
+		filepath.Join(dir, "index.txt"), filepath.Join(other, "index.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := removeFromIndices(dir, "2022/b.json"); err != nil {
+		t.Fatal(err)
+	}
+	if err := addWithdrawn(dir, "2022/b.json", release); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, err := readIndex(filepath.Join(dir, "index.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"2021/b.json", "2022/a.json"}; !reflect.DeepEqual(lines, want) {
+		t.Fatalf("expected %v, got %v", want, lines)
+	}
+	if lines, _ = readIndex(filepath.Join(other, "index.txt")); len(lines) != 3 {
+		t.Fatalf("hard linked index.txt was modified: %v", lines)
+	}
+
+	chs, err := readChanges(filepath.Join(dir, "changes.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chs) != 2 {
+		t.Fatalf("expected 2 changes, got %v", chs)
+	}
+
+	if withdrawn, err := isWithdrawn(dir, "2022/b.json"); err != nil || !withdrawn {
+		t.Fatalf("expected withdrawn advisory, got %v", err)
+	}
+	// Withdrawn advisories can still be found by their filenames.
+	if _, err := findByFilename(dir, "b.json"); err == nil {
+		t.Fatal("expected ambiguous filename")
+	}
+	if err := removeWithdrawn(dir, "2022/b.json"); err != nil {
+		t.Fatal(err)
+	}
+	if fname, err := findByFilename(dir, "b.json"); err != nil || fname != "2021/b.json" {
+		t.Fatalf("expected 2021/b.json, got %q (%v)", fname, err)
+	}
+}
+
+func TestFindWithdrawnByID(t *testing.T) {
+	dir := t.TempDir()
+	release := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, id := range []string{"ACME-1", "ACME-2"} {
+		fname := filepath.Join("2022", strings.ToLower(id)+".json")
+		if err := os.MkdirAll(filepath.Join(dir, "2022"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		doc := fmt.Sprintf(`{"document": {"tracking": {"id": "%s"}}}`, id)
+		if err := os.WriteFile(filepath.Join(dir, fname), []byte(doc), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := addWithdrawn(dir, fname, release); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, x := range []struct {
+		id   string
+		want string
+	}{
+		{"ACME-1", filepath.Join("2022", "acme-1.json")},
+		{"ACME-2", filepath.Join("2022", "acme-2.json")},
+		{"ACME-3", ""},
+	} {
+		fname, err := findByID(dir, nil, tlpWhite, x.id)
+		switch {
+		case x.want == "" && err == nil:
+			t.Errorf("%s: expected not found, got %q", x.id, fname)
+		case x.want != "" && (err != nil || fname != x.want):
+			t.Errorf("%s: expected %q, got %q (%v)", x.id, x.want, fname, err)
+		}
+	}
+}
+
+func TestRemoveMethod(t *testing.T) {
+	c := &controller{cfg: &config{TLPs: []tlp{tlpWhite}}}
+
+	r := httptest.NewRequest("GET", "/api/delete?tlp=white&filename=a.json", nil)
+	if _, err := c.remove(r); err == nil {
+		t.Fatal("expected GET request to fail")
+	}
+
+	// Parameters in the URL are ignored in POST requests.
+	r = httptest.NewRequest("POST", "/api/delete?tlp=white&filename=a.json", nil)
+	if _, err := c.remove(r); err == nil {
+		t.Fatal("expected parameters in URL to be ignored")
+	}
+
+	rec := httptest.NewRecorder()
+	postOnly(func(http.ResponseWriter, *http.Request) {
+		t.Fatal("GET request passed")
+	})(rec, httptest.NewRequest("GET", "/delete", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected status %d, got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
@@ -16,7 +16,23 @@ import (
 	"github.com/csaf-poc/csaf_distribution/util"
 )
 
+// unlink removes a file if it exists. Files of a transaction
+// are hard linked to the current version, so they have to be
+// removed before they are written.
+func unlink(fname string) error {
+	if err := os.Remove(fname); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
 func writeHashedFile(fname, name string, data []byte, armored string) error {
+	// Remove first to break hard links.
+	for _, f := range []string{fname, fname + ".sha256", fname + ".sha512", fname + ".asc"} {
+		if err := unlink(f); err != nil {
+			return err
+		}
+	}
 	// Write the file itself.
 	if err := os.WriteFile(fname, data, 0644); err != nil {
 		return err
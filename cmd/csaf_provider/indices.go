@@ -19,38 +19,37 @@ import (
 	"time"
 )
 
-func updateIndex(dir, fname string) error {
-
-	index := filepath.Join(dir, "index.txt")
+// change is an entry of the changes.csv or the withdrawn.csv.
+type change struct {
+	time time.Time
+	path string
+}
 
-	lines, err := func() ([]string, error) {
-		f, err := os.Open(index)
-		if err != nil {
-			if os.IsNotExist(err) {
-				return []string{fname}, nil
-			}
-			return nil, err
-		}
-		defer f.Close()
-		var lines []string
-		scanner := bufio.NewScanner(f)
-		for scanner.Scan() {
-			// stop scanning when we found it.
-			if line := scanner.Text(); line != fname {
-				lines = append(lines, line)
-			} else {
-				return nil, nil
-			}
-		}
-		return append(lines, fname), nil
-	}()
+// readIndex reads the lines of an index.txt.
+// A missing file is treated as an empty one.
+func readIndex(index string) ([]string, error) {
+	f, err := os.Open(index)
 	if err != nil {
-		return err
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
 	}
-	if len(lines) == 0 {
-		return nil
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// writeIndex writes the sorted lines to an index.txt.
+func writeIndex(index string, lines []string) error {
+	// Remove first to break hard link.
+	if err := os.Remove(index); err != nil && !os.IsNotExist(err) {
+		return err
 	}
-	// Create new to break hard link.
 	f, err := os.Create(index)
 	if err != nil {
 		return err
@@ -67,71 +66,49 @@ func updateIndex(dir, fname string) error {
 	return f.Close()
 }
 
-func updateChanges(dir, fname string, releaseDate time.Time) error {
-
-	type change struct {
-		time time.Time
-		path string
+// readChanges reads the entries of a changes.csv.
+// A missing file is treated as an empty one.
+func readChanges(changes string) ([]change, error) {
+	f, err := os.Open(changes)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
 	}
-
-	changes := filepath.Join(dir, "changes.csv")
-
-	chs, err := func() ([]change, error) {
-		f, err := os.Open(changes)
+	defer f.Close()
+	var chs []change
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 2
+	r.ReuseRecord = true
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			if os.IsNotExist(err) {
-				return []change{{releaseDate, fname}}, nil
-			}
 			return nil, err
 		}
-		defer f.Close()
-		var chs []change
-		r := csv.NewReader(f)
-		r.FieldsPerRecord = 2
-		r.ReuseRecord = true
-		replaced := false
-		for {
-			record, err := r.Read()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				return nil, err
-			}
-			// Check if new is already in.
-			if record[1] == fname {
-				// Identical -> no change at all.
-				if record[0] == releaseDate.Format(dateFormat) {
-					return nil, nil
-				}
-				// replace old entry
-				replaced = true
-				chs = append(chs, change{releaseDate, fname})
-				continue
-			}
-			t, err := time.Parse(dateFormat, record[0])
-			if err != nil {
-				return nil, err
-			}
-			chs = append(chs, change{t, record[1]})
-		}
-		if !replaced {
-			chs = append(chs, change{releaseDate, fname})
+		t, err := time.Parse(dateFormat, record[0])
+		if err != nil {
+			return nil, err
 		}
-		return chs, nil
-	}()
-
-	if err != nil {
-		return err
-	}
-	if len(chs) == 0 {
-		return nil
+		chs = append(chs, change{t, record[1]})
 	}
+	return chs, nil
+}
+
+// writeChanges writes the entries to a changes.csv
+// in descending order of their times.
+func writeChanges(changes string, chs []change) error {
 	// Sort descending
-	sort.Slice(chs, func(i, j int) bool {
+	sort.SliceStable(chs, func(i, j int) bool {
 		return chs[j].time.Before(chs[i].time)
 	})
-	// Create new to break hard link.
+	// Remove first to break hard link.
+	if err := os.Remove(changes); err != nil && !os.IsNotExist(err) {
+		return err
+	}
 	o, err := os.Create(changes)
 	if err != nil {
 		return err
@@ -155,11 +132,167 @@ func updateChanges(dir, fname string, releaseDate time.Time) error {
 	return err2
 }
 
-func updateIndices(dir, fname string, releaseDate time.Time) error {
+func updateIndex(dir string, fnames ...string) error {
+
+	index := filepath.Join(dir, "index.txt")
+
+	lines, err := readIndex(index)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool, len(lines))
+	for _, line := range lines {
+		known[line] = true
+	}
+	n := len(lines)
+	for _, fname := range fnames {
+		if !known[fname] {
+			known[fname] = true
+			lines = append(lines, fname)
+		}
+	}
+	if len(lines) == n {
+		return nil
+	}
+	return writeIndex(index, lines)
+}
+
+func updateChanges(dir string, updates ...change) error {
+
+	changes := filepath.Join(dir, "changes.csv")
 
-	if err := updateIndex(dir, fname); err != nil {
+	chs, err := readChanges(changes)
+	if err != nil {
 		return err
 	}
+	known := make(map[string]int, len(chs))
+	for i, ch := range chs {
+		known[ch.path] = i
+	}
+	modified := false
+	for _, update := range updates {
+		// Check if new is already in.
+		if i, ok := known[update.path]; ok {
+			// Identical -> no change at all.
+			if chs[i].time.Format(dateFormat) == update.time.Format(dateFormat) {
+				continue
+			}
+			// replace old entry
+			chs[i].time = update.time
+		} else {
+			known[update.path] = len(chs)
+			chs = append(chs, update)
+		}
+		modified = true
+	}
+	if !modified {
+		return nil
+	}
+	return writeChanges(changes, chs)
+}
+
+func updateIndices(dir string, updates ...change) error {
+
+	fnames := make([]string, len(updates))
+	for i := range updates {
+		fnames[i] = updates[i].path
+	}
+
+	if err := updateIndex(dir, fnames...); err != nil {
+		return err
+	}
+
+	return updateChanges(dir, updates...)
+}
+
+// removeFromIndex removes a path from the index.txt.
+func removeFromIndex(dir, fname string) error {
+
+	index := filepath.Join(dir, "index.txt")
+
+	lines, err := readIndex(index)
+	if err != nil {
+		return err
+	}
+	kept := lines[:0]
+	for _, line := range lines {
+		if line != fname {
+			kept = append(kept, line)
+		}
+	}
+	if len(kept) == len(lines) {
+		return nil
+	}
+	return writeIndex(index, kept)
+}
+
+// removeChange removes paths from a changes.csv like file.
+// It returns true if one of the paths was found.
+func removeChange(changes string, fnames ...string) (bool, error) {
+	chs, err := readChanges(changes)
+	if err != nil {
+		return false, err
+	}
+	remove := make(map[string]bool, len(fnames))
+	for _, fname := range fnames {
+		remove[fname] = true
+	}
+	kept := chs[:0]
+	for _, ch := range chs {
+		if !remove[ch.path] {
+			kept = append(kept, ch)
+		}
+	}
+	if len(kept) == len(chs) {
+		return false, nil
+	}
+	return true, writeChanges(changes, kept)
+}
+
+// removeFromIndices removes a path from the index.txt and the changes.csv.
+func removeFromIndices(dir, fname string) error {
+
+	if err := removeFromIndex(dir, fname); err != nil {
+		return err
+	}
+
+	_, err := removeChange(filepath.Join(dir, "changes.csv"), fname)
+	return err
+}
+
+// isWithdrawn checks if a path is listed in the withdrawn.csv.
+func isWithdrawn(dir, fname string) (bool, error) {
+	chs, err := readChanges(filepath.Join(dir, "withdrawn.csv"))
+	if err != nil {
+		return false, err
+	}
+	for _, ch := range chs {
+		if ch.path == fname {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// addWithdrawn lists a path in the withdrawn.csv.
+func addWithdrawn(dir, fname string, withdrawn time.Time) error {
+
+	withdrawnCSV := filepath.Join(dir, "withdrawn.csv")
+
+	chs, err := readChanges(withdrawnCSV)
+	if err != nil {
+		return err
+	}
+	for _, ch := range chs {
+		if ch.path == fname {
+			return nil
+		}
+	}
+	return writeChanges(withdrawnCSV, append(chs, change{withdrawn, fname}))
+}
 
-	return updateChanges(dir, fname, releaseDate)
+// removeWithdrawn removes paths from the withdrawn.csv.
+func removeWithdrawn(dir string, fnames ...string) error {
+	_, err := removeChange(filepath.Join(dir, "withdrawn.csv"), fnames...)
+	return err
 }
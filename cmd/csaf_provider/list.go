@@ -0,0 +1,441 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/csaf-poc/csaf_distribution/csaf"
+	"github.com/csaf-poc/csaf_distribution/util"
+)
+
+// advisoryInfo is the metadata of a published advisory.
+type advisoryInfo struct {
+	TLP                string `json:"tlp"`
+	ID                 string `json:"id"`
+	Version            string `json:"version"`
+	Status             string `json:"status"`
+	InitialReleaseDate string `json:"initial_release_date"`
+	CurrentReleaseDate string `json:"current_release_date"`
+	Filename           string `json:"filename"`
+	Path               string `json:"path"`
+	URL                string `json:"url"`
+	SHA256             string `json:"sha256,omitempty"`
+	SHA512             string `json:"sha512,omitempty"`
+	SigningKeyID       string `json:"signing_key_id,omitempty"`
+	SigningKey         string `json:"signing_key_fingerprint,omitempty"`
+	Error              string `json:"error,omitempty"`
+
+	current time.Time
+}
+
+// listFilter selects the advisories to list.
+type listFilter struct {
+	tlps     []tlp
+	from     time.Time
+	to       time.Time
+	toDay    bool // to is a plain date
+	idPrefix string
+}
+
+// inRange checks if the given time is in the date range of the filter.
+func (lf *listFilter) inRange(t time.Time) bool {
+	if !lf.from.IsZero() && t.Before(lf.from) {
+		return false
+	}
+	switch {
+	case lf.to.IsZero():
+		return true
+	case lf.toDay:
+		// The upper bound is inclusive for the whole day.
+		return t.Before(lf.to.AddDate(0, 0, 1))
+	default:
+		return !t.After(lf.to)
+	}
+}
+
+// maxReadAttempts is how often the folder of a TLP is read
+// if it is replaced by a transaction while reading it.
+const maxReadAttempts = 3
+
+// lister collects the metadata of the published advisories.
+type lister struct {
+	cfg          *config
+	wellknown    string
+	fingerprints []string
+}
+
+// parseDate parses a date given as RFC3339 timestamp or as plain date.
+// It reports if it is a plain date.
+func parseDate(s string) (time.Time, bool, error) {
+	if t, err := time.Parse(dateFormat, s); err == nil {
+		return t, false, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	return t, true, err
+}
+
+// tlpsParam returns the TLPs given in the request, all
+// published ones if none is given.
+func (c *controller) tlpsParam(r *http.Request) ([]tlp, error) {
+	if r.FormValue("tlp") != "" {
+		t, err := c.tlpParam(r)
+		if err != nil {
+			return nil, err
+		}
+		if t == tlpCSAF {
+			return nil, errors.New("the TLP of the advisories is needed")
+		}
+		return []tlp{t}, nil
+	}
+	var tlps []tlp
+	for _, t := range c.cfg.TLPs {
+		if t != tlpCSAF {
+			tlps = append(tlps, t)
+		}
+	}
+	return tlps, nil
+}
+
+// listFilterParam returns the filter given in the request.
+func (c *controller) listFilterParam(r *http.Request) (*listFilter, error) {
+	tlps, err := c.tlpsParam(r)
+	if err != nil {
+		return nil, err
+	}
+	lf := listFilter{
+		tlps:     tlps,
+		idPrefix: r.FormValue("id_prefix"),
+	}
+	if from := r.FormValue("from"); from != "" {
+		if lf.from, _, err = parseDate(from); err != nil {
+			return nil, fmt.Errorf("invalid from date '%s'", from)
+		}
+	}
+	if to := r.FormValue("to"); to != "" {
+		if lf.to, lf.toDay, err = parseDate(to); err != nil {
+			return nil, fmt.Errorf("invalid to date '%s'", to)
+		}
+	}
+	return &lf, nil
+}
+
+// newLister creates a lister which takes the known fingerprints
+// of the signing keys from the provider metadata.
+func newLister(cfg *config) (*lister, error) {
+	l := lister{
+		cfg:       cfg,
+		wellknown: filepath.Join(cfg.Web, ".well-known", "csaf"),
+	}
+	f, err := os.Open(filepath.Join(l.wellknown, "provider-metadata.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &l, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	pmd, err := csaf.LoadProviderMetadata(f)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pmd.PGPKeys {
+		l.fingerprints = append(l.fingerprints, string(pmd.PGPKeys[i].Fingerprint))
+	}
+	return &l, nil
+}
+
+// load loads the changes.csv and the ROLIE feed of a TLP.
+// The symbolic link to the folder of the TLP is resolved once
+// so that all files are read from the same version of it.
+// It returns nothing if the TLP is not published.
+func (l *lister) load(t tlp) (string, []change, *csaf.ROLIEFeed, error) {
+	folder, err := filepath.EvalSymlinks(filepath.Join(l.wellknown, string(t)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil, nil, nil
+		}
+		return "", nil, nil, err
+	}
+	chs, err := readChanges(filepath.Join(folder, "changes.csv"))
+	if err != nil {
+		return "", nil, nil, err
+	}
+	rolie, err := loadFeed(filepath.Join(folder, "csaf-feed-tlp-"+string(t)+".json"))
+	if err != nil {
+		return "", nil, nil, err
+	}
+	// Missing files are only fine if the folder is still there.
+	if _, err := os.Stat(folder); err != nil {
+		return "", nil, nil, err
+	}
+	return folder, chs, rolie, nil
+}
+
+// read calls fn with the folder, the changes and the feed of a TLP.
+// If files vanish because a transaction replaced the folder in the
+// meantime it is tried again with the new folder. fn is not called
+// if the TLP is not published.
+func (l *lister) read(
+	t tlp,
+	fn func(string, []change, *csaf.ROLIEFeed) error,
+) error {
+	for attempt := 1; ; attempt++ {
+		folder, chs, rolie, err := l.load(t)
+		if err == nil && folder != "" {
+			err = fn(folder, chs, rolie)
+		}
+		if err != nil && errors.Is(err, os.ErrNotExist) && attempt < maxReadAttempts {
+			continue
+		}
+		return err
+	}
+}
+
+// signingKey returns the key id of the signature of an advisory
+// and the fingerprint of the key if it is a known one.
+func (l *lister) signingKey(fname string) (string, string) {
+	data, err := os.ReadFile(fname + ".asc")
+	if err != nil {
+		return "", ""
+	}
+	sig, err := crypto.NewPGPSignatureFromArmored(string(data))
+	if err != nil {
+		return "", ""
+	}
+	ids, ok := sig.GetHexSignatureKeyIDs()
+	if !ok || len(ids) == 0 {
+		return "", ""
+	}
+	id := strings.ToUpper(ids[0])
+	for _, fp := range l.fingerprints {
+		if strings.HasSuffix(strings.ToUpper(fp), id) {
+			return id, strings.ToUpper(fp)
+		}
+	}
+	return id, ""
+}
+
+// info collects the metadata of an advisory.
+func (l *lister) info(t tlp, folder, path string) (*advisoryInfo, error) {
+
+	fname := filepath.Join(folder, path)
+
+	data, err := os.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	sum, err := csaf.NewAdvisorySummary(util.NewPathEval(), doc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	hash := func(ext string) string {
+		if h, err := util.HashFromFile(fname + ext); err == nil && h != nil {
+			return hex.EncodeToString(h)
+		}
+		return ""
+	}
+
+	keyID, fingerprint := l.signingKey(fname)
+
+	return &advisoryInfo{
+		TLP:                string(t),
+		ID:                 sum.ID,
+		Version:            sum.Version,
+		Status:             sum.Status,
+		InitialReleaseDate: sum.InitialReleaseDate.Format(dateFormat),
+		CurrentReleaseDate: sum.CurrentReleaseDate.Format(dateFormat),
+		Filename:           filepath.Base(path),
+		Path:               filepath.ToSlash(path),
+		URL: l.cfg.CanonicalURLPrefix +
+			"/.well-known/csaf/" + string(t) + "/" + filepath.ToSlash(path),
+		SHA256:       hash(".sha256"),
+		SHA512:       hash(".sha512"),
+		SigningKeyID: keyID,
+		SigningKey:   fingerprint,
+		current:      sum.CurrentReleaseDate,
+	}, nil
+}
+
+// list collects the metadata of the advisories selected by the filter.
+// They are ordered by their current release dates, newest first.
+func (l *lister) list(lf *listFilter) ([]*advisoryInfo, error) {
+	advisories := []*advisoryInfo{}
+	for _, t := range lf.tlps {
+		var infos []*advisoryInfo
+		if err := l.read(t, func(folder string, chs []change, rolie *csaf.ROLIEFeed) error {
+			infos = infos[:0]
+			var entries map[string]*csaf.Entry
+			if lf.idPrefix != "" {
+				entries = entriesByPath(rolie, t)
+			}
+			for _, ch := range chs {
+				if !lf.inRange(ch.time) {
+					continue
+				}
+				// Prefer the id from the feed to spare the loading.
+				if e := entries[filepath.ToSlash(ch.path)]; e != nil &&
+					!strings.HasPrefix(e.ID, lf.idPrefix) {
+					continue
+				}
+				info, err := l.info(t, folder, ch.path)
+				if err != nil {
+					// Try again if the folder was replaced in the meantime.
+					if _, serr := os.Stat(folder); serr != nil {
+						return err
+					}
+					// Report the advisory but keep listing the others.
+					infos = append(infos, l.failed(t, ch.path, err))
+					continue
+				}
+				if !strings.HasPrefix(info.ID, lf.idPrefix) {
+					continue
+				}
+				infos = append(infos, info)
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+		advisories = append(advisories, infos...)
+	}
+	sort.SliceStable(advisories, func(i, j int) bool {
+		return advisories[j].current.Before(advisories[i].current)
+	})
+	return advisories, nil
+}
+
+// failed returns the metadata of an advisory which cannot be loaded.
+func (l *lister) failed(t tlp, path string, err error) *advisoryInfo {
+	return &advisoryInfo{
+		TLP:      string(t),
+		Filename: filepath.Base(path),
+		Path:     filepath.ToSlash(path),
+		URL: l.cfg.CanonicalURLPrefix +
+			"/.well-known/csaf/" + string(t) + "/" + filepath.ToSlash(path),
+		Error: err.Error(),
+	}
+}
+
+// entriesByPath indexes the entries of a ROLIE feed by the paths
+// of their advisories relative to the folder of the TLP.
+func entriesByPath(rolie *csaf.ROLIEFeed, t tlp) map[string]*csaf.Entry {
+	if rolie == nil {
+		return nil
+	}
+	prefix := "/.well-known/csaf/" + string(t) + "/"
+	entries := make(map[string]*csaf.Entry, len(rolie.Feed.Entry))
+	for _, e := range rolie.Feed.Entry {
+		if idx := strings.Index(e.Content.Src, prefix); idx != -1 {
+			entries[e.Content.Src[idx+len(prefix):]] = e
+		}
+	}
+	return entries
+}
+
+// listAdvisories returns the metadata of the published advisories.
+// They can be filtered by TLP, by the range of their
+// current release dates and by a prefix of their ids.
+func (c *controller) listAdvisories(r *http.Request) (interface{}, error) {
+	lf, err := c.listFilterParam(r)
+	if err != nil {
+		return nil, err
+	}
+	l, err := newLister(c.cfg)
+	if err != nil {
+		return nil, err
+	}
+	advisories, err := l.list(lf)
+	if err != nil {
+		return nil, err
+	}
+	return &struct {
+		Advisories []*advisoryInfo `json:"advisories"`
+	}{
+		Advisories: advisories,
+	}, nil
+}
+
+// getAdvisory returns the metadata of a single published
+// advisory given by its filename or its tracking id.
+func (c *controller) getAdvisory(r *http.Request) (interface{}, error) {
+	tlps, err := c.tlpsParam(r)
+	if err != nil {
+		return nil, err
+	}
+
+	filename, id := r.FormValue("filename"), r.FormValue("id")
+	if (filename == "") == (id == "") {
+		return nil, errors.New("either filename or id is needed")
+	}
+
+	l, err := newLister(c.cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	errAmbiguous := errors.New("advisory is ambiguous, the TLP is needed")
+
+	var found *advisoryInfo
+	for _, t := range tlps {
+		var hit *advisoryInfo
+		if err := l.read(t, func(folder string, chs []change, rolie *csaf.ROLIEFeed) error {
+			hit = nil
+			var entries map[string]*csaf.Entry
+			if id != "" {
+				entries = entriesByPath(rolie, t)
+			}
+			for _, ch := range chs {
+				if filename != "" && filepath.Base(ch.path) != filename {
+					continue
+				}
+				if id != "" {
+					if e := entries[filepath.ToSlash(ch.path)]; e == nil || e.ID != id {
+						continue
+					}
+				}
+				if hit != nil {
+					return errAmbiguous
+				}
+				var err error
+				if hit, err = l.info(t, folder, ch.path); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+		if hit != nil {
+			if found != nil {
+				return nil, errAmbiguous
+			}
+			found = hit
+		}
+	}
+	if found == nil {
+		return nil, errors.New("advisory not found")
+	}
+	return found, nil
+}
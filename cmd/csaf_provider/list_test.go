@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/csaf-poc/csaf_distribution/csaf"
+)
+
+func TestList(t *testing.T) {
+	web := t.TempDir()
+	folder := filepath.Join(web, ".well-known", "csaf", "white")
+	if err := os.MkdirAll(filepath.Join(folder, "2022"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, id := range []string{"ACME-1", "ACME-2", "OTHER-1"} {
+		release := time.Date(2022, 1, i+1, 12, 0, 0, 0, time.UTC)
+		doc := fmt.Sprintf(`{"document": {
+  "title": "%[1]s",
+  "publisher": {"category": "vendor", "name": "ACME", "namespace": "https://example.com"},
+  "tracking": {
+    "id": "%[1]s", "status": "final", "version": "%[2]d",
+    "initial_release_date": "%[3]s", "current_release_date": "%[3]s"
+  }
+}}`, id, i+1, release.Format(dateFormat))
+		fname := filepath.Join("2022", id+".json")
+		if err := os.WriteFile(filepath.Join(folder, fname), []byte(doc), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := updateIndices(folder, change{release, fname}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := &controller{cfg: &config{Web: web, TLPs: []tlp{tlpCSAF, tlpWhite, tlpAmber}}}
+	l, err := newLister(c.cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"all", "", []string{"OTHER-1", "ACME-2", "ACME-1"}},
+		{"id prefix", "id_prefix=ACME", []string{"ACME-2", "ACME-1"}},
+		{"from", "from=2022-01-02", []string{"OTHER-1", "ACME-2"}},
+		{"to", "to=2022-01-01T12:00:00Z", []string{"ACME-1"}},
+		{"to date", "to=2022-01-02", []string{"ACME-2", "ACME-1"}},
+		{"day", "from=2022-01-02&to=2022-01-02", []string{"ACME-2"}},
+	} {
+		lf, err := c.listFilterParam(httptest.NewRequest("GET", "/api/list?"+tc.query, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		advisories, err := l.list(lf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []string{}
+		for _, a := range advisories {
+			ids = append(ids, a.ID)
+		}
+		if !reflect.DeepEqual(ids, tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, ids)
+		}
+	}
+}
+
+func TestListerRead(t *testing.T) {
+	web := t.TempDir()
+	wellknown := filepath.Join(web, ".well-known", "csaf")
+	if err := os.MkdirAll(wellknown, 0755); err != nil {
+		t.Fatal(err)
+	}
+	release := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	var (
+		old      = filepath.Join(web, "white-1")
+		replaced = filepath.Join(web, "white-2")
+		err      error
+	)
+	for _, dir := range []string{old, replaced} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := updateIndices(dir, change{release, "2022/a.json"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	link := filepath.Join(wellknown, "white")
+	if err := os.Symlink(old, link); err != nil {
+		t.Fatal(err)
+	}
+
+	l := &lister{cfg: &config{Web: web}, wellknown: wellknown}
+
+	var folders []string
+	if err = l.read(tlpWhite, func(folder string, chs []change, _ *csaf.ROLIEFeed) error {
+		folders = append(folders, folder)
+		if len(chs) != 1 {
+			t.Fatalf("expected one change, got %v", chs)
+		}
+		if len(folders) == 1 {
+			// Replace the folder like a transaction does.
+			if err := os.Remove(link); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Symlink(replaced, link); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.RemoveAll(old); err != nil {
+				t.Fatal(err)
+			}
+		}
+		_, err := os.ReadFile(filepath.Join(folder, "index.txt"))
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if replaced, err = filepath.EvalSymlinks(replaced); err != nil {
+		t.Fatal(err)
+	}
+	if len(folders) != 2 || folders[1] != replaced {
+		t.Fatalf("expected to read %s again, got %v", replaced, folders)
+	}
+}
+
+func TestListErrors(t *testing.T) {
+	web := t.TempDir()
+	folder := filepath.Join(web, ".well-known", "csaf", "white")
+	if err := os.MkdirAll(filepath.Join(folder, "2022"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	release := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	docs := []struct{ id, content string }{
+		{"ACME-1", fmt.Sprintf(`{"document": {
+  "title": "ACME-1",
+  "publisher": {"category": "vendor", "name": "ACME", "namespace": "https://example.com"},
+  "tracking": {
+    "id": "ACME-1", "status": "final", "version": "1",
+    "initial_release_date": "%[1]s", "current_release_date": "%[1]s"
+  }
+}}`, release.Format(dateFormat))},
+		{"ACME-2", "{"},
+		{"OTHER-1", "{"},
+	}
+	rolie := &csaf.ROLIEFeed{}
+	for _, doc := range docs {
+		fname := filepath.Join("2022", doc.id+".json")
+		if err := os.WriteFile(filepath.Join(folder, fname), []byte(doc.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := updateIndices(folder, change{release, fname}); err != nil {
+			t.Fatal(err)
+		}
+		rolie.Feed.Entry = append(rolie.Feed.Entry, &csaf.Entry{
+			ID:      doc.id,
+			Content: csaf.Content{Src: "https://example.com/.well-known/csaf/white/2022/" + doc.id + ".json"},
+		})
+	}
+	// Only the first two are in the feed.
+	rolie.Feed.Entry = rolie.Feed.Entry[:2]
+	feed, err := os.Create(filepath.Join(folder, "csaf-feed-tlp-white.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rolie.WriteTo(feed); err != nil {
+		t.Fatal(err)
+	}
+	feed.Close()
+
+	entries := entriesByPath(rolie, tlpWhite)
+	if len(entries) != 2 || entries["2022/ACME-2.json"] == nil ||
+		entries["2022/ACME-2.json"].ID != "ACME-2" {
+		t.Errorf("unexpected entries %v", entries)
+	}
+
+	c := &controller{cfg: &config{Web: web, TLPs: []tlp{tlpWhite}}}
+	l, err := newLister(c.cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		query  string
+		want   []string
+		failed []string
+	}{
+		{"all", "", []string{"ACME-1"}, []string{"ACME-2.json", "OTHER-1.json"}},
+		// Advisories which cannot be read are only filtered
+		// by their ids if they are listed in the feed.
+		{"id prefix", "id_prefix=OTHER", nil, []string{"OTHER-1.json"}},
+	} {
+		lf, err := c.listFilterParam(httptest.NewRequest("GET", "/api/list?"+tc.query, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		advisories, err := l.list(lf)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		var ids, failed []string
+		for _, a := range advisories {
+			if a.Error != "" {
+				failed = append(failed, a.Filename)
+			} else {
+				ids = append(ids, a.ID)
+			}
+		}
+		if !reflect.DeepEqual(ids, tc.want) || !reflect.DeepEqual(failed, tc.failed) {
+			t.Errorf("%s: expected %v and failed %v, got %v and %v",
+				tc.name, tc.want, tc.failed, ids, failed)
+		}
+	}
+}
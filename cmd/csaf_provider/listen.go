@@ -0,0 +1,176 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// tlsClientCert returns the TLS client certificate
+// verified when serving the provider itself.
+func tlsClientCert(r *http.Request) *clientCert {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return &clientCert{verify: "NONE"}
+	}
+	if len(r.TLS.VerifiedChains) == 0 {
+		return &clientCert{verify: "FAILED:certificate not verified"}
+	}
+	leaf := r.TLS.VerifiedChains[0][0]
+	return &clientCert{
+		verify:  "SUCCESS",
+		issuer:  leaf.Issuer.String(),
+		subject: leaf.Subject.String(),
+	}
+}
+
+// standalone switches the controller to serve the provider
+// without a webserver and returns the handler to do so.
+// The paths of the requests are routed and the TLS client
+// certificates are taken from the TLS connections.
+func (c *controller) standalone() http.Handler {
+	c.clientCert = tlsClientCert
+
+	pim := newPathInfoMux(urlPath)
+	c.bind(pim)
+
+	if !c.cfg.ServeWeb {
+		return pim
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/.well-known/csaf/", c.webHandler())
+	mux.Handle("/", pim)
+	return mux
+}
+
+// noDirs is a http.FileSystem which refuses to open
+// directories so that they are not listed.
+type noDirs struct{ fs http.FileSystem }
+
+// Open implements the http.FileSystem interface.
+func (nd noDirs) Open(name string) (http.File, error) {
+	f, err := nd.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err == nil && st.IsDir() {
+		err = os.ErrNotExist
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// webHandler serves the .well-known/csaf tree below the web folder.
+// The TLP:AMBER and TLP:RED folders are only served to authenticated
+// clients. Directories are not listed.
+func (c *controller) webHandler() http.Handler {
+	files := http.FileServer(noDirs{http.Dir(c.cfg.Web)})
+	protected := c.auth(files.ServeHTTP)
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rest := strings.TrimPrefix(path.Clean(r.URL.Path), "/.well-known/csaf/")
+		switch tlp(strings.SplitN(rest, "/", 2)[0]) {
+		case tlpAmber, tlpRed:
+			protected(rw, r)
+		default:
+			files.ServeHTTP(rw, r)
+		}
+	})
+}
+
+// serverTLSConfig returns the TLS configuration to serve the provider.
+// Client certificates are verified against the configured CAs.
+func (cfg *config) serverTLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.ClientCAFile == "" {
+		return tlsConfig, nil
+	}
+	pem, err := os.ReadFile(cfg.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in '%s'", cfg.ClientCAFile)
+	}
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	return tlsConfig, nil
+}
+
+// listen serves the provider on the given address
+// until the process is interrupted.
+func listen(c *controller, addr string) error {
+	cfg := c.cfg
+
+	if cfg.CanonicalURLPrefix == "" {
+		return errors.New("canonical_url_prefix is needed to listen")
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return errors.New("both tls_cert_file and tls_key_file must be given")
+	}
+	if cfg.TLSCertFile == "" && cfg.ClientCAFile != "" {
+		return errors.New("client_ca_file needs tls_cert_file and tls_key_file")
+	}
+
+	tlsConfig, err := cfg.serverTLSConfig()
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           c.standalone(),
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: time.Minute,
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+		sig := <-sigs
+		log.Printf("received %s, stopping\n", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Printf("error: %v\n", err)
+		}
+	}()
+
+	if cfg.TLSCertFile == "" {
+		log.Printf("warning: serving without TLS on %s\n", addr)
+		err = srv.ListenAndServe()
+	} else {
+		log.Printf("serving on %s\n", addr)
+		err = srv.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+	}
+	if err != http.ErrServerClosed {
+		return err
+	}
+	// Wait for the running requests.
+	<-done
+	return nil
+}
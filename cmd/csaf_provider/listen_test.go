@@ -0,0 +1,136 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newCert creates a certificate signed by parent or a self-signed CA.
+func newCert(
+	t *testing.T, cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey,
+) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func TestStandalone(t *testing.T) {
+	web := t.TempDir()
+	csafDir := filepath.Join(web, ".well-known", "csaf")
+	if err := os.MkdirAll(csafDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(
+		filepath.Join(csafDir, "provider-metadata.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, label := range []tlp{tlpWhite, tlpAmber} {
+		if err := os.Mkdir(filepath.Join(csafDir, string(label)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(
+			filepath.Join(csafDir, string(label), "index.txt"), []byte("\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ca, caKey := newCert(t, "test ca", nil, nil)
+	other, otherKey := newCert(t, "other ca", nil, nil)
+	client, clientKey := newCert(t, "client", ca, caKey)
+	stranger, strangerKey := newCert(t, "stranger", other, otherKey)
+
+	issuer := ca.Subject.String()
+	cfg := &config{
+		Web:      web,
+		ServeWeb: true,
+		Issuer:   &issuer,
+		TLPs:     []tlp{tlpWhite},
+	}
+	c, err := newController(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewUnstartedServer(c.standalone())
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	pool.AddCert(other)
+	srv.TLS = &tls.Config{ClientCAs: pool, ClientAuth: tls.VerifyClientCertIfGiven}
+	srv.StartTLS()
+	defer srv.Close()
+
+	get := func(path string, cert *x509.Certificate, key *ecdsa.PrivateKey) int {
+		transport := srv.Client().Transport.(*http.Transport).Clone()
+		if cert != nil {
+			transport.TLSClientConfig.Certificates = []tls.Certificate{{
+				Certificate: [][]byte{cert.Raw},
+				PrivateKey:  key,
+			}}
+		}
+		res, err := (&http.Client{Transport: transport}).Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		io.Copy(io.Discard, res.Body)
+		return res.StatusCode
+	}
+
+	for _, tc := range []struct {
+		name string
+		path string
+		cert *x509.Certificate
+		key  *ecdsa.PrivateKey
+		code int
+	}{
+		{"static", "/.well-known/csaf/provider-metadata.json", nil, nil, http.StatusOK},
+		{"white", "/.well-known/csaf/white/index.txt", nil, nil, http.StatusOK},
+		{"amber without certificate", "/.well-known/csaf/amber/index.txt", nil, nil, http.StatusForbidden},
+		{"amber unclean", "/.well-known/csaf/white/../amber/index.txt", nil, nil, http.StatusForbidden},
+		{"amber", "/.well-known/csaf/amber/index.txt", client, clientKey, http.StatusOK},
+		{"directory", "/.well-known/csaf/white/", nil, nil, http.StatusNotFound},
+		{"no certificate", "/", nil, nil, http.StatusForbidden},
+		{"certificate", "/", client, clientKey, http.StatusOK},
+		{"wrong issuer", "/", stranger, strangerKey, http.StatusForbidden},
+		{"unknown path", "/unknown", client, clientKey, http.StatusNotFound},
+	} {
+		if code := get(tc.path, tc.cert, tc.key); code != tc.code {
+			t.Errorf("%s: expected %d, got %d", tc.name, tc.code, code)
+		}
+	}
+}
@@ -19,7 +19,8 @@ import (
 )
 
 type options struct {
-	Version bool `long:"version" description:"Display version of the binary"`
+	Version bool   `long:"version" description:"Display version of the binary"`
+	Listen  string `long:"listen" description:"Serve the provider on this address instead of running as CGI program" value-name:"ADDR"`
 }
 
 func main() {
@@ -33,6 +34,9 @@ func main() {
 
 	cfg, err := loadConfig()
 	if err != nil {
+		if opts.Listen != "" {
+			log.Fatalf("error: %v\n", err)
+		}
 		cgi.Serve(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 			http.Error(rw, fmt.Sprintf("Config error: %v\n", err), http.StatusInternalServerError)
 		}))
@@ -43,7 +47,15 @@ func main() {
 	if err != nil {
 		log.Fatalf("error: %v\n", err)
 	}
-	pim := newPathInfoMux()
+
+	if opts.Listen != "" {
+		if err := listen(c, opts.Listen); err != nil {
+			log.Fatalf("error: %v\n", err)
+		}
+		return
+	}
+
+	pim := newPathInfoMux(cgiPathInfo)
 	c.bind(pim)
 
 	if err := cgi.Serve(pim); err != nil {
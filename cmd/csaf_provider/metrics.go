@@ -0,0 +1,69 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/csaf-poc/csaf_distribution/util"
+)
+
+// newMetrics creates the registry of the metrics of the provider.
+func newMetrics() *util.Metrics {
+	m := util.NewMetrics()
+	m.Counter("csaf_provider_uploads_total",
+		"Number of successfully uploaded advisories.")
+	m.Counter("csaf_provider_rejections_total",
+		"Number of rejected uploads by reason.")
+	m.Counter("csaf_provider_transactions_total",
+		"Number of transactions.")
+	m.Counter("csaf_provider_transaction_seconds_total",
+		"Total duration of the transactions.")
+	m.Gauge("csaf_provider_last_transaction_duration_seconds",
+		"Duration of the last transaction.")
+	return m
+}
+
+// updateMetrics updates the metrics file if one is configured.
+// As the provider runs as a CGI the metrics are kept in the file.
+func (c *controller) updateMetrics(fn func(*util.Metrics)) {
+	if c.cfg.MetricsFile == "" {
+		return
+	}
+	if err := newMetrics().UpdateFile(c.cfg.MetricsFile, fn); err != nil {
+		log.Printf("error: updating metrics failed: %v\n", err)
+	}
+}
+
+// recordTransaction records the duration of a transaction.
+func recordTransaction(m *util.Metrics, took time.Duration) {
+	m.Add("csaf_provider_transactions_total", 1)
+	m.Add("csaf_provider_transaction_seconds_total", took.Seconds())
+	m.Set("csaf_provider_last_transaction_duration_seconds", took.Seconds())
+}
+
+// recordOutcome records if an advisory was uploaded or rejected.
+func recordOutcome(m *util.Metrics, t tlp, reason string, err error) {
+	if err != nil {
+		m.Add("csaf_provider_rejections_total", 1, "reason", reason)
+		return
+	}
+	m.Add("csaf_provider_uploads_total", 1, "tlp", string(t))
+}
+
+// recordUpload records the outcome of an upload.
+func (c *controller) recordUpload(t tlp, reason string, took time.Duration, err error) {
+	c.updateMetrics(func(m *util.Metrics) {
+		if took > 0 {
+			recordTransaction(m, took)
+		}
+		recordOutcome(m, t, reason, err)
+	})
+}
@@ -16,14 +16,26 @@ import (
 
 type pathInfoMux struct {
 	routes map[string]http.Handler
+	// pathInfo returns the path of a request to route by.
+	pathInfo func(*http.Request) string
 }
 
-func newPathInfoMux() *pathInfoMux {
-	return &pathInfoMux{routes: map[string]http.Handler{}}
+// cgiPathInfo returns the PATH_INFO of the current CGI request.
+func cgiPathInfo(*http.Request) string {
+	return os.Getenv("PATH_INFO")
+}
+
+// urlPath returns the path of the URL of a request.
+func urlPath(req *http.Request) string {
+	return req.URL.Path
+}
+
+func newPathInfoMux(pathInfo func(*http.Request) string) *pathInfoMux {
+	return &pathInfoMux{routes: map[string]http.Handler{}, pathInfo: pathInfo}
 }
 
 func (pim *pathInfoMux) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
-	pi := os.Getenv("PATH_INFO")
+	pi := pim.pathInfo(req)
 	if h, ok := pim.routes[pi]; ok {
 		h.ServeHTTP(rw, req)
 		return
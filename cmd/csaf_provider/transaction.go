@@ -9,18 +9,64 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/csaf-poc/csaf_distribution/csaf"
 	"github.com/csaf-poc/csaf_distribution/util"
+	"github.com/gofrs/flock"
 )
 
+// errBusy is returned if the lock for a transaction
+// could not be acquired in time.
+var errBusy = errors.New("another upload is in progress, try again later")
+
+// lockRetryDelay is the delay between the attempts to acquire the lock.
+const lockRetryDelay = 50 * time.Millisecond
+
+// lock serializes the transactions of concurrently running
+// uploads with a lock file. It waits up to the configured
+// lock timeout for running transactions to finish.
+func (cfg *config) lock(fn func() error) error {
+	if err := os.MkdirAll(filepath.Dir(cfg.LockFile), 0755); err != nil {
+		return err
+	}
+	fl := flock.New(cfg.LockFile)
+
+	ctx, cancel := context.WithTimeout(
+		context.Background(), time.Duration(*cfg.LockTimeout))
+	defer cancel()
+
+	locked, err := fl.TryLockContext(ctx, lockRetryDelay)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return errBusy
+	case err != nil:
+		return fmt.Errorf("file locking failed: %v", err)
+	case !locked:
+		return errBusy
+	}
+	defer fl.Unlock()
+	return fn()
+}
+
 func doTransaction(
 	cfg *config,
 	t tlp,
 	fn func(string, *csaf.ProviderMetadata) error,
 ) error {
+	return cfg.lock(func() error { return transaction(cfg, t, fn) })
+}
+
+func transaction(
+	cfg *config,
+	t tlp,
+	fn func(string, *csaf.ProviderMetadata) error,
+) error {
 
 	wellknown := filepath.Join(cfg.Web, ".well-known", "csaf")
 
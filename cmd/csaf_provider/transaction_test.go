@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLock(t *testing.T) {
+	timeout := duration(100 * time.Millisecond)
+	cfg := &config{
+		LockFile:    filepath.Join(t.TempDir(), "folder", ".lock"),
+		LockTimeout: &timeout,
+	}
+
+	locked := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+
+	go func() {
+		done <- cfg.lock(func() error {
+			close(locked)
+			<-release
+			return nil
+		})
+	}()
+	<-locked
+
+	if err := cfg.lock(func() error { return nil }); !errors.Is(err, errBusy) {
+		t.Fatalf("expected busy error, got %v", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	var called bool
+	if err := cfg.lock(func() error { called = true; return nil }); err != nil || !called {
+		t.Fatalf("expected lock to be free, got %v", err)
+	}
+}
@@ -32,11 +32,14 @@ import (
 
 // The supported flag options of the uploader command line
 type options struct {
-	Action         string `short:"a" long:"action" choice:"upload" choice:"create" default:"upload" description:"Action to perform"`
-	URL            string `short:"u" long:"url" description:"URL of the CSAF provider" default:"https://localhost/cgi-bin/csaf_provider.go" value-name:"URL"`
-	TLP            string `short:"t" long:"tlp" choice:"csaf" choice:"white" choice:"green" choice:"amber" choice:"red" default:"csaf" description:"TLP of the feed"`
-	ExternalSigned bool   `short:"x" long:"external-signed" description:"CSAF files are signed externally. Assumes .asc files beside CSAF files."`
-	NoSchemaCheck  bool   `short:"s" long:"no-schema-check" description:"Do not check files against CSAF JSON schema locally."`
+	Action         string   `short:"a" long:"action" choice:"upload" choice:"create" default:"upload" description:"Action to perform"`
+	URL            string   `short:"u" long:"url" description:"URL of the CSAF provider" default:"https://localhost/cgi-bin/csaf_provider.go" value-name:"URL"`
+	TLP            string   `short:"t" long:"tlp" choice:"csaf" choice:"white" choice:"green" choice:"amber" choice:"red" default:"csaf" description:"TLP of the feed"`
+	ExternalSigned bool     `short:"x" long:"external-signed" description:"CSAF files are signed externally. Assumes .asc files beside CSAF files."`
+	NoSchemaCheck  bool     `short:"s" long:"no-schema-check" description:"Do not check files against CSAF JSON schema locally."`
+	MandatoryTests bool     `short:"m" long:"mandatory-tests" description:"Run the mandatory tests of the CSAF standard on the files locally."`
+	OptionalTests  bool     `long:"optional-tests" description:"Run the optional and informative tests of the CSAF standard on the files locally."`
+	EnforceTests   []string `long:"enforce-test" description:"Treat findings of this optional or informative test as errors (can be given multiple times)." value-name:"TEST-ID"`
 
 	Key        *string `short:"k" long:"key" description:"OpenPGP key to sign the CSAF files" value-name:"KEY-FILE"`
 	Password   *string `short:"p" long:"password" description:"Authentication password for accessing the CSAF provider" value-name:"PASSWORD"`
@@ -197,18 +200,38 @@ func (p *processor) uploadRequest(filename string) (*http.Request, error) {
 		return nil, err
 	}
 
-	if !p.opts.NoSchemaCheck {
+	tests := csaf.TestConfig{
+		Mandatory: p.opts.MandatoryTests,
+		Optional:  p.opts.OptionalTests,
+		Enforce:   p.opts.EnforceTests,
+	}
+
+	if !p.opts.NoSchemaCheck || tests.Enabled() {
 		var doc interface{}
 		if err := json.NewDecoder(bytes.NewReader(data)).Decode(&doc); err != nil {
 			return nil, err
 		}
-		errs, err := csaf.ValidateCSAF(doc)
-		if err != nil {
-			return nil, err
+		if !p.opts.NoSchemaCheck {
+			errs, err := csaf.ValidateCSAF(doc)
+			if err != nil {
+				return nil, err
+			}
+			if len(errs) > 0 {
+				writeStrings("Errors:", errs)
+				return nil, errors.New("local schema check failed")
+			}
 		}
-		if len(errs) > 0 {
-			writeStrings("Errors:", errs)
-			return nil, errors.New("local schema check failed")
+		if tests.Enabled() {
+			results, err := tests.Run(doc)
+			if err != nil {
+				return nil, err
+			}
+			writeStrings("Infos:", results.BySeverity(csaf.TestInfo).Strings())
+			writeStrings("Warnings:", results.BySeverity(csaf.TestWarning).Strings())
+			if errs := results.BySeverity(csaf.TestError); len(errs) > 0 {
+				writeStrings("Errors:", errs.Strings())
+				return nil, errors.New("local tests failed")
+			}
 		}
 	}
 
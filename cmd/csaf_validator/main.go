@@ -0,0 +1,132 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+// Implements a command line tool that validates local CSAF documents.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/csaf-poc/csaf_distribution/csaf"
+	"github.com/csaf-poc/csaf_distribution/util"
+	"github.com/jessevdk/go-flags"
+)
+
+type options struct {
+	Format      string   `short:"f" long:"format" choice:"text" choice:"json" description:"Format of the output" default:"text"`
+	Quiet       bool     `short:"q" long:"quiet" description:"Do not print anything, only report the result with the exit code"`
+	NoMandatory bool     `long:"no-mandatory-tests" description:"Do not run the mandatory tests of the CSAF standard on the files"`
+	Optional    bool     `long:"optional-tests" description:"Run the optional and informative tests of the CSAF standard on the files"`
+	Enforce     []string `long:"enforce-test" description:"Treat findings of this optional or informative test as errors (can be given multiple times)" value-name:"TEST-ID"`
+	Version     bool     `long:"version" description:"Display version of the binary"`
+}
+
+// tests returns the configuration of the CSAF tests run on the files.
+func (o *options) tests() *csaf.TestConfig {
+	return &csaf.TestConfig{
+		Mandatory: !o.NoMandatory,
+		Optional:  o.Optional,
+		Enforce:   o.Enforce,
+	}
+}
+
+func errCheck(err error) {
+	if err != nil {
+		if e, ok := err.(*flags.Error); ok && e.Type == flags.ErrHelp {
+			os.Exit(0)
+		}
+		log.Fatalf("error: %v\n", err)
+	}
+}
+
+// writeText writes a human readable form of the results to w.
+func writeText(results []*fileResult, w io.Writer) error {
+	for _, r := range results {
+		var status string
+		if r.Valid() {
+			status = "valid"
+		} else {
+			status = "invalid"
+		}
+		if _, err := fmt.Fprintf(w, "%s: %s\n", r.Filename, status); err != nil {
+			return err
+		}
+		for _, msg := range r.Errors {
+			if _, err := fmt.Fprintf(w, "  error: %s\n", msg); err != nil {
+				return err
+			}
+		}
+		for _, t := range r.Tests {
+			if _, err := fmt.Fprintf(w, "  %s: %s\n", t.Severity, t.String()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// exitCode returns the exit code for the results:
+// 0 if all files are valid and 1 otherwise.
+func exitCode(results []*fileResult) int {
+	for _, r := range results {
+		if !r.Valid() {
+			return 1
+		}
+	}
+	return 0
+}
+
+// writeJSON writes the JSON encoding of the results to w.
+func writeJSON(results []*fileResult, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
+
+func main() {
+	opts := new(options)
+
+	parser := flags.NewParser(opts, flags.Default)
+	parser.Usage = "[OPTIONS] files or directories..."
+	args, err := parser.Parse()
+	errCheck(err)
+
+	if opts.Version {
+		fmt.Println(util.SemVersion)
+		return
+	}
+
+	if len(args) == 0 {
+		log.Println("No files given.")
+		return
+	}
+
+	files, err := collectFiles(args)
+	errCheck(err)
+
+	results := newValidator(opts).validate(files)
+
+	if !opts.Quiet {
+		var writer func([]*fileResult, io.Writer) error
+		switch opts.Format {
+		case "json":
+			writer = writeJSON
+		default:
+			writer = writeText
+		}
+		errCheck(writer(results, os.Stdout))
+	}
+
+	if code := exitCode(results); code != 0 {
+		os.Exit(code)
+	}
+}
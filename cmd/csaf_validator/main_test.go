@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestWriteJSON(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{
+		writeFile(t, dir, "acme-2022-0001.json", testAdvisory("ACME-2022-0001", "1")),
+		writeFile(t, dir, "acme-2022-0002.json", testAdvisory("ACME-2022-0002", "2")),
+	}
+
+	var buf bytes.Buffer
+	if err := writeJSON(newValidator(&options{}).validate(files), &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []struct {
+		Filename string   `json:"filename"`
+		Errors   []string `json:"errors"`
+		Tests    []struct {
+			ID       string `json:"id"`
+			Severity string `json:"severity"`
+			Pointer  string `json:"pointer"`
+			Message  string `json:"message"`
+		} `json:"tests"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON output: %v\n%s", err, buf.String())
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(got))
+	}
+	if got[0].Filename != files[0] || len(got[0].Errors) != 0 || len(got[0].Tests) != 0 {
+		t.Errorf("unexpected result for valid file: %+v", got[0])
+	}
+	if got[1].Filename != files[1] || len(got[1].Tests) != 1 {
+		t.Fatalf("unexpected result for invalid file: %+v", got[1])
+	}
+	if tr := got[1].Tests[0]; tr.ID != "6.1.16" || tr.Severity != "error" ||
+		tr.Pointer != "/document/tracking/version" || tr.Message == "" {
+		t.Errorf("unexpected finding: %+v", tr)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	dir := t.TempDir()
+	var (
+		valid    = writeFile(t, dir, "acme-2022-0001.json", testAdvisory("ACME-2022-0001", "1"))
+		mismatch = writeFile(t, dir, "acme-2022-0003.json", testAdvisory("ACME-2022-0002", "1"))
+		failing  = writeFile(t, dir, "acme-2022-0004.json", testAdvisory("ACME-2022-0004", "2"))
+	)
+
+	for _, x := range []struct {
+		name  string
+		opts  options
+		files []string
+		code  int
+	}{
+		{"none", options{}, nil, 0},
+		{"valid", options{}, []string{valid}, 0},
+		{"valid and mismatch", options{}, []string{valid, mismatch}, 1},
+		{"valid and failing", options{}, []string{valid, failing}, 1},
+		{"failing without mandatory tests", options{NoMandatory: true}, []string{valid, failing}, 0},
+		{"all", options{NoMandatory: true}, []string{valid, mismatch, failing}, 1},
+	} {
+		results := newValidator(&x.opts).validate(x.files)
+		if code := exitCode(results); code != x.code {
+			t.Errorf("%s: expected exit code %d, got %d", x.name, x.code, code)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/csaf-poc/csaf_distribution/csaf"
+	"github.com/csaf-poc/csaf_distribution/util"
+)
+
+// fileResult is the result of the validation of a single file.
+type fileResult struct {
+	Filename string           `json:"filename"`
+	Errors   []string         `json:"errors,omitempty"`
+	Tests    csaf.TestResults `json:"tests,omitempty"`
+}
+
+// Valid returns true if the file has neither errors
+// nor test findings reported as errors.
+func (fr *fileResult) Valid() bool {
+	return len(fr.Errors) == 0 && len(fr.Tests.BySeverity(csaf.TestError)) == 0
+}
+
+func (fr *fileResult) error(format string, args ...interface{}) {
+	fr.Errors = append(fr.Errors, fmt.Sprintf(format, args...))
+}
+
+type validator struct {
+	tests *csaf.TestConfig
+	expr  *util.PathEval
+}
+
+func newValidator(opts *options) *validator {
+	return &validator{
+		tests: opts.tests(),
+		expr:  util.NewPathEval(),
+	}
+}
+
+// collectFiles expands the given arguments to a list of files.
+// Directories are searched recursively for '.json' files.
+func collectFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		fi, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		if err := filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.Type().IsRegular() && strings.HasSuffix(path, ".json") {
+				files = append(files, path)
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// validate validates the given files.
+func (v *validator) validate(files []string) []*fileResult {
+	results := make([]*fileResult, 0, len(files))
+	for _, file := range files {
+		results = append(results, v.validateFile(file))
+	}
+	return results
+}
+
+// validateFile checks a file against the JSON schema, checks if its
+// name is confirming to the standard and runs the configured tests.
+func (v *validator) validateFile(file string) *fileResult {
+
+	result := &fileResult{Filename: file}
+
+	var doc interface{}
+	if err := func() error {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return json.NewDecoder(f).Decode(&doc)
+	}(); err != nil {
+		result.error("%v", err)
+		return result
+	}
+
+	errors, err := csaf.ValidateCSAF(doc)
+	if err != nil {
+		result.error("validating against JSON schema failed: %v", err)
+		return result
+	}
+	for _, msg := range errors {
+		result.error("schema: %s", msg)
+	}
+
+	// Check the filename.
+	filename := filepath.Base(file)
+	if !util.ConfirmingFileName(filename) {
+		result.error("filename %q is not confirming to the standard", filename)
+	}
+	var id string
+	if err := v.expr.Extract(
+		`$.document.tracking.id`, util.StringMatcher(&id), false, doc,
+	); err == nil {
+		if expected := util.CleanFileName(id); filename != expected {
+			result.error("filename %q does not match tracking id %q (expected %q)",
+				filename, id, expected)
+		}
+	}
+
+	if v.tests.Enabled() {
+		tests, err := v.tests.Run(doc)
+		if err != nil {
+			result.error("running tests failed: %v", err)
+			return result
+		}
+		result.Tests = tests
+	}
+
+	return result
+}
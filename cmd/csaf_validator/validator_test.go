@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testAdvisory returns a minimal advisory with the given
+// tracking id and version.
+func testAdvisory(id, version string) string {
+	return fmt.Sprintf(`{"document": {
+  "category": "csaf_base",
+  "csaf_version": "2.0",
+  "distribution": {"tlp": {"label": "WHITE"}},
+  "publisher": {"category": "vendor", "name": "ACME", "namespace": "https://example.com"},
+  "title": "Test",
+  "tracking": {
+    "id": "%s", "status": "final", "version": "%s",
+    "initial_release_date": "2022-01-01T00:00:00Z",
+    "current_release_date": "2022-01-01T00:00:00Z",
+    "revision_history": [{"date": "2022-01-01T00:00:00Z", "number": "1", "summary": "Initial."}]
+  }
+}}`, id, version)
+}
+
+// writeFile writes content to the file name in dir.
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	fname := filepath.Join(dir, name)
+	if err := os.WriteFile(fname, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return fname
+}
+
+func TestValidateFile(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, x := range []struct {
+		name        string
+		filename    string
+		content     string
+		noMandatory bool
+		errors      []string
+		tests       []string
+	}{
+		{
+			name:     "valid",
+			filename: "acme-2022-0001.json",
+			content:  testAdvisory("ACME-2022-0001", "1"),
+		}, {
+			name:     "cleaned tracking id",
+			filename: "acme_2022_0001.json",
+			content:  testAdvisory("ACME:2022/0001", "1"),
+		}, {
+			name:     "tracking id mismatch",
+			filename: "acme-2022-0002.json",
+			content:  testAdvisory("ACME-2022-0001", "1"),
+			errors:   []string{`does not match tracking id "ACME-2022-0001" (expected "acme-2022-0001.json")`},
+		}, {
+			name:     "not confirming filename",
+			filename: "ACME-2022-0001.json",
+			content:  testAdvisory("ACME-2022-0001", "1"),
+			errors: []string{
+				`filename "ACME-2022-0001.json" is not confirming`,
+				`does not match tracking id`,
+			},
+		}, {
+			name:     "no JSON",
+			filename: "acme-2022-0001.json",
+			content:  "{",
+			errors:   []string{"unexpected EOF"},
+		}, {
+			name:     "schema",
+			filename: "acme-2022-0001.json",
+			content:  strings.Replace(testAdvisory("ACME-2022-0001", "1"), `"title": "Test",`, "", 1),
+			errors:   []string{"schema: ", "schema: /document: missing properties: 'title'"},
+		}, {
+			name:     "mandatory tests",
+			filename: "acme-2022-0001.json",
+			content:  testAdvisory("ACME-2022-0001", "2"),
+			tests:    []string{"6.1.16"},
+		}, {
+			name:        "no mandatory tests",
+			filename:    "acme-2022-0001.json",
+			content:     testAdvisory("ACME-2022-0001", "2"),
+			noMandatory: true,
+		},
+	} {
+		fname := writeFile(t, dir, x.filename, x.content)
+		v := newValidator(&options{NoMandatory: x.noMandatory})
+		result := v.validateFile(fname)
+		os.Remove(fname)
+
+		if len(result.Errors) != len(x.errors) {
+			t.Errorf("%s: expected %d errors, got %q", x.name, len(x.errors), result.Errors)
+		} else {
+			for i, e := range x.errors {
+				if !strings.Contains(result.Errors[i], e) {
+					t.Errorf("%s: expected error %q, got %q", x.name, e, result.Errors[i])
+				}
+			}
+		}
+
+		var ids []string
+		for _, r := range result.Tests {
+			ids = append(ids, r.ID)
+		}
+		if strings.Join(ids, " ") != strings.Join(x.tests, " ") {
+			t.Errorf("%s: expected findings %v, got %v", x.name, x.tests, result.Tests.Strings())
+		}
+
+		if valid := len(x.errors) == 0 && len(x.tests) == 0; result.Valid() != valid {
+			t.Errorf("%s: expected valid %t", x.name, valid)
+		}
+	}
+}
@@ -0,0 +1,636 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package csaf
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/csaf-poc/csaf_distribution/util"
+)
+
+// DateTime is a date-time value in a CSAF document.
+// It is kept in its textual representation to allow
+// lossless round trips of the documents.
+type DateTime string
+
+// Time parses the date-time value as RFC3339.
+func (dt DateTime) Time() (time.Time, error) {
+	return time.Parse(time.RFC3339, string(dt))
+}
+
+// Lang is a language tag in the sense of BCP 47.
+type Lang string
+
+// RevisionNumber is a version number in the tracking
+// section. Either an integer or a semantic version.
+type RevisionNumber string
+
+// ProductID is a reference token for product instances.
+type ProductID string
+
+// ProductGroupID is a reference token for product group instances.
+type ProductGroupID string
+
+// Products is a list of product ids.
+type Products []ProductID
+
+// ProductGroups is a list of product group ids.
+type ProductGroups []ProductGroupID
+
+// DocumentStatus is the status of a document in the tracking section.
+type DocumentStatus string
+
+const (
+	// DocumentStatusDraft is the "draft" status.
+	DocumentStatusDraft DocumentStatus = "draft"
+	// DocumentStatusFinal is the "final" status.
+	DocumentStatusFinal DocumentStatus = "final"
+	// DocumentStatusInterim is the "interim" status.
+	DocumentStatusInterim DocumentStatus = "interim"
+)
+
+// NoteCategory is the category of a note.
+type NoteCategory string
+
+const (
+	// NoteCategoryDescription is the "description" category.
+	NoteCategoryDescription NoteCategory = "description"
+	// NoteCategoryDetails is the "details" category.
+	NoteCategoryDetails NoteCategory = "details"
+	// NoteCategoryFAQ is the "faq" category.
+	NoteCategoryFAQ NoteCategory = "faq"
+	// NoteCategoryGeneral is the "general" category.
+	NoteCategoryGeneral NoteCategory = "general"
+	// NoteCategoryLegalDisclaimer is the "legal_disclaimer" category.
+	NoteCategoryLegalDisclaimer NoteCategory = "legal_disclaimer"
+	// NoteCategoryOther is the "other" category.
+	NoteCategoryOther NoteCategory = "other"
+	// NoteCategorySummary is the "summary" category.
+	NoteCategorySummary NoteCategory = "summary"
+)
+
+// ReferenceCategory is the category of a reference.
+type ReferenceCategory string
+
+const (
+	// ReferenceCategoryExternal is the "external" category.
+	ReferenceCategoryExternal ReferenceCategory = "external"
+	// ReferenceCategorySelf is the "self" category.
+	ReferenceCategorySelf ReferenceCategory = "self"
+)
+
+// BranchCategory is the category of a branch in the product tree.
+type BranchCategory string
+
+const (
+	// BranchCategoryArchitecture is the "architecture" category.
+	BranchCategoryArchitecture BranchCategory = "architecture"
+	// BranchCategoryHostName is the "host_name" category.
+	BranchCategoryHostName BranchCategory = "host_name"
+	// BranchCategoryLanguage is the "language" category.
+	BranchCategoryLanguage BranchCategory = "language"
+	// BranchCategoryLegacy is the "legacy" category.
+	BranchCategoryLegacy BranchCategory = "legacy"
+	// BranchCategoryPatchLevel is the "patch_level" category.
+	BranchCategoryPatchLevel BranchCategory = "patch_level"
+	// BranchCategoryProductFamily is the "product_family" category.
+	BranchCategoryProductFamily BranchCategory = "product_family"
+	// BranchCategoryProductName is the "product_name" category.
+	BranchCategoryProductName BranchCategory = "product_name"
+	// BranchCategoryProductVersion is the "product_version" category.
+	BranchCategoryProductVersion BranchCategory = "product_version"
+	// BranchCategoryProductVersionRange is the "product_version_range" category.
+	BranchCategoryProductVersionRange BranchCategory = "product_version_range"
+	// BranchCategoryServicePack is the "service_pack" category.
+	BranchCategoryServicePack BranchCategory = "service_pack"
+	// BranchCategorySpecification is the "specification" category.
+	BranchCategorySpecification BranchCategory = "specification"
+	// BranchCategoryVendor is the "vendor" category.
+	BranchCategoryVendor BranchCategory = "vendor"
+)
+
+// RelationshipCategory is the category of a relationship in the product tree.
+type RelationshipCategory string
+
+const (
+	// RelationshipCategoryDefaultComponentOf is the "default_component_of" category.
+	RelationshipCategoryDefaultComponentOf RelationshipCategory = "default_component_of"
+	// RelationshipCategoryExternalComponentOf is the "external_component_of" category.
+	RelationshipCategoryExternalComponentOf RelationshipCategory = "external_component_of"
+	// RelationshipCategoryInstalledOn is the "installed_on" category.
+	RelationshipCategoryInstalledOn RelationshipCategory = "installed_on"
+	// RelationshipCategoryInstalledWith is the "installed_with" category.
+	RelationshipCategoryInstalledWith RelationshipCategory = "installed_with"
+	// RelationshipCategoryOptionalComponentOf is the "optional_component_of" category.
+	RelationshipCategoryOptionalComponentOf RelationshipCategory = "optional_component_of"
+)
+
+// FlagLabel is the label of a flag of a vulnerability.
+type FlagLabel string
+
+const (
+	// FlagLabelComponentNotPresent is the "component_not_present" label.
+	FlagLabelComponentNotPresent FlagLabel = "component_not_present"
+	// FlagLabelInlineMitigationsAlreadyExist is the "inline_mitigations_already_exist" label.
+	FlagLabelInlineMitigationsAlreadyExist FlagLabel = "inline_mitigations_already_exist"
+	// FlagLabelVulnerableCodeCannotBeControlledByAdversary is the
+	// "vulnerable_code_cannot_be_controlled_by_adversary" label.
+	FlagLabelVulnerableCodeCannotBeControlledByAdversary FlagLabel = "vulnerable_code_cannot_be_controlled_by_adversary"
+	// FlagLabelVulnerableCodeNotInExecutePath is the "vulnerable_code_not_in_execute_path" label.
+	FlagLabelVulnerableCodeNotInExecutePath FlagLabel = "vulnerable_code_not_in_execute_path"
+	// FlagLabelVulnerableCodeNotPresent is the "vulnerable_code_not_present" label.
+	FlagLabelVulnerableCodeNotPresent FlagLabel = "vulnerable_code_not_present"
+)
+
+// InvolvementParty is the type of a party in an involvement.
+type InvolvementParty string
+
+const (
+	// InvolvementPartyCoordinator is the "coordinator" party.
+	InvolvementPartyCoordinator InvolvementParty = "coordinator"
+	// InvolvementPartyDiscoverer is the "discoverer" party.
+	InvolvementPartyDiscoverer InvolvementParty = "discoverer"
+	// InvolvementPartyOther is the "other" party.
+	InvolvementPartyOther InvolvementParty = "other"
+	// InvolvementPartyUser is the "user" party.
+	InvolvementPartyUser InvolvementParty = "user"
+	// InvolvementPartyVendor is the "vendor" party.
+	InvolvementPartyVendor InvolvementParty = "vendor"
+)
+
+// InvolvementStatus is the status of an involvement.
+type InvolvementStatus string
+
+const (
+	// InvolvementStatusCompleted is the "completed" status.
+	InvolvementStatusCompleted InvolvementStatus = "completed"
+	// InvolvementStatusContactAttempted is the "contact_attempted" status.
+	InvolvementStatusContactAttempted InvolvementStatus = "contact_attempted"
+	// InvolvementStatusDisputed is the "disputed" status.
+	InvolvementStatusDisputed InvolvementStatus = "disputed"
+	// InvolvementStatusInProgress is the "in_progress" status.
+	InvolvementStatusInProgress InvolvementStatus = "in_progress"
+	// InvolvementStatusNotContacted is the "not_contacted" status.
+	InvolvementStatusNotContacted InvolvementStatus = "not_contacted"
+	// InvolvementStatusOpen is the "open" status.
+	InvolvementStatusOpen InvolvementStatus = "open"
+)
+
+// RemediationCategory is the category of a remediation.
+type RemediationCategory string
+
+const (
+	// RemediationCategoryMitigation is the "mitigation" category.
+	RemediationCategoryMitigation RemediationCategory = "mitigation"
+	// RemediationCategoryNoFixPlanned is the "no_fix_planned" category.
+	RemediationCategoryNoFixPlanned RemediationCategory = "no_fix_planned"
+	// RemediationCategoryNoneAvailable is the "none_available" category.
+	RemediationCategoryNoneAvailable RemediationCategory = "none_available"
+	// RemediationCategoryVendorFix is the "vendor_fix" category.
+	RemediationCategoryVendorFix RemediationCategory = "vendor_fix"
+	// RemediationCategoryWorkaround is the "workaround" category.
+	RemediationCategoryWorkaround RemediationCategory = "workaround"
+)
+
+// RestartRequiredCategory is the category of a required restart.
+type RestartRequiredCategory string
+
+const (
+	// RestartRequiredCategoryConnected is the "connected" category.
+	RestartRequiredCategoryConnected RestartRequiredCategory = "connected"
+	// RestartRequiredCategoryDependencies is the "dependencies" category.
+	RestartRequiredCategoryDependencies RestartRequiredCategory = "dependencies"
+	// RestartRequiredCategoryMachine is the "machine" category.
+	RestartRequiredCategoryMachine RestartRequiredCategory = "machine"
+	// RestartRequiredCategoryNone is the "none" category.
+	RestartRequiredCategoryNone RestartRequiredCategory = "none"
+	// RestartRequiredCategoryParent is the "parent" category.
+	RestartRequiredCategoryParent RestartRequiredCategory = "parent"
+	// RestartRequiredCategoryService is the "service" category.
+	RestartRequiredCategoryService RestartRequiredCategory = "service"
+	// RestartRequiredCategorySystem is the "system" category.
+	RestartRequiredCategorySystem RestartRequiredCategory = "system"
+	// RestartRequiredCategoryVulnerableComponent is the "vulnerable_component" category.
+	RestartRequiredCategoryVulnerableComponent RestartRequiredCategory = "vulnerable_component"
+	// RestartRequiredCategoryZone is the "zone" category.
+	RestartRequiredCategoryZone RestartRequiredCategory = "zone"
+)
+
+// ThreatCategory is the category of a threat.
+type ThreatCategory string
+
+const (
+	// ThreatCategoryExploitStatus is the "exploit_status" category.
+	ThreatCategoryExploitStatus ThreatCategory = "exploit_status"
+	// ThreatCategoryImpact is the "impact" category.
+	ThreatCategoryImpact ThreatCategory = "impact"
+	// ThreatCategoryTargetSet is the "target_set" category.
+	ThreatCategoryTargetSet ThreatCategory = "target_set"
+)
+
+// Acknowledgment reflects an entry of an 'acknowledgments' list.
+type Acknowledgment struct {
+	Names        []string `json:"names,omitempty"`
+	Organization *string  `json:"organization,omitempty"`
+	Summary      *string  `json:"summary,omitempty"`
+	URLs         []string `json:"urls,omitempty"`
+}
+
+// AggregateSeverity reflects the 'document.aggregate_severity' object.
+type AggregateSeverity struct {
+	Namespace *string `json:"namespace,omitempty"`
+	Text      *string `json:"text"` // required
+}
+
+// TLP reflects the 'document.distribution.tlp' object.
+type TLP struct {
+	Label *TLPLabel `json:"label"` // required
+	URL   *string   `json:"url,omitempty"`
+}
+
+// DocumentDistribution reflects the 'document.distribution' object.
+type DocumentDistribution struct {
+	Text *string `json:"text,omitempty"`
+	TLP  *TLP    `json:"tlp,omitempty"`
+}
+
+// Note reflects an entry of a 'notes' list.
+type Note struct {
+	Audience *string       `json:"audience,omitempty"`
+	Category *NoteCategory `json:"category"` // required
+	Text     *string       `json:"text"`     // required
+	Title    *string       `json:"title,omitempty"`
+}
+
+// Reference reflects an entry of a 'references' list.
+type Reference struct {
+	Category *ReferenceCategory `json:"category,omitempty"`
+	Summary  *string            `json:"summary"` // required
+	URL      *string            `json:"url"`     // required
+}
+
+// Engine reflects the 'document.tracking.generator.engine' object.
+type Engine struct {
+	Name    *string `json:"name"` // required
+	Version *string `json:"version,omitempty"`
+}
+
+// Generator reflects the 'document.tracking.generator' object.
+type Generator struct {
+	Date   *DateTime `json:"date,omitempty"`
+	Engine *Engine   `json:"engine"` // required
+}
+
+// Revision reflects an entry of 'document.tracking.revision_history'.
+type Revision struct {
+	Date          *DateTime       `json:"date"` // required
+	LegacyVersion *string         `json:"legacy_version,omitempty"`
+	Number        *RevisionNumber `json:"number"`  // required
+	Summary       *string         `json:"summary"` // required
+}
+
+// Tracking reflects the 'document.tracking' object.
+type Tracking struct {
+	Aliases            []string        `json:"aliases,omitempty"`
+	CurrentReleaseDate *DateTime       `json:"current_release_date"` // required
+	Generator          *Generator      `json:"generator,omitempty"`
+	ID                 *string         `json:"id"`                   // required
+	InitialReleaseDate *DateTime       `json:"initial_release_date"` // required
+	RevisionHistory    []*Revision     `json:"revision_history"`     // required
+	Status             *DocumentStatus `json:"status"`               // required
+	Version            *RevisionNumber `json:"version"`              // required
+}
+
+// Document reflects the 'document' object of an advisory.
+type Document struct {
+	Acknowledgments   []*Acknowledgment     `json:"acknowledgments,omitempty"`
+	AggregateSeverity *AggregateSeverity    `json:"aggregate_severity,omitempty"`
+	Category          *string               `json:"category"`     // required
+	CSAFVersion       *string               `json:"csaf_version"` // required
+	Distribution      *DocumentDistribution `json:"distribution,omitempty"`
+	Lang              *Lang                 `json:"lang,omitempty"`
+	Notes             []*Note               `json:"notes,omitempty"`
+	Publisher         *Publisher            `json:"publisher"` // required
+	References        []*Reference          `json:"references,omitempty"`
+	SourceLang        *Lang                 `json:"source_lang,omitempty"`
+	Title             *string               `json:"title"`    // required
+	Tracking          *Tracking             `json:"tracking"` // required
+}
+
+// FileHash reflects an entry of 'file_hashes'.
+type FileHash struct {
+	Algorithm *string `json:"algorithm"` // required
+	Value     *string `json:"value"`     // required
+}
+
+// Hashes reflects an entry of 'product_identification_helper.hashes'.
+type Hashes struct {
+	FileHashes []*FileHash `json:"file_hashes"` // required
+	Filename   *string     `json:"filename"`    // required
+}
+
+// XGenericURI reflects an entry of 'product_identification_helper.x_generic_uris'.
+type XGenericURI struct {
+	Namespace *string `json:"namespace"` // required
+	URI       *string `json:"uri"`       // required
+}
+
+// ProductIdentificationHelper reflects the 'product_identification_helper' object.
+type ProductIdentificationHelper struct {
+	CPE           *string        `json:"cpe,omitempty"`
+	Hashes        []*Hashes      `json:"hashes,omitempty"`
+	ModelNumbers  []string       `json:"model_numbers,omitempty"`
+	PURL          *string        `json:"purl,omitempty"`
+	SBOMURLs      []string       `json:"sbom_urls,omitempty"`
+	SerialNumbers []string       `json:"serial_numbers,omitempty"`
+	SKUs          []string       `json:"skus,omitempty"`
+	XGenericURIs  []*XGenericURI `json:"x_generic_uris,omitempty"`
+}
+
+// FullProductName reflects a 'full_product_name_t' object.
+type FullProductName struct {
+	Name                        *string                      `json:"name"`       // required
+	ProductID                   *ProductID                   `json:"product_id"` // required
+	ProductIdentificationHelper *ProductIdentificationHelper `json:"product_identification_helper,omitempty"`
+}
+
+// Branch reflects an entry of a 'branches' list in the product tree.
+type Branch struct {
+	Branches []*Branch        `json:"branches,omitempty"`
+	Category *BranchCategory  `json:"category"` // required
+	Name     *string          `json:"name"`     // required
+	Product  *FullProductName `json:"product,omitempty"`
+}
+
+// ProductGroup reflects an entry of 'product_tree.product_groups'.
+type ProductGroup struct {
+	GroupID    *ProductGroupID `json:"group_id"`    // required
+	ProductIDs Products        `json:"product_ids"` // required
+	Summary    *string         `json:"summary,omitempty"`
+}
+
+// Relationship reflects an entry of 'product_tree.relationships'.
+type Relationship struct {
+	Category                  *RelationshipCategory `json:"category"`                     // required
+	FullProductName           *FullProductName      `json:"full_product_name"`            // required
+	ProductReference          *ProductID            `json:"product_reference"`            // required
+	RelatesToProductReference *ProductID            `json:"relates_to_product_reference"` // required
+}
+
+// ProductTree reflects the 'product_tree' object of an advisory.
+type ProductTree struct {
+	Branches         []*Branch          `json:"branches,omitempty"`
+	FullProductNames []*FullProductName `json:"full_product_names,omitempty"`
+	ProductGroups    []*ProductGroup    `json:"product_groups,omitempty"`
+	Relationships    []*Relationship    `json:"relationships,omitempty"`
+}
+
+// CWE reflects the 'cwe' object of a vulnerability.
+type CWE struct {
+	ID   *string `json:"id"`   // required
+	Name *string `json:"name"` // required
+}
+
+// Flag reflects an entry of the 'flags' of a vulnerability.
+type Flag struct {
+	Date       *DateTime     `json:"date,omitempty"`
+	GroupIDs   ProductGroups `json:"group_ids,omitempty"`
+	Label      *FlagLabel    `json:"label"` // required
+	ProductIDs Products      `json:"product_ids,omitempty"`
+}
+
+// VulnerabilityID reflects an entry of the 'ids' of a vulnerability.
+type VulnerabilityID struct {
+	SystemName *string `json:"system_name"` // required
+	Text       *string `json:"text"`        // required
+}
+
+// Involvement reflects an entry of the 'involvements' of a vulnerability.
+type Involvement struct {
+	Date    *DateTime          `json:"date,omitempty"`
+	Party   *InvolvementParty  `json:"party"`  // required
+	Status  *InvolvementStatus `json:"status"` // required
+	Summary *string            `json:"summary,omitempty"`
+}
+
+// ProductStatus reflects the 'product_status' object of a vulnerability.
+type ProductStatus struct {
+	FirstAffected      Products `json:"first_affected,omitempty"`
+	FirstFixed         Products `json:"first_fixed,omitempty"`
+	Fixed              Products `json:"fixed,omitempty"`
+	KnownAffected      Products `json:"known_affected,omitempty"`
+	KnownNotAffected   Products `json:"known_not_affected,omitempty"`
+	LastAffected       Products `json:"last_affected,omitempty"`
+	Recommended        Products `json:"recommended,omitempty"`
+	UnderInvestigation Products `json:"under_investigation,omitempty"`
+}
+
+// RestartRequired reflects the 'restart_required' object of a remediation.
+type RestartRequired struct {
+	Category *RestartRequiredCategory `json:"category"` // required
+	Details  *string                  `json:"details,omitempty"`
+}
+
+// Remediation reflects an entry of the 'remediations' of a vulnerability.
+type Remediation struct {
+	Category        *RemediationCategory `json:"category"` // required
+	Date            *DateTime            `json:"date,omitempty"`
+	Details         *string              `json:"details"` // required
+	Entitlements    []string             `json:"entitlements,omitempty"`
+	GroupIDs        ProductGroups        `json:"group_ids,omitempty"`
+	ProductIDs      Products             `json:"product_ids,omitempty"`
+	RestartRequired *RestartRequired     `json:"restart_required,omitempty"`
+	URL             *string              `json:"url,omitempty"`
+}
+
+// CVSSv2 reflects a CVSS v2.0 score object.
+type CVSSv2 struct {
+	Version                    *string  `json:"version"`      // required
+	VectorString               *string  `json:"vectorString"` // required
+	AccessVector               *string  `json:"accessVector,omitempty"`
+	AccessComplexity           *string  `json:"accessComplexity,omitempty"`
+	Authentication             *string  `json:"authentication,omitempty"`
+	ConfidentialityImpact      *string  `json:"confidentialityImpact,omitempty"`
+	IntegrityImpact            *string  `json:"integrityImpact,omitempty"`
+	AvailabilityImpact         *string  `json:"availabilityImpact,omitempty"`
+	BaseScore                  *float64 `json:"baseScore"` // required
+	Exploitability             *string  `json:"exploitability,omitempty"`
+	RemediationLevel           *string  `json:"remediationLevel,omitempty"`
+	ReportConfidence           *string  `json:"reportConfidence,omitempty"`
+	TemporalScore              *float64 `json:"temporalScore,omitempty"`
+	CollateralDamagePotential  *string  `json:"collateralDamagePotential,omitempty"`
+	TargetDistribution         *string  `json:"targetDistribution,omitempty"`
+	ConfidentialityRequirement *string  `json:"confidentialityRequirement,omitempty"`
+	IntegrityRequirement       *string  `json:"integrityRequirement,omitempty"`
+	AvailabilityRequirement    *string  `json:"availabilityRequirement,omitempty"`
+	EnvironmentalScore         *float64 `json:"environmentalScore,omitempty"`
+}
+
+// CVSSv3 reflects a CVSS v3.0 or v3.1 score object.
+type CVSSv3 struct {
+	Version                       *string  `json:"version"`      // required
+	VectorString                  *string  `json:"vectorString"` // required
+	AttackVector                  *string  `json:"attackVector,omitempty"`
+	AttackComplexity              *string  `json:"attackComplexity,omitempty"`
+	PrivilegesRequired            *string  `json:"privilegesRequired,omitempty"`
+	UserInteraction               *string  `json:"userInteraction,omitempty"`
+	Scope                         *string  `json:"scope,omitempty"`
+	ConfidentialityImpact         *string  `json:"confidentialityImpact,omitempty"`
+	IntegrityImpact               *string  `json:"integrityImpact,omitempty"`
+	AvailabilityImpact            *string  `json:"availabilityImpact,omitempty"`
+	BaseScore                     *float64 `json:"baseScore"`    // required
+	BaseSeverity                  *string  `json:"baseSeverity"` // required
+	ExploitCodeMaturity           *string  `json:"exploitCodeMaturity,omitempty"`
+	RemediationLevel              *string  `json:"remediationLevel,omitempty"`
+	ReportConfidence              *string  `json:"reportConfidence,omitempty"`
+	TemporalScore                 *float64 `json:"temporalScore,omitempty"`
+	TemporalSeverity              *string  `json:"temporalSeverity,omitempty"`
+	ConfidentialityRequirement    *string  `json:"confidentialityRequirement,omitempty"`
+	IntegrityRequirement          *string  `json:"integrityRequirement,omitempty"`
+	AvailabilityRequirement       *string  `json:"availabilityRequirement,omitempty"`
+	ModifiedAttackVector          *string  `json:"modifiedAttackVector,omitempty"`
+	ModifiedAttackComplexity      *string  `json:"modifiedAttackComplexity,omitempty"`
+	ModifiedPrivilegesRequired    *string  `json:"modifiedPrivilegesRequired,omitempty"`
+	ModifiedUserInteraction       *string  `json:"modifiedUserInteraction,omitempty"`
+	ModifiedScope                 *string  `json:"modifiedScope,omitempty"`
+	ModifiedConfidentialityImpact *string  `json:"modifiedConfidentialityImpact,omitempty"`
+	ModifiedIntegrityImpact       *string  `json:"modifiedIntegrityImpact,omitempty"`
+	ModifiedAvailabilityImpact    *string  `json:"modifiedAvailabilityImpact,omitempty"`
+	EnvironmentalScore            *float64 `json:"environmentalScore,omitempty"`
+	EnvironmentalSeverity         *string  `json:"environmentalSeverity,omitempty"`
+}
+
+// Score reflects an entry of the 'scores' of a vulnerability.
+type Score struct {
+	CVSSv2   *CVSSv2  `json:"cvss_v2,omitempty"`
+	CVSSv3   *CVSSv3  `json:"cvss_v3,omitempty"`
+	Products Products `json:"products"` // required
+}
+
+// Threat reflects an entry of the 'threats' of a vulnerability.
+type Threat struct {
+	Category   *ThreatCategory `json:"category"` // required
+	Date       *DateTime       `json:"date,omitempty"`
+	Details    *string         `json:"details"` // required
+	GroupIDs   ProductGroups   `json:"group_ids,omitempty"`
+	ProductIDs Products        `json:"product_ids,omitempty"`
+}
+
+// Vulnerability reflects an entry of the 'vulnerabilities' of an advisory.
+type Vulnerability struct {
+	Acknowledgments []*Acknowledgment  `json:"acknowledgments,omitempty"`
+	CVE             *string            `json:"cve,omitempty"`
+	CWE             *CWE               `json:"cwe,omitempty"`
+	DiscoveryDate   *DateTime          `json:"discovery_date,omitempty"`
+	Flags           []*Flag            `json:"flags,omitempty"`
+	IDs             []*VulnerabilityID `json:"ids,omitempty"`
+	Involvements    []*Involvement     `json:"involvements,omitempty"`
+	Notes           []*Note            `json:"notes,omitempty"`
+	ProductStatus   *ProductStatus     `json:"product_status,omitempty"`
+	References      []*Reference       `json:"references,omitempty"`
+	ReleaseDate     *DateTime          `json:"release_date,omitempty"`
+	Remediations    []*Remediation     `json:"remediations,omitempty"`
+	Scores          []*Score           `json:"scores,omitempty"`
+	Threats         []*Threat          `json:"threats,omitempty"`
+	Title           *string            `json:"title,omitempty"`
+}
+
+// Advisory is a CSAF 2.0 document.
+// The enumerations are modelled as plain strings and not checked
+// while loading. Use ValidateCSAF to check a document against the schema.
+type Advisory struct {
+	Document        *Document        `json:"document"` // required
+	ProductTree     *ProductTree     `json:"product_tree,omitempty"`
+	Vulnerabilities []*Vulnerability `json:"vulnerabilities,omitempty"`
+}
+
+// Validate checks if the tracking section is valid.
+// Returns an error if the validation fails otherwise nil.
+func (t *Tracking) Validate() error {
+	switch {
+	case t == nil:
+		return errors.New("document.tracking is mandatory")
+	case t.CurrentReleaseDate == nil:
+		return errors.New("document.tracking.current_release_date is mandatory")
+	case t.ID == nil:
+		return errors.New("document.tracking.id is mandatory")
+	case t.InitialReleaseDate == nil:
+		return errors.New("document.tracking.initial_release_date is mandatory")
+	case len(t.RevisionHistory) == 0:
+		return errors.New("document.tracking.revision_history is mandatory")
+	case t.Status == nil:
+		return errors.New("document.tracking.status is mandatory")
+	case t.Version == nil:
+		return errors.New("document.tracking.version is mandatory")
+	}
+	return nil
+}
+
+// Validate checks if the document is valid.
+// Returns an error if the validation fails otherwise nil.
+func (d *Document) Validate() error {
+	switch {
+	case d == nil:
+		return errors.New("document is mandatory")
+	case d.Category == nil:
+		return errors.New("document.category is mandatory")
+	case d.CSAFVersion == nil:
+		return errors.New("document.csaf_version is mandatory")
+	case d.Title == nil:
+		return errors.New("document.title is mandatory")
+	}
+	if err := d.Publisher.Validate(); err != nil {
+		return err
+	}
+	return d.Tracking.Validate()
+}
+
+// Validate checks if the advisory is valid.
+// Only the presence of the mandatory fields of the document section
+// is checked. Returns an error if the validation fails otherwise nil.
+func (adv *Advisory) Validate() error {
+	return adv.Document.Validate()
+}
+
+// LoadAdvisory loads an advisory from a reader.
+func LoadAdvisory(r io.Reader) (*Advisory, error) {
+	var adv Advisory
+	if err := json.NewDecoder(r).Decode(&adv); err != nil {
+		return nil, err
+	}
+	if err := adv.Validate(); err != nil {
+		return nil, err
+	}
+	return &adv, nil
+}
+
+// NewAdvisoryFromDocument converts an already de-serialized
+// JSON document into an advisory.
+func NewAdvisoryFromDocument(doc interface{}) (*Advisory, error) {
+	var adv Advisory
+	if err := util.ReMarshalJSON(&adv, doc); err != nil {
+		return nil, err
+	}
+	if err := adv.Validate(); err != nil {
+		return nil, err
+	}
+	return &adv, nil
+}
+
+// WriteTo saves an advisory to a writer.
+func (adv *Advisory) WriteTo(w io.Writer) (int64, error) {
+	nw := util.NWriter{Writer: w, N: 0}
+	enc := json.NewEncoder(&nw)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(adv)
+	return nw.N, err
+}
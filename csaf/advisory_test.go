@@ -0,0 +1,169 @@
+package csaf
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const exampleAdvisory = `{
+  "document": {
+    "acknowledgments": [{
+      "names": ["Jane Doe"],
+      "organization": "Example Research",
+      "summary": "finding the issue",
+      "urls": ["https://research.example.com/"]
+    }],
+    "aggregate_severity": {"namespace": "https://example.com/severity", "text": "Critical"},
+    "category": "csaf_security_advisory",
+    "csaf_version": "2.0",
+    "distribution": {"text": "share freely", "tlp": {"label": "WHITE", "url": "https://www.first.org/tlp/"}},
+    "lang": "en-US",
+    "notes": [{"category": "summary", "text": "A summary.", "title": "Summary", "audience": "all"}],
+    "publisher": {
+      "category": "vendor",
+      "name": "Example Company",
+      "namespace": "https://example.com",
+      "contact_details": "psirt@example.com",
+      "issuing_authority": "Example PSIRT"
+    },
+    "references": [{"category": "self", "summary": "This document", "url": "https://example.com/csaf/white/2022/example-2022-0001.json"}],
+    "source_lang": "de",
+    "title": "Example advisory",
+    "tracking": {
+      "aliases": ["EX-2022-0001-ALIAS"],
+      "current_release_date": "2022-03-04T12:00:00.000Z",
+      "generator": {"date": "2022-03-04T11:00:00Z", "engine": {"name": "Secvisogram", "version": "1.11.0"}},
+      "id": "Example-2022-0001",
+      "initial_release_date": "2022-01-01T00:00:00+01:00",
+      "revision_history": [
+        {"date": "2022-01-01T00:00:00+01:00", "number": "1", "summary": "Initial."},
+        {"date": "2022-03-04T12:00:00.000Z", "legacy_version": "1.1", "number": "2", "summary": "Update."}
+      ],
+      "status": "final",
+      "version": "2"
+    }
+  },
+  "product_tree": {
+    "branches": [{
+      "category": "vendor",
+      "name": "Example",
+      "branches": [{
+        "category": "product_version",
+        "name": "1.0",
+        "product": {
+          "name": "Example Product 1.0",
+          "product_id": "CSAFPID-0001",
+          "product_identification_helper": {
+            "cpe": "cpe:/a:example:product:1.0",
+            "hashes": [{"file_hashes": [{"algorithm": "sha256", "value": "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"}], "filename": "product.tar.gz"}],
+            "model_numbers": ["M1"],
+            "purl": "pkg:generic/example/product@1.0",
+            "sbom_urls": ["https://example.com/sbom.json"],
+            "serial_numbers": ["S1"],
+            "skus": ["SKU1"],
+            "x_generic_uris": [{"namespace": "https://example.com/ns", "uri": "https://example.com/ns/product"}]
+          }
+        }
+      }]
+    }],
+    "full_product_names": [{"name": "Example Library 2.0", "product_id": "CSAFPID-0002"}],
+    "product_groups": [{"group_id": "CSAFGID-0001", "product_ids": ["CSAFPID-0001", "CSAFPID-0002"], "summary": "All products."}],
+    "relationships": [{
+      "category": "installed_on",
+      "full_product_name": {"name": "Example Library 2.0 on Example Product 1.0", "product_id": "CSAFPID-0003"},
+      "product_reference": "CSAFPID-0002",
+      "relates_to_product_reference": "CSAFPID-0001"
+    }]
+  },
+  "vulnerabilities": [{
+    "acknowledgments": [{"names": ["John Doe"]}],
+    "cve": "CVE-2022-0001",
+    "cwe": {"id": "CWE-79", "name": "Improper Neutralization of Input During Web Page Generation ('Cross-site Scripting')"},
+    "discovery_date": "2021-12-01T00:00:00Z",
+    "flags": [{"date": "2022-01-01T00:00:00Z", "group_ids": ["CSAFGID-0001"], "label": "component_not_present", "product_ids": ["CSAFPID-0002"]}],
+    "ids": [{"system_name": "Example Tracker", "text": "BUG-1"}],
+    "involvements": [{"date": "2022-01-01T00:00:00Z", "party": "vendor", "status": "completed", "summary": "Fixed."}],
+    "notes": [{"category": "description", "text": "Details."}],
+    "product_status": {
+      "first_affected": ["CSAFPID-0001"],
+      "first_fixed": ["CSAFPID-0003"],
+      "fixed": ["CSAFPID-0003"],
+      "known_affected": ["CSAFPID-0001"],
+      "known_not_affected": ["CSAFPID-0002"],
+      "last_affected": ["CSAFPID-0001"],
+      "recommended": ["CSAFPID-0003"]
+    },
+    "references": [{"summary": "Upstream", "url": "https://upstream.example.com/"}],
+    "release_date": "2022-01-01T00:00:00Z",
+    "remediations": [{
+      "category": "vendor_fix",
+      "date": "2022-01-01T00:00:00Z",
+      "details": "Update.",
+      "entitlements": ["Everyone"],
+      "group_ids": ["CSAFGID-0001"],
+      "product_ids": ["CSAFPID-0001"],
+      "restart_required": {"category": "service", "details": "Restart the service."},
+      "url": "https://example.com/download"
+    }],
+    "scores": [{
+      "cvss_v2": {"version": "2.0", "vectorString": "AV:N/AC:L/Au:N/C:P/I:P/A:P", "baseScore": 7.5, "accessVector": "NETWORK"},
+      "cvss_v3": {
+        "version": "3.1",
+        "vectorString": "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H/MS:U",
+        "baseScore": 9.8,
+        "baseSeverity": "CRITICAL",
+        "attackVector": "NETWORK",
+        "modifiedScope": "UNCHANGED",
+        "environmentalScore": 9.8,
+        "environmentalSeverity": "CRITICAL"
+      },
+      "products": ["CSAFPID-0001"]
+    }],
+    "threats": [{"category": "exploit_status", "date": "2022-01-01T00:00:00Z", "details": "None known.", "group_ids": ["CSAFGID-0001"], "product_ids": ["CSAFPID-0001"]}],
+    "title": "Cross-site scripting"
+  }]
+}`
+
+func TestAdvisoryRoundTrip(t *testing.T) {
+
+	adv, err := LoadAdvisory(strings.NewReader(exampleAdvisory))
+	if err != nil {
+		t.Fatalf("Loading advisory failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := adv.WriteTo(&buf); err != nil {
+		t.Fatalf("Writing advisory failed: %v", err)
+	}
+
+	var orig, again interface{}
+	if err := json.Unmarshal([]byte(exampleAdvisory), &orig); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(buf.Bytes(), &again); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(orig, again) {
+		t.Errorf("Advisory changed while round tripping:\n%s", buf.String())
+	}
+
+	if got := *adv.Vulnerabilities[0].Remediations[0].Category; got != RemediationCategoryVendorFix {
+		t.Errorf("Expected remediation category %q, got %q", RemediationCategoryVendorFix, got)
+	}
+	if got := adv.Vulnerabilities[0].ProductStatus.KnownNotAffected; len(got) != 1 || got[0] != "CSAFPID-0002" {
+		t.Errorf("Unexpected known_not_affected products: %v", got)
+	}
+}
+
+func TestLoadAdvisoryMissingTracking(t *testing.T) {
+	const doc = `{"document": {
+    "category": "csaf_base", "csaf_version": "2.0", "title": "x",
+    "publisher": {"category": "vendor", "name": "n", "namespace": "https://example.com"}}}`
+	if _, err := LoadAdvisory(strings.NewReader(doc)); err == nil {
+		t.Error("Expected error for missing tracking section")
+	}
+}
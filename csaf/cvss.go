@@ -0,0 +1,433 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package csaf
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// cvssMetric describes a metric of a CVSS vector and
+// the property of the score object it corresponds to.
+type cvssMetric struct {
+	// abbr is the abbreviation of the metric in the vector.
+	abbr string
+	// property is the name of the property in the JSON object.
+	property string
+	// values maps the abbreviated values to the property values.
+	values map[string]string
+	// def is the abbreviated value assumed if the metric is not given.
+	// An empty def means the metric is mandatory.
+	def string
+}
+
+var (
+	cvss2Impact = map[string]string{"N": "NONE", "P": "PARTIAL", "C": "COMPLETE"}
+	cvss2Req    = map[string]string{
+		"L": "LOW", "M": "MEDIUM", "H": "HIGH", "ND": "NOT_DEFINED"}
+
+	cvss3AttackVector = map[string]string{
+		"N": "NETWORK", "A": "ADJACENT_NETWORK", "L": "LOCAL", "P": "PHYSICAL"}
+	cvss3LowHigh = map[string]string{"L": "LOW", "H": "HIGH"}
+	cvss3PR      = map[string]string{"N": "NONE", "L": "LOW", "H": "HIGH"}
+	cvss3UI      = map[string]string{"N": "NONE", "R": "REQUIRED"}
+	cvss3Scope   = map[string]string{"U": "UNCHANGED", "C": "CHANGED"}
+	cvss3Impact  = map[string]string{"H": "HIGH", "L": "LOW", "N": "NONE"}
+	cvss3Req     = map[string]string{
+		"X": "NOT_DEFINED", "H": "HIGH", "M": "MEDIUM", "L": "LOW"}
+)
+
+// notDefined returns a copy of m extended by the "X" value.
+func notDefined(m map[string]string) map[string]string {
+	n := make(map[string]string, len(m)+1)
+	for k, v := range m {
+		n[k] = v
+	}
+	n["X"] = "NOT_DEFINED"
+	return n
+}
+
+var cvss2Metrics = []cvssMetric{
+	{"AV", "accessVector", map[string]string{
+		"L": "LOCAL", "A": "ADJACENT_NETWORK", "N": "NETWORK"}, ""},
+	{"AC", "accessComplexity", map[string]string{
+		"H": "HIGH", "M": "MEDIUM", "L": "LOW"}, ""},
+	{"Au", "authentication", map[string]string{
+		"M": "MULTIPLE", "S": "SINGLE", "N": "NONE"}, ""},
+	{"C", "confidentialityImpact", cvss2Impact, ""},
+	{"I", "integrityImpact", cvss2Impact, ""},
+	{"A", "availabilityImpact", cvss2Impact, ""},
+	{"E", "exploitability", map[string]string{
+		"U": "UNPROVEN", "POC": "PROOF_OF_CONCEPT", "F": "FUNCTIONAL",
+		"H": "HIGH", "ND": "NOT_DEFINED"}, "ND"},
+	{"RL", "remediationLevel", map[string]string{
+		"OF": "OFFICIAL_FIX", "TF": "TEMPORARY_FIX", "W": "WORKAROUND",
+		"U": "UNAVAILABLE", "ND": "NOT_DEFINED"}, "ND"},
+	{"RC", "reportConfidence", map[string]string{
+		"UC": "UNCONFIRMED", "UR": "UNCORROBORATED", "C": "CONFIRMED",
+		"ND": "NOT_DEFINED"}, "ND"},
+	{"CDP", "collateralDamagePotential", map[string]string{
+		"N": "NONE", "L": "LOW", "LM": "LOW_MEDIUM", "MH": "MEDIUM_HIGH",
+		"H": "HIGH", "ND": "NOT_DEFINED"}, "ND"},
+	{"TD", "targetDistribution", map[string]string{
+		"N": "NONE", "L": "LOW", "M": "MEDIUM", "H": "HIGH",
+		"ND": "NOT_DEFINED"}, "ND"},
+	{"CR", "confidentialityRequirement", cvss2Req, "ND"},
+	{"IR", "integrityRequirement", cvss2Req, "ND"},
+	{"AR", "availabilityRequirement", cvss2Req, "ND"},
+}
+
+var cvss3Metrics = []cvssMetric{
+	{"AV", "attackVector", cvss3AttackVector, ""},
+	{"AC", "attackComplexity", cvss3LowHigh, ""},
+	{"PR", "privilegesRequired", cvss3PR, ""},
+	{"UI", "userInteraction", cvss3UI, ""},
+	{"S", "scope", cvss3Scope, ""},
+	{"C", "confidentialityImpact", cvss3Impact, ""},
+	{"I", "integrityImpact", cvss3Impact, ""},
+	{"A", "availabilityImpact", cvss3Impact, ""},
+	{"E", "exploitCodeMaturity", map[string]string{
+		"X": "NOT_DEFINED", "H": "HIGH", "F": "FUNCTIONAL",
+		"P": "PROOF_OF_CONCEPT", "U": "UNPROVEN"}, "X"},
+	{"RL", "remediationLevel", map[string]string{
+		"X": "NOT_DEFINED", "U": "UNAVAILABLE", "W": "WORKAROUND",
+		"T": "TEMPORARY_FIX", "O": "OFFICIAL_FIX"}, "X"},
+	{"RC", "reportConfidence", map[string]string{
+		"X": "NOT_DEFINED", "C": "CONFIRMED", "R": "REASONABLE",
+		"U": "UNKNOWN"}, "X"},
+	{"CR", "confidentialityRequirement", cvss3Req, "X"},
+	{"IR", "integrityRequirement", cvss3Req, "X"},
+	{"AR", "availabilityRequirement", cvss3Req, "X"},
+	{"MAV", "modifiedAttackVector", notDefined(cvss3AttackVector), "X"},
+	{"MAC", "modifiedAttackComplexity", notDefined(cvss3LowHigh), "X"},
+	{"MPR", "modifiedPrivilegesRequired", notDefined(cvss3PR), "X"},
+	{"MUI", "modifiedUserInteraction", notDefined(cvss3UI), "X"},
+	{"MS", "modifiedScope", notDefined(cvss3Scope), "X"},
+	{"MC", "modifiedConfidentialityImpact", notDefined(cvss3Impact), "X"},
+	{"MI", "modifiedIntegrityImpact", notDefined(cvss3Impact), "X"},
+	{"MA", "modifiedAvailabilityImpact", notDefined(cvss3Impact), "X"},
+}
+
+// cvssVector is a parsed CVSS vector mapping metrics to values.
+type cvssVector map[string]string
+
+// parseCVSSVector parses a vector against the given metrics.
+// prefix is the expected leading version part of the vector.
+func parseCVSSVector(vector, prefix string, metrics []cvssMetric) (cvssVector, error) {
+	if prefix != "" {
+		if !strings.HasPrefix(vector, prefix+"/") {
+			return nil, fmt.Errorf("vector does not start with %q", prefix+"/")
+		}
+		vector = vector[len(prefix)+1:]
+	}
+	known := make(map[string]*cvssMetric, len(metrics))
+	for i := range metrics {
+		known[metrics[i].abbr] = &metrics[i]
+	}
+	v := cvssVector{}
+	for _, part := range strings.Split(vector, "/") {
+		kv := strings.SplitN(part, ":", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid vector component %q", part)
+		}
+		m := known[kv[0]]
+		if m == nil {
+			return nil, fmt.Errorf("unknown metric %q", kv[0])
+		}
+		if _, ok := m.values[kv[1]]; !ok {
+			return nil, fmt.Errorf("invalid value %q for metric %q", kv[1], kv[0])
+		}
+		if _, dup := v[kv[0]]; dup {
+			return nil, fmt.Errorf("metric %q given more than once", kv[0])
+		}
+		v[kv[0]] = kv[1]
+	}
+	for i := range metrics {
+		m := &metrics[i]
+		if _, ok := v[m.abbr]; !ok {
+			if m.def == "" {
+				return nil, fmt.Errorf("mandatory metric %q missing", m.abbr)
+			}
+			v[m.abbr] = m.def
+		}
+	}
+	return v, nil
+}
+
+// inconsistencies returns the properties which do not match the vector.
+func (v cvssVector) inconsistencies(
+	metrics []cvssMetric,
+	properties map[string]*string,
+) []string {
+	var incons []string
+	for i := range metrics {
+		m := &metrics[i]
+		p := properties[m.property]
+		if p == nil {
+			continue
+		}
+		if expected := m.values[v[m.abbr]]; *p != expected {
+			incons = append(incons,
+				fmt.Sprintf("%s is %q but vector says %q", m.property, *p, expected))
+		}
+	}
+	return incons
+}
+
+// properties returns the metric properties of the CVSS v2 object.
+func (c *CVSSv2) properties() map[string]*string {
+	return map[string]*string{
+		"accessVector":               c.AccessVector,
+		"accessComplexity":           c.AccessComplexity,
+		"authentication":             c.Authentication,
+		"confidentialityImpact":      c.ConfidentialityImpact,
+		"integrityImpact":            c.IntegrityImpact,
+		"availabilityImpact":         c.AvailabilityImpact,
+		"exploitability":             c.Exploitability,
+		"remediationLevel":           c.RemediationLevel,
+		"reportConfidence":           c.ReportConfidence,
+		"collateralDamagePotential":  c.CollateralDamagePotential,
+		"targetDistribution":         c.TargetDistribution,
+		"confidentialityRequirement": c.ConfidentialityRequirement,
+		"integrityRequirement":       c.IntegrityRequirement,
+		"availabilityRequirement":    c.AvailabilityRequirement,
+	}
+}
+
+// properties returns the metric properties of the CVSS v3 object.
+func (c *CVSSv3) properties() map[string]*string {
+	return map[string]*string{
+		"attackVector":                  c.AttackVector,
+		"attackComplexity":              c.AttackComplexity,
+		"privilegesRequired":            c.PrivilegesRequired,
+		"userInteraction":               c.UserInteraction,
+		"scope":                         c.Scope,
+		"confidentialityImpact":         c.ConfidentialityImpact,
+		"integrityImpact":               c.IntegrityImpact,
+		"availabilityImpact":            c.AvailabilityImpact,
+		"exploitCodeMaturity":           c.ExploitCodeMaturity,
+		"remediationLevel":              c.RemediationLevel,
+		"reportConfidence":              c.ReportConfidence,
+		"confidentialityRequirement":    c.ConfidentialityRequirement,
+		"integrityRequirement":          c.IntegrityRequirement,
+		"availabilityRequirement":       c.AvailabilityRequirement,
+		"modifiedAttackVector":          c.ModifiedAttackVector,
+		"modifiedAttackComplexity":      c.ModifiedAttackComplexity,
+		"modifiedPrivilegesRequired":    c.ModifiedPrivilegesRequired,
+		"modifiedUserInteraction":       c.ModifiedUserInteraction,
+		"modifiedScope":                 c.ModifiedScope,
+		"modifiedConfidentialityImpact": c.ModifiedConfidentialityImpact,
+		"modifiedIntegrityImpact":       c.ModifiedIntegrityImpact,
+		"modifiedAvailabilityImpact":    c.ModifiedAvailabilityImpact,
+	}
+}
+
+// cvssScores are the computed scores of a CVSS vector.
+type cvssScores struct {
+	base          float64
+	temporal      float64
+	environmental float64
+}
+
+// roundTo1Decimal rounds as defined in CVSS v2.
+func roundTo1Decimal(x float64) float64 {
+	return math.Round(x*10) / 10
+}
+
+var cvss2Weights = map[string]map[string]float64{
+	"AV":  {"L": 0.395, "A": 0.646, "N": 1.0},
+	"AC":  {"H": 0.35, "M": 0.61, "L": 0.71},
+	"Au":  {"M": 0.45, "S": 0.56, "N": 0.704},
+	"C":   {"N": 0.0, "P": 0.275, "C": 0.660},
+	"I":   {"N": 0.0, "P": 0.275, "C": 0.660},
+	"A":   {"N": 0.0, "P": 0.275, "C": 0.660},
+	"E":   {"U": 0.85, "POC": 0.9, "F": 0.95, "H": 1.0, "ND": 1.0},
+	"RL":  {"OF": 0.87, "TF": 0.90, "W": 0.95, "U": 1.0, "ND": 1.0},
+	"RC":  {"UC": 0.90, "UR": 0.95, "C": 1.0, "ND": 1.0},
+	"CDP": {"N": 0, "L": 0.1, "LM": 0.3, "MH": 0.4, "H": 0.5, "ND": 0},
+	"TD":  {"N": 0, "L": 0.25, "M": 0.75, "H": 1.0, "ND": 1.0},
+	"CR":  {"L": 0.5, "M": 1.0, "H": 1.51, "ND": 1.0},
+	"IR":  {"L": 0.5, "M": 1.0, "H": 1.51, "ND": 1.0},
+	"AR":  {"L": 0.5, "M": 1.0, "H": 1.51, "ND": 1.0},
+}
+
+// cvss2Scores calculates the scores of a CVSS v2 vector.
+func (v cvssVector) cvss2Scores() cvssScores {
+	w := func(m string) float64 { return cvss2Weights[m][v[m]] }
+
+	base := func(impact float64) float64 {
+		exploitability := 20 * w("AV") * w("AC") * w("Au")
+		f := 1.176
+		if impact == 0 {
+			f = 0
+		}
+		return roundTo1Decimal((0.6*impact + 0.4*exploitability - 1.5) * f)
+	}
+	temporal := func(base float64) float64 {
+		return roundTo1Decimal(base * w("E") * w("RL") * w("RC"))
+	}
+
+	impact := 10.41 * (1 - (1-w("C"))*(1-w("I"))*(1-w("A")))
+	baseScore := base(impact)
+
+	adjustedImpact := math.Min(10, 10.41*(1-
+		(1-w("C")*w("CR"))*
+			(1-w("I")*w("IR"))*
+			(1-w("A")*w("AR"))))
+	adjustedTemporal := temporal(base(adjustedImpact))
+
+	return cvssScores{
+		base:     baseScore,
+		temporal: temporal(baseScore),
+		environmental: roundTo1Decimal(
+			(adjustedTemporal + (10-adjustedTemporal)*w("CDP")) * w("TD")),
+	}
+}
+
+var cvss3Weights = map[string]map[string]float64{
+	"AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+	"AC": {"L": 0.77, "H": 0.44},
+	"UI": {"N": 0.85, "R": 0.62},
+	"C":  {"H": 0.56, "L": 0.22, "N": 0},
+	"I":  {"H": 0.56, "L": 0.22, "N": 0},
+	"A":  {"H": 0.56, "L": 0.22, "N": 0},
+	"E":  {"X": 1, "H": 1, "F": 0.97, "P": 0.94, "U": 0.91},
+	"RL": {"X": 1, "U": 1, "W": 0.97, "T": 0.96, "O": 0.95},
+	"RC": {"X": 1, "C": 1, "R": 0.96, "U": 0.92},
+	"CR": {"X": 1, "H": 1.5, "M": 1, "L": 0.5},
+	"IR": {"X": 1, "H": 1.5, "M": 1, "L": 0.5},
+	"AR": {"X": 1, "H": 1.5, "M": 1, "L": 0.5},
+}
+
+// cvss3PRWeight returns the weight of the privileges required
+// which depends on the scope.
+func cvss3PRWeight(pr string, changed bool) float64 {
+	switch pr {
+	case "L":
+		if changed {
+			return 0.68
+		}
+		return 0.62
+	case "H":
+		if changed {
+			return 0.5
+		}
+		return 0.27
+	default:
+		return 0.85
+	}
+}
+
+// roundUp30 is the round up function of CVSS v3.0.
+func roundUp30(x float64) float64 {
+	return math.Ceil(x*10) / 10
+}
+
+// roundUp31 is the round up function of CVSS v3.1.
+func roundUp31(x float64) float64 {
+	i := int64(math.Round(x * 100000))
+	if i%10000 == 0 {
+		return float64(i) / 100000
+	}
+	return float64(i/10000+1) / 10
+}
+
+// cvss3Scores calculates the scores of a CVSS v3.0 or v3.1 vector.
+func (v cvssVector) cvss3Scores(v31 bool) cvssScores {
+	roundUp := roundUp30
+	if v31 {
+		roundUp = roundUp31
+	}
+	w := func(m string) float64 { return cvss3Weights[m][v[m]] }
+
+	// modified returns the modified metric falling back to the base one.
+	modified := func(m string) string {
+		if x := v["M"+m]; x != "" && x != "X" {
+			return x
+		}
+		return v[m]
+	}
+	mw := func(m string) float64 { return cvss3Weights[m][modified(m)] }
+
+	changed := v["S"] == "C"
+	iss := 1 - (1-w("C"))*(1-w("I"))*(1-w("A"))
+	var impact float64
+	if changed {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	} else {
+		impact = 6.42 * iss
+	}
+	exploitability := 8.22 * w("AV") * w("AC") *
+		cvss3PRWeight(v["PR"], changed) * w("UI")
+
+	var base float64
+	switch {
+	case impact <= 0:
+	case changed:
+		base = roundUp(math.Min(1.08*(impact+exploitability), 10))
+	default:
+		base = roundUp(math.Min(impact+exploitability, 10))
+	}
+
+	temporalFactor := w("E") * w("RL") * w("RC")
+
+	mChanged := modified("S") == "C"
+	miss := math.Min(1-
+		(1-w("CR")*mw("C"))*
+			(1-w("IR")*mw("I"))*
+			(1-w("AR")*mw("A")), 0.915)
+	var mImpact float64
+	switch {
+	case !mChanged:
+		mImpact = 6.42 * miss
+	case v31:
+		mImpact = 7.52*(miss-0.029) - 3.25*math.Pow(miss*0.9731-0.02, 13)
+	default:
+		mImpact = 7.52*(miss-0.029) - 3.25*math.Pow(miss-0.02, 15)
+	}
+	mExploitability := 8.22 * mw("AV") * mw("AC") *
+		cvss3PRWeight(modified("PR"), mChanged) * mw("UI")
+
+	var env float64
+	switch {
+	case mImpact <= 0:
+	case mChanged:
+		env = roundUp(roundUp(math.Min(1.08*(mImpact+mExploitability), 10)) * temporalFactor)
+	default:
+		env = roundUp(roundUp(math.Min(mImpact+mExploitability, 10)) * temporalFactor)
+	}
+
+	return cvssScores{
+		base:          base,
+		temporal:      roundUp(base * temporalFactor),
+		environmental: env,
+	}
+}
+
+// cvss3Severity returns the qualitative severity rating of a CVSS v3 score.
+func cvss3Severity(score float64) string {
+	switch {
+	case score == 0:
+		return "NONE"
+	case score < 4:
+		return "LOW"
+	case score < 7:
+		return "MEDIUM"
+	case score < 9:
+		return "HIGH"
+	default:
+		return "CRITICAL"
+	}
+}
+
+// sameScore compares two scores with a precision of one decimal.
+func sameScore(a, b float64) bool {
+	return math.Abs(a-b) < 0.01
+}
@@ -0,0 +1,149 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package csaf
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// informativeTests are the tests of section 6.3.
+// The tests 6.3.6 and 6.3.7 (resolving URLs) and 6.3.8 (spell check)
+// need external resources and are not implemented.
+var informativeTests = []func(*tester){
+	informativeOnlyCVSSv2,       // 6.3.1
+	informativeCVSSv30,          // 6.3.2
+	informativeMissingCVE,       // 6.3.3
+	informativeMissingCWE,       // 6.3.4
+	informativeShortHash,        // 6.3.5
+	informativeBranchCategories, // 6.3.9
+	informativeVersionRange,     // 6.3.10
+	informativeVersionIndicator, // 6.3.11
+}
+
+// RunInformativeTests runs the informative tests of section 6.3
+// of the CSAF standard on the document doc. doc is either
+// an *Advisory or a de-serialized JSON document.
+// The findings are reported as infos.
+func RunInformativeTests(doc interface{}) (TestResults, error) {
+	t, err := newTester(doc)
+	if err != nil {
+		return nil, err
+	}
+	return t.run(informativeTests, TestInfo), nil
+}
+
+func informativeOnlyCVSSv2(t *tester) {
+	t.adv.walkScores(func(s *Score, pointer string) {
+		if s.CVSSv2 != nil && s.CVSSv3 == nil {
+			t.add("6.3.1", pointer, "CVSS v2 is the only scoring system used")
+		}
+	})
+}
+
+func informativeCVSSv30(t *tester) {
+	t.adv.walkScores(func(s *Score, pointer string) {
+		if s.CVSSv3 != nil && deref(s.CVSSv3.Version) == "3.0" {
+			t.add("6.3.2", pointer+"/cvss_v3/version", "CVSS v3.0 is used instead of v3.1")
+		}
+	})
+}
+
+func informativeMissingCVE(t *tester) {
+	for i, v := range t.adv.Vulnerabilities {
+		if v != nil && v.CVE == nil {
+			t.add("6.3.3", fmt.Sprintf("/vulnerabilities/%d/cve", i), "CVE is missing")
+		}
+	}
+}
+
+func informativeMissingCWE(t *tester) {
+	for i, v := range t.adv.Vulnerabilities {
+		if v != nil && v.CWE == nil {
+			t.add("6.3.4", fmt.Sprintf("/vulnerabilities/%d/cwe", i), "CWE is missing")
+		}
+	}
+}
+
+func informativeShortHash(t *tester) {
+	names, pointers := t.adv.fullProductNames()
+	for i, fpn := range names {
+		pih := fpn.ProductIdentificationHelper
+		if pih == nil {
+			continue
+		}
+		for j, h := range pih.Hashes {
+			if h == nil {
+				continue
+			}
+			for k, fh := range h.FileHashes {
+				// Shorter than 64 hex digits is weaker than SHA-256.
+				if fh != nil && len(deref(fh.Value)) < 64 {
+					t.add("6.3.5",
+						fmt.Sprintf("%s/product_identification_helper/hashes/%d/file_hashes/%d/value",
+							pointers[i], j, k),
+						"hash value of %q is short", deref(fh.Algorithm))
+				}
+			}
+		}
+	}
+}
+
+func informativeBranchCategories(t *tester) {
+	if t.adv.ProductTree == nil {
+		return
+	}
+	var recurse func([]*Branch, string, map[BranchCategory]bool)
+	recurse = func(bs []*Branch, pointer string, path map[BranchCategory]bool) {
+		for i, b := range bs {
+			if b == nil {
+				continue
+			}
+			bp := fmt.Sprintf("%s/%d", pointer, i)
+			current := make(map[BranchCategory]bool, len(path)+1)
+			for k := range path {
+				current[k] = true
+			}
+			if b.Category != nil {
+				current[*b.Category] = true
+			}
+			if b.Product != nil {
+				if !current[BranchCategoryVendor] ||
+					!current[BranchCategoryProductName] ||
+					!current[BranchCategoryProductVersion] &&
+						!current[BranchCategoryProductVersionRange] {
+					t.add("6.3.9", bp+"/product",
+						"path to product lacks a vendor, product_name or product_version branch")
+				}
+			}
+			recurse(b.Branches, bp+"/branches", current)
+		}
+	}
+	recurse(t.adv.ProductTree.Branches, "/product_tree/branches", nil)
+}
+
+func informativeVersionRange(t *tester) {
+	t.adv.walkBranches(func(b *Branch, pointer string) {
+		if b.Category != nil && *b.Category == BranchCategoryProductVersionRange {
+			t.add("6.3.10", pointer+"/category", "product version range is used")
+		}
+	})
+}
+
+var versionIndicatorPattern = regexp.MustCompile(`^[vV][0-9].*$`)
+
+func informativeVersionIndicator(t *tester) {
+	t.adv.walkBranches(func(b *Branch, pointer string) {
+		if b.Category != nil && *b.Category == BranchCategoryProductVersion &&
+			versionIndicatorPattern.MatchString(deref(b.Name)) {
+			t.add("6.3.11", pointer+"/name",
+				"product version %q uses 'v' as version indicator", deref(b.Name))
+		}
+	})
+}
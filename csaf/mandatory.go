@@ -0,0 +1,959 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package csaf
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// The document categories of the profiles defined in section 4.
+const (
+	profileBase                     = "csaf_base"
+	profileSecurityIncidentResponse = "csaf_security_incident_response"
+	profileInformationalAdvisory    = "csaf_informational_advisory"
+	profileSecurityAdvisory         = "csaf_security_advisory"
+	profileVEX                      = "csaf_vex"
+)
+
+// mandatoryTests are the tests of section 6.1.
+var mandatoryTests = []func(*tester){
+	mandatoryMissingProductID,           // 6.1.1
+	mandatoryMultipleProductID,          // 6.1.2
+	mandatoryCircularProductID,          // 6.1.3
+	mandatoryMissingGroupID,             // 6.1.4
+	mandatoryMultipleGroupID,            // 6.1.5
+	mandatoryContradictingStatus,        // 6.1.6
+	mandatoryMultipleScores,             // 6.1.7
+	mandatoryInvalidCVSS,                // 6.1.8
+	mandatoryCVSSComputation,            // 6.1.9
+	mandatoryInconsistentCVSS,           // 6.1.10
+	mandatoryCWE,                        // 6.1.11
+	mandatoryLanguage,                   // 6.1.12
+	mandatoryPURL,                       // 6.1.13
+	mandatorySortedRevisionHistory,      // 6.1.14
+	mandatoryTranslator,                 // 6.1.15
+	mandatoryLatestDocumentVersion,      // 6.1.16
+	mandatoryDocumentStatusDraft,        // 6.1.17
+	mandatoryReleasedRevisionHistory,    // 6.1.18
+	mandatoryPreReleaseRevisions,        // 6.1.19
+	mandatoryNonDraftVersion,            // 6.1.20
+	mandatoryMissingRevision,            // 6.1.21
+	mandatoryMultipleRevisions,          // 6.1.22
+	mandatoryMultipleCVE,                // 6.1.23
+	mandatoryMultipleInvolvements,       // 6.1.24
+	mandatoryMultipleHashAlgorithms,     // 6.1.25
+	mandatoryProhibitedCategory,         // 6.1.26
+	mandatoryProfileTests,               // 6.1.27
+	mandatoryTranslation,                // 6.1.28
+	mandatoryRemediationWithoutProducts, // 6.1.29
+	mandatoryMixedVersioning,            // 6.1.30
+	mandatoryVersionRange,               // 6.1.31
+	mandatoryFlagWithoutProducts,        // 6.1.32
+	mandatoryMultipleVEXFlags,           // 6.1.33
+}
+
+// RunMandatoryTests runs the mandatory tests of section 6.1
+// of the CSAF standard on the document doc. doc is either
+// an *Advisory or a de-serialized JSON document.
+// The document should be valid against the JSON schema.
+// Returns the findings sorted by test id. An error is returned
+// if the document could not be converted into an advisory.
+func RunMandatoryTests(doc interface{}) (TestResults, error) {
+	t, err := newTester(doc)
+	if err != nil {
+		return nil, err
+	}
+	return t.run(mandatoryTests, TestError), nil
+}
+
+// category returns the document category.
+func (adv *Advisory) category() string {
+	if adv.Document == nil {
+		return ""
+	}
+	return deref(adv.Document.Category)
+}
+
+// status returns the document status.
+func (adv *Advisory) status() DocumentStatus {
+	if adv.Document == nil || adv.Document.Tracking == nil ||
+		adv.Document.Tracking.Status == nil {
+		return ""
+	}
+	return *adv.Document.Tracking.Status
+}
+
+func mandatoryMissingProductID(t *tester) {
+	defined := map[ProductID]bool{}
+	for _, d := range t.adv.productDefinitions() {
+		defined[d.id] = true
+	}
+	for _, r := range t.adv.productReferences() {
+		if !defined[r.id] {
+			t.add("6.1.1", r.pointer,
+				"product id %q is not defined in the product tree", r.id)
+		}
+	}
+}
+
+func mandatoryMultipleProductID(t *tester) {
+	seen := map[ProductID]string{}
+	for _, d := range t.adv.productDefinitions() {
+		if first, ok := seen[d.id]; ok {
+			t.add("6.1.2", d.pointer,
+				"product id %q is already defined at %s", d.id, first)
+			continue
+		}
+		seen[d.id] = d.pointer
+	}
+}
+
+func mandatoryCircularProductID(t *tester) {
+	if t.adv.ProductTree == nil {
+		return
+	}
+	for i, r := range t.adv.ProductTree.Relationships {
+		if r == nil || r.FullProductName == nil || r.FullProductName.ProductID == nil {
+			continue
+		}
+		id := *r.FullProductName.ProductID
+		pointer := fmt.Sprintf("/product_tree/relationships/%d", i)
+		if r.ProductReference != nil && *r.ProductReference == id {
+			t.add("6.1.3", pointer+"/product_reference",
+				"relationship refers to the product id %q it defines", id)
+		}
+		if r.RelatesToProductReference != nil && *r.RelatesToProductReference == id {
+			t.add("6.1.3", pointer+"/relates_to_product_reference",
+				"relationship refers to the product id %q it defines", id)
+		}
+	}
+}
+
+func mandatoryMissingGroupID(t *tester) {
+	defined := map[ProductGroupID]bool{}
+	for _, d := range t.adv.groupDefinitions() {
+		defined[d.id] = true
+	}
+	for _, r := range t.adv.groupReferences() {
+		if !defined[r.id] {
+			t.add("6.1.4", r.pointer,
+				"product group id %q is not defined in the product tree", r.id)
+		}
+	}
+}
+
+func mandatoryMultipleGroupID(t *tester) {
+	seen := map[ProductGroupID]string{}
+	for _, d := range t.adv.groupDefinitions() {
+		if first, ok := seen[d.id]; ok {
+			t.add("6.1.5", d.pointer,
+				"product group id %q is already defined at %s", d.id, first)
+			continue
+		}
+		seen[d.id] = d.pointer
+	}
+}
+
+// statusGroups maps the product status lists to the
+// groups which must not contradict each other.
+var statusGroups = map[string]string{
+	"first_affected":      "affected",
+	"known_affected":      "affected",
+	"last_affected":       "affected",
+	"known_not_affected":  "not affected",
+	"first_fixed":         "fixed",
+	"fixed":               "fixed",
+	"under_investigation": "under investigation",
+}
+
+func mandatoryContradictingStatus(t *tester) {
+	for i, v := range t.adv.Vulnerabilities {
+		if v == nil {
+			continue
+		}
+		groups := map[ProductID]string{}
+		for _, f := range v.ProductStatus.fields() {
+			group, ok := statusGroups[f.name]
+			if !ok {
+				continue
+			}
+			for j, p := range f.products {
+				if other, ok := groups[p]; ok && other != group {
+					t.add("6.1.6",
+						fmt.Sprintf("/vulnerabilities/%d/product_status/%s/%d", i, f.name, j),
+						"product %q is marked as %s and %s", p, other, group)
+					continue
+				}
+				groups[p] = group
+			}
+		}
+	}
+}
+
+func mandatoryMultipleScores(t *tester) {
+	for i, v := range t.adv.Vulnerabilities {
+		if v == nil {
+			continue
+		}
+		seen := map[ProductID]map[string]bool{}
+		for j, s := range v.Scores {
+			if s == nil {
+				continue
+			}
+			var versions []string
+			if s.CVSSv2 != nil {
+				versions = append(versions, deref(s.CVSSv2.Version))
+			}
+			if s.CVSSv3 != nil {
+				versions = append(versions, deref(s.CVSSv3.Version))
+			}
+			for k, p := range s.Products {
+				vs := seen[p]
+				if vs == nil {
+					vs = map[string]bool{}
+					seen[p] = vs
+				}
+				for _, version := range versions {
+					if vs[version] {
+						t.add("6.1.7",
+							fmt.Sprintf("/vulnerabilities/%d/scores/%d/products/%d", i, j, k),
+							"product %q has more than one CVSS %s score", p, version)
+					}
+					vs[version] = true
+				}
+			}
+		}
+	}
+}
+
+// walkScores calls fn for every score in the advisory.
+func (adv *Advisory) walkScores(fn func(*Score, string)) {
+	for i, v := range adv.Vulnerabilities {
+		if v == nil {
+			continue
+		}
+		for j, s := range v.Scores {
+			if s != nil {
+				fn(s, fmt.Sprintf("/vulnerabilities/%d/scores/%d", i, j))
+			}
+		}
+	}
+}
+
+// validateAgainst validates the JSON representation of x with
+// the given schema.
+func validateAgainst(cs *compiledSchema, x interface{}) ([]string, error) {
+	data, err := json.Marshal(x)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return cs.validate(doc)
+}
+
+func mandatoryInvalidCVSS(t *tester) {
+	check := func(cs *compiledSchema, x interface{}, pointer string) {
+		errs, err := validateAgainst(cs, x)
+		if err != nil {
+			t.add("6.1.8", pointer, "validation failed: %v", err)
+			return
+		}
+		for _, e := range errs {
+			t.add("6.1.8", pointer, "%s", e)
+		}
+	}
+	t.adv.walkScores(func(s *Score, pointer string) {
+		if s.CVSSv2 != nil {
+			check(&compiledCVSS20Schema, s.CVSSv2, pointer+"/cvss_v2")
+		}
+		if s.CVSSv3 != nil {
+			switch version := deref(s.CVSSv3.Version); version {
+			case "3.0":
+				check(&compiledCVSS30Schema, s.CVSSv3, pointer+"/cvss_v3")
+			case "3.1":
+				check(&compiledCVSS31Schema, s.CVSSv3, pointer+"/cvss_v3")
+			default:
+				t.add("6.1.8", pointer+"/cvss_v3/version",
+					"unsupported CVSS version %q", version)
+			}
+		}
+	})
+}
+
+// cvss2Vector parses the vector of a CVSS v2 object.
+func (c *CVSSv2) vector() (cvssVector, error) {
+	return parseCVSSVector(deref(c.VectorString), "", cvss2Metrics)
+}
+
+// cvss3Vector parses the vector of a CVSS v3 object.
+func (c *CVSSv3) vector() (cvssVector, error) {
+	return parseCVSSVector(
+		deref(c.VectorString), "CVSS:"+deref(c.Version), cvss3Metrics)
+}
+
+func mandatoryCVSSComputation(t *tester) {
+	score := func(pointer, name string, given *float64, computed float64) {
+		if given != nil && !sameScore(*given, computed) {
+			t.add("6.1.9", pointer+"/"+name,
+				"%s is %.1f but computes to %.1f", name, *given, computed)
+		}
+	}
+	severity := func(pointer, name string, given *string, computed float64) {
+		if given != nil && *given != cvss3Severity(computed) {
+			t.add("6.1.9", pointer+"/"+name,
+				"%s is %q but computes to %q", name, *given, cvss3Severity(computed))
+		}
+	}
+	t.adv.walkScores(func(s *Score, pointer string) {
+		if c := s.CVSSv2; c != nil {
+			// Invalid vectors are reported by 6.1.8 already.
+			if v, err := c.vector(); err == nil {
+				p := pointer + "/cvss_v2"
+				scores := v.cvss2Scores()
+				score(p, "baseScore", c.BaseScore, scores.base)
+				score(p, "temporalScore", c.TemporalScore, scores.temporal)
+				score(p, "environmentalScore", c.EnvironmentalScore, scores.environmental)
+			}
+		}
+		if c := s.CVSSv3; c != nil {
+			if v, err := c.vector(); err == nil {
+				p := pointer + "/cvss_v3"
+				scores := v.cvss3Scores(deref(c.Version) == "3.1")
+				score(p, "baseScore", c.BaseScore, scores.base)
+				severity(p, "baseSeverity", c.BaseSeverity, scores.base)
+				score(p, "temporalScore", c.TemporalScore, scores.temporal)
+				severity(p, "temporalSeverity", c.TemporalSeverity, scores.temporal)
+				score(p, "environmentalScore", c.EnvironmentalScore, scores.environmental)
+				severity(p, "environmentalSeverity", c.EnvironmentalSeverity, scores.environmental)
+			}
+		}
+	})
+}
+
+func mandatoryInconsistentCVSS(t *tester) {
+	t.adv.walkScores(func(s *Score, pointer string) {
+		if c := s.CVSSv2; c != nil {
+			if v, err := c.vector(); err == nil {
+				for _, msg := range v.inconsistencies(cvss2Metrics, c.properties()) {
+					t.add("6.1.10", pointer+"/cvss_v2", "%s", msg)
+				}
+			}
+		}
+		if c := s.CVSSv3; c != nil {
+			if v, err := c.vector(); err == nil {
+				for _, msg := range v.inconsistencies(cvss3Metrics, c.properties()) {
+					t.add("6.1.10", pointer+"/cvss_v3", "%s", msg)
+				}
+			}
+		}
+	})
+}
+
+var cweIDPattern = regexp.MustCompile(`^CWE-[1-9]\d{0,5}$`)
+
+// mandatoryCWE checks the form of the CWE entries.
+// As there is no CWE catalogue at hand the ids and names
+// are not checked against the official list.
+func mandatoryCWE(t *tester) {
+	for i, v := range t.adv.Vulnerabilities {
+		if v == nil || v.CWE == nil {
+			continue
+		}
+		pointer := fmt.Sprintf("/vulnerabilities/%d/cwe", i)
+		if id := deref(v.CWE.ID); !cweIDPattern.MatchString(id) {
+			t.add("6.1.11", pointer+"/id", "invalid CWE id %q", id)
+		}
+		if strings.TrimSpace(deref(v.CWE.Name)) == "" {
+			t.add("6.1.11", pointer+"/name", "CWE name is empty")
+		}
+	}
+}
+
+func mandatoryLanguage(t *tester) {
+	d := t.adv.Document
+	if d == nil {
+		return
+	}
+	check := func(lang *Lang, pointer string) {
+		if lang == nil {
+			return
+		}
+		if _, err := language.Parse(string(*lang)); err != nil {
+			t.add("6.1.12", pointer, "invalid language %q: %v", *lang, err)
+		}
+	}
+	check(d.Lang, "/document/lang")
+	check(d.SourceLang, "/document/source_lang")
+}
+
+var (
+	purlTypePattern      = regexp.MustCompile(`^[A-Za-z.+-][A-Za-z0-9.+-]*$`)
+	purlQualifierPattern = regexp.MustCompile(`^[A-Za-z.\-_][A-Za-z0-9.\-_]*$`)
+)
+
+// checkPURL checks if s is a valid package URL.
+func checkPURL(s string) error {
+	rest := s
+	if !strings.HasPrefix(rest, "pkg:") {
+		return fmt.Errorf("scheme is not %q", "pkg")
+	}
+	rest = strings.TrimLeft(rest[len("pkg:"):], "/")
+
+	if idx := strings.IndexByte(rest, '#'); idx >= 0 {
+		rest = rest[:idx]
+	}
+	if idx := strings.IndexByte(rest, '?'); idx >= 0 {
+		for _, q := range strings.Split(rest[idx+1:], "&") {
+			kv := strings.SplitN(q, "=", 2)
+			if len(kv) != 2 || kv[1] == "" {
+				return fmt.Errorf("invalid qualifier %q", q)
+			}
+			if !purlQualifierPattern.MatchString(kv[0]) {
+				return fmt.Errorf("invalid qualifier key %q", kv[0])
+			}
+		}
+		rest = rest[:idx]
+	}
+
+	parts := strings.Split(strings.TrimRight(rest, "/"), "/")
+	if len(parts) < 2 {
+		return fmt.Errorf("missing type or name")
+	}
+	if !purlTypePattern.MatchString(parts[0]) {
+		return fmt.Errorf("invalid type %q", parts[0])
+	}
+	name := parts[len(parts)-1]
+	if idx := strings.LastIndexByte(name, '@'); idx >= 0 {
+		name = name[:idx]
+	}
+	if name == "" {
+		return fmt.Errorf("missing name")
+	}
+	for _, p := range parts[1:] {
+		if _, err := url.PathUnescape(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mandatoryPURL(t *tester) {
+	names, pointers := t.adv.fullProductNames()
+	for i, fpn := range names {
+		if pih := fpn.ProductIdentificationHelper; pih != nil && pih.PURL != nil {
+			if err := checkPURL(*pih.PURL); err != nil {
+				t.add("6.1.13", pointers[i]+"/product_identification_helper/purl",
+					"invalid package URL %q: %v", *pih.PURL, err)
+			}
+		}
+	}
+}
+
+func mandatorySortedRevisionHistory(t *tester) {
+	revs, indices := t.adv.sortedRevisions()
+	for i := 1; i < len(revs); i++ {
+		if compareRevisionNumbers(*revs[i-1].Number, *revs[i].Number) > 0 {
+			t.add("6.1.14",
+				fmt.Sprintf("/document/tracking/revision_history/%d/number", indices[i]),
+				"revision %q is dated after revision %q but has a lower number",
+				*revs[i].Number, *revs[i-1].Number)
+		}
+	}
+}
+
+func mandatoryTranslator(t *tester) {
+	d := t.adv.Document
+	if d == nil || d.Publisher == nil || d.Publisher.Category == nil {
+		return
+	}
+	if *d.Publisher.Category == CSAFCategoryTranslator && d.SourceLang == nil {
+		t.add("6.1.15", "/document/source_lang",
+			"source_lang is missing although the publisher is a translator")
+	}
+}
+
+func mandatoryLatestDocumentVersion(t *tester) {
+	d := t.adv.Document
+	if d == nil || d.Tracking == nil || d.Tracking.Version == nil {
+		return
+	}
+	revs, _ := t.adv.sortedRevisions()
+	if len(revs) == 0 {
+		return
+	}
+	last := *revs[len(revs)-1].Number
+	version := *d.Tracking.Version
+	draft := t.adv.status() == DocumentStatusDraft
+
+	normalize := func(rn RevisionNumber) string {
+		v := parseRevisionNumber(rn)
+		if !v.semantic {
+			return string(rn)
+		}
+		s := fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch)
+		if v.pre != "" && !draft {
+			s += "-" + v.pre
+		}
+		return s
+	}
+
+	if normalize(version) != normalize(last) {
+		t.add("6.1.16", "/document/tracking/version",
+			"version %q does not match the latest revision %q", version, last)
+	}
+}
+
+func mandatoryDocumentStatusDraft(t *tester) {
+	d := t.adv.Document
+	if d == nil || d.Tracking == nil || d.Tracking.Version == nil {
+		return
+	}
+	v := parseRevisionNumber(*d.Tracking.Version)
+	if (v.zero() || v.pre != "") && t.adv.status() != DocumentStatusDraft {
+		t.add("6.1.17", "/document/tracking/status",
+			"status must be %q for version %q", DocumentStatusDraft, *d.Tracking.Version)
+	}
+}
+
+// released returns true if the document is final or interim.
+func (adv *Advisory) released() bool {
+	switch adv.status() {
+	case DocumentStatusFinal, DocumentStatusInterim:
+		return true
+	}
+	return false
+}
+
+// walkRevisionNumbers calls fn for every number in the revision history.
+func (adv *Advisory) walkRevisionNumbers(fn func(RevisionNumber, string)) {
+	if adv.Document == nil || adv.Document.Tracking == nil {
+		return
+	}
+	for i, r := range adv.Document.Tracking.RevisionHistory {
+		if r != nil && r.Number != nil {
+			fn(*r.Number,
+				fmt.Sprintf("/document/tracking/revision_history/%d/number", i))
+		}
+	}
+}
+
+func mandatoryReleasedRevisionHistory(t *tester) {
+	if !t.adv.released() {
+		return
+	}
+	t.adv.walkRevisionNumbers(func(rn RevisionNumber, pointer string) {
+		if parseRevisionNumber(rn).zero() {
+			t.add("6.1.18", pointer,
+				"revision %q is not allowed in a %s document", rn, t.adv.status())
+		}
+	})
+}
+
+func mandatoryPreReleaseRevisions(t *tester) {
+	t.adv.walkRevisionNumbers(func(rn RevisionNumber, pointer string) {
+		if parseRevisionNumber(rn).pre != "" {
+			t.add("6.1.19", pointer,
+				"revision %q has a pre-release part", rn)
+		}
+	})
+}
+
+func mandatoryNonDraftVersion(t *tester) {
+	d := t.adv.Document
+	if !t.adv.released() || d.Tracking.Version == nil {
+		return
+	}
+	if parseRevisionNumber(*d.Tracking.Version).pre != "" {
+		t.add("6.1.20", "/document/tracking/version",
+			"version %q has a pre-release part in a %s document",
+			*d.Tracking.Version, t.adv.status())
+	}
+}
+
+func mandatoryMissingRevision(t *tester) {
+	revs, indices := t.adv.sortedRevisions()
+	if len(revs) == 0 {
+		return
+	}
+	pointer := func(i int) string {
+		return fmt.Sprintf("/document/tracking/revision_history/%d/number", indices[i])
+	}
+	first := parseRevisionNumber(*revs[0].Number)
+	if first.valid && first.major > 1 {
+		t.add("6.1.21", pointer(0),
+			"first revision %q does not start with 0 or 1", *revs[0].Number)
+	}
+	for i := 1; i < len(revs); i++ {
+		prev := parseRevisionNumber(*revs[i-1].Number)
+		curr := parseRevisionNumber(*revs[i].Number)
+		if prev.valid && curr.valid && curr.major > prev.major+1 {
+			t.add("6.1.21", pointer(i),
+				"revision %q follows revision %q leaving a gap",
+				*revs[i].Number, *revs[i-1].Number)
+		}
+	}
+}
+
+func mandatoryMultipleRevisions(t *tester) {
+	seen := map[RevisionNumber]string{}
+	t.adv.walkRevisionNumbers(func(rn RevisionNumber, pointer string) {
+		if first, ok := seen[rn]; ok {
+			t.add("6.1.22", pointer,
+				"revision %q is already defined at %s", rn, first)
+			return
+		}
+		seen[rn] = pointer
+	})
+}
+
+func mandatoryMultipleCVE(t *tester) {
+	seen := map[string]string{}
+	for i, v := range t.adv.Vulnerabilities {
+		if v == nil || v.CVE == nil {
+			continue
+		}
+		pointer := fmt.Sprintf("/vulnerabilities/%d/cve", i)
+		if first, ok := seen[*v.CVE]; ok {
+			t.add("6.1.23", pointer, "%s is already used at %s", *v.CVE, first)
+			continue
+		}
+		seen[*v.CVE] = pointer
+	}
+}
+
+func mandatoryMultipleInvolvements(t *tester) {
+	for i, v := range t.adv.Vulnerabilities {
+		if v == nil {
+			continue
+		}
+		type key struct {
+			party InvolvementParty
+			date  DateTime
+		}
+		seen := map[key]string{}
+		for j, inv := range v.Involvements {
+			if inv == nil || inv.Party == nil {
+				continue
+			}
+			k := key{party: *inv.Party}
+			if inv.Date != nil {
+				k.date = *inv.Date
+			}
+			pointer := fmt.Sprintf("/vulnerabilities/%d/involvements/%d", i, j)
+			if first, ok := seen[k]; ok {
+				t.add("6.1.24", pointer,
+					"involvement of party %q at this date is already given at %s",
+					k.party, first)
+				continue
+			}
+			seen[k] = pointer
+		}
+	}
+}
+
+func mandatoryMultipleHashAlgorithms(t *tester) {
+	names, pointers := t.adv.fullProductNames()
+	for i, fpn := range names {
+		pih := fpn.ProductIdentificationHelper
+		if pih == nil {
+			continue
+		}
+		for j, h := range pih.Hashes {
+			if h == nil {
+				continue
+			}
+			seen := map[string]bool{}
+			for k, fh := range h.FileHashes {
+				if fh == nil || fh.Algorithm == nil {
+					continue
+				}
+				if seen[*fh.Algorithm] {
+					t.add("6.1.25",
+						fmt.Sprintf("%s/product_identification_helper/hashes/%d/file_hashes/%d",
+							pointers[i], j, k),
+						"hash algorithm %q is used more than once", *fh.Algorithm)
+				}
+				seen[*fh.Algorithm] = true
+			}
+		}
+	}
+}
+
+// prohibitedCategoryNames are the normalized names and values
+// of the profiles other than CSAF Base.
+var prohibitedCategoryNames = []string{
+	"securityincidentresponse",
+	"informationaladvisory",
+	"securityadvisory",
+	"vex",
+	"csafsecurityincidentresponse",
+	"csafinformationaladvisory",
+	"csafsecurityadvisory",
+	"csafvex",
+}
+
+func mandatoryProhibitedCategory(t *tester) {
+	category := t.adv.category()
+	switch category {
+	case profileBase, profileSecurityIncidentResponse,
+		profileInformationalAdvisory, profileSecurityAdvisory, profileVEX:
+		return
+	}
+	if strings.HasPrefix(strings.ToLower(category), "csaf_") {
+		t.add("6.1.26", "/document/category",
+			"category %q uses the reserved prefix csaf_", category)
+		return
+	}
+	normalized := strings.Map(func(r rune) rune {
+		switch r {
+		case '-', '_', ' ', '\t', '\n', '\r':
+			return -1
+		}
+		return r
+	}, strings.ToLower(category))
+	for _, name := range prohibitedCategoryNames {
+		if normalized == name {
+			t.add("6.1.26", "/document/category",
+				"category %q is too close to the name of a profile", category)
+			return
+		}
+	}
+}
+
+func mandatoryProfileTests(t *tester) {
+	adv := t.adv
+	switch adv.category() {
+	case profileSecurityIncidentResponse, profileInformationalAdvisory:
+		profileDocumentNotes(t)
+		profileDocumentReferences(t)
+		if adv.category() == profileInformationalAdvisory && len(adv.Vulnerabilities) > 0 {
+			t.add("6.1.27.3", "/vulnerabilities",
+				"an informational advisory must not contain vulnerabilities")
+		}
+	case profileSecurityAdvisory, profileVEX:
+		if adv.ProductTree == nil {
+			t.add("6.1.27.4", "/product_tree", "product_tree is missing")
+		}
+		if len(adv.Vulnerabilities) == 0 {
+			t.add("6.1.27.11", "/vulnerabilities", "vulnerabilities are missing")
+		}
+		for i, v := range adv.Vulnerabilities {
+			if v != nil && len(v.Notes) == 0 {
+				t.add("6.1.27.5", fmt.Sprintf("/vulnerabilities/%d/notes", i),
+					"notes are missing")
+			}
+		}
+		if adv.category() == profileSecurityAdvisory {
+			for i, v := range adv.Vulnerabilities {
+				if v != nil && v.ProductStatus == nil {
+					t.add("6.1.27.6", fmt.Sprintf("/vulnerabilities/%d/product_status", i),
+						"product_status is missing")
+				}
+			}
+		} else {
+			profileVEXTests(t)
+		}
+	}
+}
+
+func profileDocumentNotes(t *tester) {
+	for _, n := range t.adv.Document.Notes {
+		if n == nil || n.Category == nil {
+			continue
+		}
+		switch *n.Category {
+		case NoteCategoryDescription, NoteCategoryDetails,
+			NoteCategoryGeneral, NoteCategorySummary:
+			return
+		}
+	}
+	t.add("6.1.27.1", "/document/notes",
+		"a note of category description, details, general or summary is missing")
+}
+
+func profileDocumentReferences(t *tester) {
+	for _, r := range t.adv.Document.References {
+		if r != nil && r.Category != nil && *r.Category == ReferenceCategoryExternal {
+			return
+		}
+	}
+	t.add("6.1.27.2", "/document/references",
+		"a reference of category external is missing")
+}
+
+func profileVEXTests(t *tester) {
+	members := t.adv.groupMembers()
+	for i, v := range t.adv.Vulnerabilities {
+		if v == nil {
+			continue
+		}
+		pointer := fmt.Sprintf("/vulnerabilities/%d", i)
+		ps := v.ProductStatus
+		if ps == nil || len(ps.Fixed)+len(ps.KnownAffected)+
+			len(ps.KnownNotAffected)+len(ps.UnderInvestigation) == 0 {
+			t.add("6.1.27.7", pointer+"/product_status",
+				"none of fixed, known_affected, known_not_affected or under_investigation is given")
+		}
+		if v.CVE == nil && len(v.IDs) == 0 {
+			t.add("6.1.27.8", pointer, "neither cve nor ids are given")
+		}
+		if ps == nil {
+			continue
+		}
+
+		// 6.1.27.9
+		impact := map[ProductID]bool{}
+		for _, f := range v.Flags {
+			if f != nil {
+				for _, p := range resolveProducts(f.ProductIDs, f.GroupIDs, members) {
+					impact[p] = true
+				}
+			}
+		}
+		for _, th := range v.Threats {
+			if th != nil && th.Category != nil && *th.Category == ThreatCategoryImpact {
+				for _, p := range resolveProducts(th.ProductIDs, th.GroupIDs, members) {
+					impact[p] = true
+				}
+			}
+		}
+		for j, p := range ps.KnownNotAffected {
+			if !impact[p] {
+				t.add("6.1.27.9",
+					fmt.Sprintf("%s/product_status/known_not_affected/%d", pointer, j),
+					"impact statement for product %q is missing", p)
+			}
+		}
+
+		// 6.1.27.10
+		action := map[ProductID]bool{}
+		for _, r := range v.Remediations {
+			if r != nil {
+				for _, p := range resolveProducts(r.ProductIDs, r.GroupIDs, members) {
+					action[p] = true
+				}
+			}
+		}
+		for j, p := range ps.KnownAffected {
+			if !action[p] {
+				t.add("6.1.27.10",
+					fmt.Sprintf("%s/product_status/known_affected/%d", pointer, j),
+					"action statement for product %q is missing", p)
+			}
+		}
+	}
+}
+
+func mandatoryTranslation(t *tester) {
+	d := t.adv.Document
+	if d == nil || d.Lang == nil || d.SourceLang == nil {
+		return
+	}
+	if strings.EqualFold(string(*d.Lang), string(*d.SourceLang)) {
+		t.add("6.1.28", "/document/source_lang",
+			"source_lang equals lang %q", *d.Lang)
+	}
+}
+
+func mandatoryRemediationWithoutProducts(t *tester) {
+	for i, v := range t.adv.Vulnerabilities {
+		if v == nil {
+			continue
+		}
+		for j, r := range v.Remediations {
+			if r != nil && len(r.ProductIDs) == 0 && len(r.GroupIDs) == 0 {
+				t.add("6.1.29", fmt.Sprintf("/vulnerabilities/%d/remediations/%d", i, j),
+					"remediation refers to no product")
+			}
+		}
+	}
+}
+
+func mandatoryMixedVersioning(t *tester) {
+	d := t.adv.Document
+	if d == nil || d.Tracking == nil || d.Tracking.Version == nil {
+		return
+	}
+	semantic := parseRevisionNumber(*d.Tracking.Version).semantic
+	t.adv.walkRevisionNumbers(func(rn RevisionNumber, pointer string) {
+		if v := parseRevisionNumber(rn); v.valid && v.semantic != semantic {
+			t.add("6.1.30", pointer,
+				"revision %q mixes integer and semantic versioning with version %q",
+				rn, *d.Tracking.Version)
+		}
+	})
+}
+
+var versionRangePattern = regexp.MustCompile(
+	`(?i)(<|>|(^|\s)(after|all|before|earlier|later|prior|versions)(\s|$))`)
+
+func mandatoryVersionRange(t *tester) {
+	t.adv.walkBranches(func(b *Branch, pointer string) {
+		if b.Category == nil || *b.Category != BranchCategoryProductVersion ||
+			b.Name == nil {
+			return
+		}
+		if versionRangePattern.MatchString(*b.Name) {
+			t.add("6.1.31", pointer+"/name",
+				"product version %q contains a version range", *b.Name)
+		}
+	})
+}
+
+func mandatoryFlagWithoutProducts(t *tester) {
+	for i, v := range t.adv.Vulnerabilities {
+		if v == nil {
+			continue
+		}
+		for j, f := range v.Flags {
+			if f != nil && len(f.ProductIDs) == 0 && len(f.GroupIDs) == 0 {
+				t.add("6.1.32", fmt.Sprintf("/vulnerabilities/%d/flags/%d", i, j),
+					"flag refers to no product")
+			}
+		}
+	}
+}
+
+func mandatoryMultipleVEXFlags(t *tester) {
+	members := t.adv.groupMembers()
+	for i, v := range t.adv.Vulnerabilities {
+		if v == nil {
+			continue
+		}
+		seen := map[ProductID]bool{}
+		for j, f := range v.Flags {
+			if f == nil {
+				continue
+			}
+			current := map[ProductID]bool{}
+			for _, p := range resolveProducts(f.ProductIDs, f.GroupIDs, members) {
+				if current[p] {
+					continue
+				}
+				current[p] = true
+				if seen[p] {
+					t.add("6.1.33", fmt.Sprintf("/vulnerabilities/%d/flags/%d", i, j),
+						"product %q has more than one flag", p)
+				}
+			}
+			for p := range current {
+				seen[p] = true
+			}
+		}
+	}
+}
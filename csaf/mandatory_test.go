@@ -0,0 +1,134 @@
+package csaf
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func loadTestDocument(t *testing.T, modify func(map[string]interface{})) interface{} {
+	t.Helper()
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(exampleAdvisory), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if modify != nil {
+		modify(doc)
+	}
+	return doc
+}
+
+func lookup(doc interface{}, keys ...interface{}) interface{} {
+	for _, k := range keys {
+		switch key := k.(type) {
+		case string:
+			doc = doc.(map[string]interface{})[key]
+		case int:
+			doc = doc.([]interface{})[key]
+		}
+	}
+	return doc
+}
+
+func TestRunMandatoryTestsClean(t *testing.T) {
+	doc := loadTestDocument(t, nil)
+	if errs, err := ValidateCSAF(doc); err != nil || len(errs) > 0 {
+		t.Fatalf("Example is not schema valid: %v %v", err, errs)
+	}
+	results, err := RunMandatoryTests(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		t.Errorf("Unexpected finding: %s", r.String())
+	}
+}
+
+func TestRunMandatoryTests(t *testing.T) {
+	for _, x := range []struct {
+		id      string
+		pointer string
+		modify  func(map[string]interface{})
+	}{
+		{"6.1.1", "/vulnerabilities/0/product_status/fixed/0", func(doc map[string]interface{}) {
+			ps := lookup(doc, "vulnerabilities", 0, "product_status").(map[string]interface{})
+			ps["fixed"] = []interface{}{"CSAFPID-9999"}
+		}},
+		{"6.1.2", "/product_tree/full_product_names/0/product_id", func(doc map[string]interface{}) {
+			lookup(doc, "product_tree", "full_product_names", 0).(map[string]interface{})["product_id"] = "CSAFPID-0001"
+		}},
+		{"6.1.6", "/vulnerabilities/0/product_status/known_not_affected/0", func(doc map[string]interface{}) {
+			ps := lookup(doc, "vulnerabilities", 0, "product_status").(map[string]interface{})
+			ps["known_not_affected"] = []interface{}{"CSAFPID-0001"}
+		}},
+		{"6.1.9", "/vulnerabilities/0/scores/0/cvss_v3/baseScore", func(doc map[string]interface{}) {
+			lookup(doc, "vulnerabilities", 0, "scores", 0, "cvss_v3").(map[string]interface{})["baseScore"] = 9.1
+		}},
+		{"6.1.10", "/vulnerabilities/0/scores/0/cvss_v2", func(doc map[string]interface{}) {
+			lookup(doc, "vulnerabilities", 0, "scores", 0, "cvss_v2").(map[string]interface{})["accessVector"] = "LOCAL"
+		}},
+		{"6.1.14", "/document/tracking/revision_history/1/number", func(doc map[string]interface{}) {
+			tr := lookup(doc, "document", "tracking").(map[string]interface{})
+			lookup(tr, "revision_history", 0).(map[string]interface{})["number"] = "3"
+		}},
+		{"6.1.16", "/document/tracking/version", func(doc map[string]interface{}) {
+			lookup(doc, "document", "tracking").(map[string]interface{})["version"] = "1"
+		}},
+		{"6.1.27.6", "/vulnerabilities/0/product_status", func(doc map[string]interface{}) {
+			delete(lookup(doc, "vulnerabilities", 0).(map[string]interface{}), "product_status")
+		}},
+		{"6.1.31", "/product_tree/branches/0/branches/0/name", func(doc map[string]interface{}) {
+			lookup(doc, "product_tree", "branches", 0, "branches", 0).(map[string]interface{})["name"] = "<1.0"
+		}},
+	} {
+		doc := loadTestDocument(t, x.modify)
+		results, err := RunMandatoryTests(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var found bool
+		var got []string
+		for _, r := range results {
+			got = append(got, r.String())
+			if r.ID == x.id && r.Pointer == x.pointer {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%s: expected finding at %s, got: %s",
+				x.id, x.pointer, strings.Join(got, "; "))
+		}
+	}
+}
+
+func TestCVSSScores(t *testing.T) {
+	for _, x := range []struct {
+		vector  string
+		version string
+		base    float64
+		env     float64
+	}{
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", "3.1", 9.8, 9.8},
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N", "3.1", 6.1, 6.1},
+		{"CVSS:3.0/AV:L/AC:H/PR:H/UI:R/S:U/C:L/I:N/A:N/E:U/CR:H", "3.0", 1.8, 2.3},
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:N/I:N/A:N", "3.1", 0, 0},
+	} {
+		v, err := parseCVSSVector(x.vector, "CVSS:"+x.version, cvss3Metrics)
+		if err != nil {
+			t.Fatalf("%s: %v", x.vector, err)
+		}
+		scores := v.cvss3Scores(x.version == "3.1")
+		if !sameScore(scores.base, x.base) || !sameScore(scores.environmental, x.env) {
+			t.Errorf("%s: expected %.1f/%.1f, got %.1f/%.1f",
+				x.vector, x.base, x.env, scores.base, scores.environmental)
+		}
+	}
+
+	v, err := parseCVSSVector("AV:N/AC:L/Au:N/C:C/I:C/A:C/E:F/RL:OF/RC:C", "", cvss2Metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scores := v.cvss2Scores(); !sameScore(scores.base, 10) || !sameScore(scores.temporal, 8.3) {
+		t.Errorf("CVSS v2: expected 10.0/8.3, got %.1f/%.1f", scores.base, scores.temporal)
+	}
+}
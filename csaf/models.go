@@ -218,13 +218,21 @@ type AggregatorCSAFProvider struct {
 	Mirrors  []ProviderURL                   `json:"mirrors,omitempty"`  // required
 }
 
+// AggregatorCSAFPublisher reflects one 'csaf_publisher' in an aggregator.
+type AggregatorCSAFPublisher struct {
+	Metadata       *AggregatorCSAFProviderMetadata `json:"metadata,omitempty"`        // required
+	Mirrors        []ProviderURL                   `json:"mirrors,omitempty"`         // required
+	UpdateInterval string                          `json:"update_interval,omitempty"` // required
+}
+
 // Aggregator is the CSAF Aggregator.
 type Aggregator struct {
-	Aggregator    *AggregatorInfo           `json:"aggregator,omitempty"`         // required
-	Version       *AggregatorVersion        `json:"aggregator_version,omitempty"` // required
-	CanonicalURL  *AggregatorURL            `json:"canonical_url,omitempty"`      // required
-	CSAFProviders []*AggregatorCSAFProvider `json:"csaf_providers,omitempty"`     // required
-	LastUpdated   *TimeStamp                `json:"last_updated,omitempty"`       // required
+	Aggregator     *AggregatorInfo            `json:"aggregator,omitempty"`         // required
+	Version        *AggregatorVersion         `json:"aggregator_version,omitempty"` // required
+	CanonicalURL   *AggregatorURL             `json:"canonical_url,omitempty"`      // required
+	CSAFProviders  []*AggregatorCSAFProvider  `json:"csaf_providers,omitempty"`     // required
+	CSAFPublishers []*AggregatorCSAFPublisher `json:"csaf_publishers,omitempty"`
+	LastUpdated    *TimeStamp                 `json:"last_updated,omitempty"` // required
 }
 
 // Validate validates the current state of the AggregatorCategory.
@@ -296,6 +304,23 @@ func (acp *AggregatorCSAFProvider) Validate() error {
 	return nil
 }
 
+// Validate validates the current state of the AggregatorCSAFPublisher.
+func (acp *AggregatorCSAFPublisher) Validate() error {
+	if acp == nil {
+		return errors.New("aggregator.csaf_publishers[] not allowed to be nil")
+	}
+	if err := acp.Metadata.Validate(); err != nil {
+		return err
+	}
+	if len(acp.Mirrors) == 0 {
+		return errors.New("aggregator.csaf_publishers[].mirrors is mandatory")
+	}
+	if acp.UpdateInterval == "" {
+		return errors.New("aggregator.csaf_publishers[].update_interval is mandatory")
+	}
+	return nil
+}
+
 // Validate validates the current state of the Aggregator.
 func (a *Aggregator) Validate() error {
 	if err := a.Aggregator.Validate(); err != nil {
@@ -312,6 +337,11 @@ func (a *Aggregator) Validate() error {
 			return err
 		}
 	}
+	for _, publisher := range a.CSAFPublishers {
+		if err := publisher.Validate(); err != nil {
+			return err
+		}
+	}
 	if a.LastUpdated == nil {
 		return errors.New("Aggregator.LastUpdate == nil")
 	}
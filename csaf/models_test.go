@@ -0,0 +1,81 @@
+package csaf
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAggregatorCSAFPublisherValidate(t *testing.T) {
+	var (
+		category    = CSAFCategoryVendor
+		name        = "ACME"
+		namespace   = "https://example.com"
+		lastUpdated = TimeStamp(time.Now())
+		url         = ProviderURL("https://example.com/.well-known/csaf/provider-metadata.json")
+		role        = MetadataRolePublisher
+	)
+	metadata := func() *AggregatorCSAFProviderMetadata {
+		return &AggregatorCSAFProviderMetadata{
+			LastUpdated: &lastUpdated,
+			Publisher: &Publisher{
+				Category:  &category,
+				Name:      &name,
+				Namespace: &namespace,
+			},
+			Role: &role,
+			URL:  &url,
+		}
+	}
+	mirrors := []ProviderURL{"https://aggregator.example.com/.well-known/csaf-aggregator/acme"}
+
+	for _, tc := range []struct {
+		name      string
+		publisher func() *AggregatorCSAFPublisher
+		err       string
+	}{
+		{"valid", func() *AggregatorCSAFPublisher {
+			return &AggregatorCSAFPublisher{metadata(), mirrors, "daily"}
+		}, ""},
+		{"without role", func() *AggregatorCSAFPublisher {
+			md := metadata()
+			md.Role = nil
+			return &AggregatorCSAFPublisher{md, mirrors, "daily"}
+		}, ""},
+		{"nil", func() *AggregatorCSAFPublisher {
+			return nil
+		}, "not allowed to be nil"},
+		{"no metadata", func() *AggregatorCSAFPublisher {
+			return &AggregatorCSAFPublisher{nil, mirrors, "daily"}
+		}, "metadata is mandatory"},
+		{"no last updated", func() *AggregatorCSAFPublisher {
+			md := metadata()
+			md.LastUpdated = nil
+			return &AggregatorCSAFPublisher{md, mirrors, "daily"}
+		}, "last_updated is mandatory"},
+		{"no publisher name", func() *AggregatorCSAFPublisher {
+			md := metadata()
+			md.Publisher.Name = nil
+			return &AggregatorCSAFPublisher{md, mirrors, "daily"}
+		}, "publisher.name is mandatory"},
+		{"no url", func() *AggregatorCSAFPublisher {
+			md := metadata()
+			md.URL = nil
+			return &AggregatorCSAFPublisher{md, mirrors, "daily"}
+		}, "url is mandatory"},
+		{"no mirrors", func() *AggregatorCSAFPublisher {
+			return &AggregatorCSAFPublisher{metadata(), nil, "daily"}
+		}, "mirrors is mandatory"},
+		{"no update interval", func() *AggregatorCSAFPublisher {
+			return &AggregatorCSAFPublisher{metadata(), mirrors, ""}
+		}, "update_interval is mandatory"},
+	} {
+		err := tc.publisher().Validate()
+		switch {
+		case tc.err == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		case tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)):
+			t.Errorf("%s: expected error containing %q, got %v", tc.name, tc.err, err)
+		}
+	}
+}
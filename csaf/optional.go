@@ -0,0 +1,425 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package csaf
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/csaf-poc/csaf_distribution/util"
+)
+
+// optionalTests are the tests of section 6.2.
+// 6.2.13 (sorting) is not implemented as the order
+// of the keys is lost when de-serializing the document.
+var optionalTests = []func(*tester){
+	optionalUnusedProductID,             // 6.2.1
+	optionalMissingRemediation,          // 6.2.2
+	optionalMissingScore,                // 6.2.3
+	optionalBuildMetadata,               // 6.2.4
+	optionalOlderInitialReleaseDate,     // 6.2.5
+	optionalOlderCurrentReleaseDate,     // 6.2.6
+	optionalMissingInvolvementDate,      // 6.2.7
+	optionalOnlyHashAlgorithm,           // 6.2.8, 6.2.9
+	optionalMissingTLPLabel,             // 6.2.10
+	optionalMissingCanonicalURL,         // 6.2.11
+	optionalMissingLanguage,             // 6.2.12
+	optionalPrivateLanguage,             // 6.2.14
+	optionalDefaultLanguage,             // 6.2.15
+	optionalMissingIdentificationHelper, // 6.2.16
+	optionalCVEInIDs,                    // 6.2.17
+	optionalVersionRangeWithoutVers,     // 6.2.18
+	optionalCVSSForFixedProducts,        // 6.2.19
+	optionalAdditionalProperties,        // 6.2.20
+}
+
+// RunOptionalTests runs the optional tests of section 6.2
+// of the CSAF standard on the document doc. doc is either
+// an *Advisory or a de-serialized JSON document.
+// The findings are reported as warnings.
+func RunOptionalTests(doc interface{}) (TestResults, error) {
+	t, err := newTester(doc)
+	if err != nil {
+		return nil, err
+	}
+	return t.run(optionalTests, TestWarning), nil
+}
+
+// affectedStatus are the product status lists of the affected group.
+var affectedStatus = []string{"first_affected", "known_affected", "last_affected"}
+
+// statusProducts returns the products of the given product status lists
+// along with their locations.
+func statusProducts(ps *ProductStatus, pointer string, names ...string) []productRef {
+	var refs []productRef
+	for _, f := range ps.fields() {
+		for _, name := range names {
+			if f.name == name {
+				refs = appendProducts(refs, f.products, pointer+"/product_status/"+f.name)
+			}
+		}
+	}
+	return refs
+}
+
+func optionalUnusedProductID(t *tester) {
+	used := map[ProductID]bool{}
+	for _, r := range t.adv.productReferences() {
+		used[r.id] = true
+	}
+	for _, d := range t.adv.productDefinitions() {
+		if !used[d.id] {
+			t.add("6.2.1", d.pointer, "product id %q is never used", d.id)
+		}
+	}
+}
+
+func optionalMissingRemediation(t *tester) {
+	members := t.adv.groupMembers()
+	for i, v := range t.adv.Vulnerabilities {
+		if v == nil {
+			continue
+		}
+		remediated := map[ProductID]bool{}
+		for _, r := range v.Remediations {
+			if r != nil {
+				for _, p := range resolveProducts(r.ProductIDs, r.GroupIDs, members) {
+					remediated[p] = true
+				}
+			}
+		}
+		pointer := fmt.Sprintf("/vulnerabilities/%d", i)
+		names := append([]string{"under_investigation"}, affectedStatus...)
+		for _, r := range statusProducts(v.ProductStatus, pointer, names...) {
+			if !remediated[r.id] {
+				t.add("6.2.2", r.pointer, "remediation for product %q is missing", r.id)
+			}
+		}
+	}
+}
+
+func optionalMissingScore(t *tester) {
+	for i, v := range t.adv.Vulnerabilities {
+		if v == nil {
+			continue
+		}
+		scored := map[ProductID]bool{}
+		for _, s := range v.Scores {
+			if s != nil {
+				for _, p := range s.Products {
+					scored[p] = true
+				}
+			}
+		}
+		pointer := fmt.Sprintf("/vulnerabilities/%d", i)
+		for _, r := range statusProducts(v.ProductStatus, pointer, affectedStatus...) {
+			if !scored[r.id] {
+				t.add("6.2.3", r.pointer, "score for product %q is missing", r.id)
+			}
+		}
+	}
+}
+
+func optionalBuildMetadata(t *tester) {
+	t.adv.walkRevisionNumbers(func(rn RevisionNumber, pointer string) {
+		if parseRevisionNumber(rn).build != "" {
+			t.add("6.2.4", pointer, "revision %q contains build metadata", rn)
+		}
+	})
+}
+
+func optionalOlderInitialReleaseDate(t *tester) {
+	revs, _ := t.adv.sortedRevisions()
+	tr := t.adv.Document.trackingOrNil()
+	if len(revs) == 0 || tr == nil || tr.InitialReleaseDate == nil {
+		return
+	}
+	initial, err := tr.InitialReleaseDate.Time()
+	if err != nil {
+		return
+	}
+	oldest, _ := revs[0].Date.Time()
+	if initial.Before(oldest) {
+		t.add("6.2.5", "/document/tracking/initial_release_date",
+			"initial release date %s is older than the oldest revision %s",
+			*tr.InitialReleaseDate, *revs[0].Date)
+	}
+}
+
+func optionalOlderCurrentReleaseDate(t *tester) {
+	revs, _ := t.adv.sortedRevisions()
+	tr := t.adv.Document.trackingOrNil()
+	if len(revs) == 0 || tr == nil || tr.CurrentReleaseDate == nil {
+		return
+	}
+	current, err := tr.CurrentReleaseDate.Time()
+	if err != nil {
+		return
+	}
+	newest := revs[len(revs)-1]
+	if latest, _ := newest.Date.Time(); current.Before(latest) {
+		t.add("6.2.6", "/document/tracking/current_release_date",
+			"current release date %s is older than the newest revision %s",
+			*tr.CurrentReleaseDate, *newest.Date)
+	}
+}
+
+// trackingOrNil returns the tracking section of the document if there is one.
+func (d *Document) trackingOrNil() *Tracking {
+	if d == nil {
+		return nil
+	}
+	return d.Tracking
+}
+
+func optionalMissingInvolvementDate(t *tester) {
+	for i, v := range t.adv.Vulnerabilities {
+		if v == nil {
+			continue
+		}
+		for j, inv := range v.Involvements {
+			if inv != nil && inv.Date == nil {
+				t.add("6.2.7", fmt.Sprintf("/vulnerabilities/%d/involvements/%d", i, j),
+					"date of involvement is missing")
+			}
+		}
+	}
+}
+
+func optionalOnlyHashAlgorithm(t *tester) {
+	names, pointers := t.adv.fullProductNames()
+	for i, fpn := range names {
+		pih := fpn.ProductIdentificationHelper
+		if pih == nil {
+			continue
+		}
+		for j, h := range pih.Hashes {
+			if h == nil || len(h.FileHashes) == 0 {
+				continue
+			}
+			algorithms := map[string]bool{}
+			for _, fh := range h.FileHashes {
+				if fh != nil {
+					algorithms[strings.ToLower(deref(fh.Algorithm))] = true
+				}
+			}
+			if len(algorithms) != 1 {
+				continue
+			}
+			pointer := fmt.Sprintf(
+				"%s/product_identification_helper/hashes/%d", pointers[i], j)
+			switch {
+			case algorithms["md5"]:
+				t.add("6.2.8", pointer, "MD5 is the only hash algorithm used")
+			case algorithms["sha1"]:
+				t.add("6.2.9", pointer, "SHA-1 is the only hash algorithm used")
+			}
+		}
+	}
+}
+
+func optionalMissingTLPLabel(t *tester) {
+	d := t.adv.Document
+	if d == nil {
+		return
+	}
+	if d.Distribution == nil || d.Distribution.TLP == nil || d.Distribution.TLP.Label == nil {
+		t.add("6.2.10", "/document/distribution/tlp/label", "TLP label is missing")
+	}
+}
+
+func optionalMissingCanonicalURL(t *tester) {
+	d := t.adv.Document
+	if d == nil || d.Tracking == nil || d.Tracking.ID == nil {
+		return
+	}
+	filename := util.CleanFileName(*d.Tracking.ID)
+	for _, r := range d.References {
+		if r == nil || r.Category == nil || *r.Category != ReferenceCategorySelf {
+			continue
+		}
+		if u := deref(r.URL); strings.HasPrefix(u, "https://") &&
+			strings.HasSuffix(u, "/"+filename) {
+			return
+		}
+	}
+	t.add("6.2.11", "/document/references",
+		"self reference with a https URL ending in %q is missing", filename)
+}
+
+func optionalMissingLanguage(t *tester) {
+	if d := t.adv.Document; d != nil && d.Lang == nil {
+		t.add("6.2.12", "/document/lang", "document language is missing")
+	}
+}
+
+// privateLanguage returns true if the language tag uses a private use
+// subtag or language from the private use range qaa-qtz.
+func privateLanguage(lang Lang) bool {
+	l := strings.ToLower(string(lang))
+	if strings.HasPrefix(l, "x-") || strings.Contains(l, "-x-") {
+		return true
+	}
+	primary := strings.SplitN(l, "-", 2)[0]
+	return len(primary) == 3 && primary >= "qaa" && primary <= "qtz"
+}
+
+func optionalPrivateLanguage(t *tester) {
+	d := t.adv.Document
+	if d == nil {
+		return
+	}
+	for _, x := range []struct {
+		lang    *Lang
+		pointer string
+	}{
+		{d.Lang, "/document/lang"},
+		{d.SourceLang, "/document/source_lang"},
+	} {
+		if x.lang != nil && privateLanguage(*x.lang) {
+			t.add("6.2.14", x.pointer, "private language %q is used", *x.lang)
+		}
+	}
+}
+
+func optionalDefaultLanguage(t *tester) {
+	d := t.adv.Document
+	if d == nil {
+		return
+	}
+	for _, x := range []struct {
+		lang    *Lang
+		pointer string
+	}{
+		{d.Lang, "/document/lang"},
+		{d.SourceLang, "/document/source_lang"},
+	} {
+		if x.lang != nil && strings.EqualFold(string(*x.lang), "i-default") {
+			t.add("6.2.15", x.pointer, "default language %q is used", *x.lang)
+		}
+	}
+}
+
+func optionalMissingIdentificationHelper(t *tester) {
+	names, pointers := t.adv.fullProductNames()
+	for i, fpn := range names {
+		if fpn.ProductIdentificationHelper == nil {
+			t.add("6.2.16", pointers[i],
+				"product identification helper for %q is missing", deref(fpn.Name))
+		}
+	}
+}
+
+var cvePattern = regexp.MustCompile(`^CVE-[0-9]{4}-[0-9]{4,}$`)
+
+func optionalCVEInIDs(t *tester) {
+	for i, v := range t.adv.Vulnerabilities {
+		if v == nil {
+			continue
+		}
+		for j, id := range v.IDs {
+			if id != nil && cvePattern.MatchString(deref(id.Text)) {
+				t.add("6.2.17", fmt.Sprintf("/vulnerabilities/%d/ids/%d", i, j),
+					"%s should be given as cve", *id.Text)
+			}
+		}
+	}
+}
+
+func optionalVersionRangeWithoutVers(t *tester) {
+	t.adv.walkBranches(func(b *Branch, pointer string) {
+		if b.Category != nil && *b.Category == BranchCategoryProductVersionRange &&
+			!strings.HasPrefix(deref(b.Name), "vers:") {
+			t.add("6.2.18", pointer+"/name",
+				"version range %q is not given in vers", deref(b.Name))
+		}
+	})
+}
+
+func optionalCVSSForFixedProducts(t *tester) {
+	for i, v := range t.adv.Vulnerabilities {
+		if v == nil || v.ProductStatus == nil {
+			continue
+		}
+		fixed := map[ProductID]bool{}
+		for _, p := range append(v.ProductStatus.FirstFixed, v.ProductStatus.Fixed...) {
+			fixed[p] = true
+		}
+		for j, s := range v.Scores {
+			if s == nil {
+				continue
+			}
+			pointer := fmt.Sprintf("/vulnerabilities/%d/scores/%d", i, j)
+			for _, p := range s.Products {
+				if !fixed[p] {
+					continue
+				}
+				if c := s.CVSSv2; c != nil &&
+					(c.EnvironmentalScore == nil || *c.EnvironmentalScore != 0) {
+					t.add("6.2.19", pointer+"/cvss_v2",
+						"fixed product %q has a CVSS v2 environmental score other than 0", p)
+				}
+				if c := s.CVSSv3; c != nil &&
+					(c.EnvironmentalScore == nil || *c.EnvironmentalScore != 0) {
+					t.add("6.2.19", pointer+"/cvss_v3",
+						"fixed product %q has a CVSS v3 environmental score other than 0", p)
+				}
+			}
+		}
+	}
+}
+
+// optionalAdditionalProperties reports the properties which
+// are not covered by the advisory model. As the model reflects
+// the JSON schema these are not defined by the standard.
+// This test needs the original JSON document.
+func optionalAdditionalProperties(t *tester) {
+	if t.doc == nil {
+		return
+	}
+	data, err := json.Marshal(t.adv)
+	if err != nil {
+		return
+	}
+	var known interface{}
+	if err := json.Unmarshal(data, &known); err != nil {
+		return
+	}
+	var compare func(orig, known interface{}, pointer string)
+	compare = func(orig, known interface{}, pointer string) {
+		switch o := orig.(type) {
+		case map[string]interface{}:
+			k, _ := known.(map[string]interface{})
+			for key, value := range o {
+				kv, ok := k[key]
+				p := pointer + "/" + escapePointer(key)
+				if !ok {
+					t.add("6.2.20", p, "additional property %q", key)
+					continue
+				}
+				compare(value, kv, p)
+			}
+		case []interface{}:
+			k, _ := known.([]interface{})
+			for i, value := range o {
+				if i < len(k) {
+					compare(value, k[i], fmt.Sprintf("%s/%d", pointer, i))
+				}
+			}
+		}
+	}
+	compare(t.doc, known, "")
+}
+
+// escapePointer escapes a key to be used in a JSON pointer.
+func escapePointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
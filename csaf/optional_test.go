@@ -0,0 +1,110 @@
+package csaf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTestConfigRun(t *testing.T) {
+	doc := loadTestDocument(t, func(doc map[string]interface{}) {
+		lookup(doc, "document").(map[string]interface{})["x_vendor_extension"] = true
+	})
+
+	tc := TestConfig{Optional: true, Enforce: []string{"6.2.16"}}
+	results, err := tc.Run(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var additional bool
+	for _, r := range results {
+		switch r.ID {
+		case "6.2.16":
+			if r.Severity != TestError {
+				t.Errorf("Expected enforced finding %s to be an error", r.String())
+			}
+		case "6.2.20":
+			additional = r.Pointer == "/document/x_vendor_extension"
+			if r.Severity != TestWarning {
+				t.Errorf("Expected finding %s to be a warning", r.String())
+			}
+		case "6.3.9":
+			if r.Severity != TestInfo {
+				t.Errorf("Expected finding %s to be an info", r.String())
+			}
+		}
+	}
+	if !additional {
+		t.Error("Additional property not found")
+	}
+	if len(results.BySeverity(TestError)) != 2 {
+		t.Errorf("Expected two errors, got: %v", results.BySeverity(TestError).Strings())
+	}
+}
+
+func TestRunOptionalTests(t *testing.T) {
+	for _, x := range []struct {
+		id       string
+		pointer  string
+		severity TestSeverity
+		modify   func(map[string]interface{})
+	}{
+		{"6.2.1", "/product_tree/full_product_names/1/product_id", TestWarning, func(doc map[string]interface{}) {
+			pt := lookup(doc, "product_tree").(map[string]interface{})
+			pt["full_product_names"] = append(pt["full_product_names"].([]interface{}),
+				map[string]interface{}{"name": "Unused", "product_id": "CSAFPID-0009"})
+		}},
+		{"6.2.3", "/vulnerabilities/0/product_status/known_affected/1", TestWarning, func(doc map[string]interface{}) {
+			ps := lookup(doc, "vulnerabilities", 0, "product_status").(map[string]interface{})
+			ps["known_affected"] = []interface{}{"CSAFPID-0001", "CSAFPID-0002"}
+		}},
+		{"6.2.5", "/document/tracking/initial_release_date", TestWarning, func(doc map[string]interface{}) {
+			lookup(doc, "document", "tracking").(map[string]interface{})["initial_release_date"] = "2021-12-01T00:00:00Z"
+		}},
+		{"6.3.1", "/vulnerabilities/0/scores/0", TestInfo, func(doc map[string]interface{}) {
+			delete(lookup(doc, "vulnerabilities", 0, "scores", 0).(map[string]interface{}), "cvss_v3")
+		}},
+		{"6.3.2", "/vulnerabilities/0/scores/0/cvss_v3/version", TestInfo, func(doc map[string]interface{}) {
+			cvss := lookup(doc, "vulnerabilities", 0, "scores", 0, "cvss_v3").(map[string]interface{})
+			cvss["version"] = "3.0"
+			cvss["vectorString"] = "CVSS:3.0/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H/MS:U"
+		}},
+		{"6.3.3", "/vulnerabilities/0/cve", TestInfo, func(doc map[string]interface{}) {
+			delete(lookup(doc, "vulnerabilities", 0).(map[string]interface{}), "cve")
+		}},
+		{"6.3.4", "/vulnerabilities/0/cwe", TestInfo, func(doc map[string]interface{}) {
+			delete(lookup(doc, "vulnerabilities", 0).(map[string]interface{}), "cwe")
+		}},
+	} {
+		// Without the modification the test must not report anything.
+		tc := TestConfig{Optional: true}
+		results, err := tc.Run(loadTestDocument(t, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range results {
+			if r.ID == x.id {
+				t.Errorf("%s: unexpected finding: %s", x.id, r.String())
+			}
+		}
+
+		if results, err = tc.Run(loadTestDocument(t, x.modify)); err != nil {
+			t.Fatal(err)
+		}
+		var found bool
+		var got []string
+		for _, r := range results {
+			got = append(got, r.String())
+			if r.ID == x.id && r.Pointer == x.pointer {
+				found = true
+				if r.Severity != x.severity {
+					t.Errorf("%s: expected severity %s, got %s", x.id, x.severity, r.Severity)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("%s: expected finding at %s, got: %s",
+				x.id, x.pointer, strings.Join(got, "; "))
+		}
+	}
+}
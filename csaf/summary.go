@@ -23,6 +23,7 @@ const (
 	tlpLabelExpr           = `$.document.distribution.tlp.label`
 	summaryExpr            = `$.document.notes[? @.category=="summary" || @.type=="summary"].text`
 	statusExpr             = `$.document.tracking.status`
+	versionExpr            = `$.document.tracking.version`
 )
 
 // AdvisorySummary is a summary of some essentials of an CSAF advisory.
@@ -35,6 +36,7 @@ type AdvisorySummary struct {
 	Summary            string
 	TLPLabel           string
 	Status             string
+	Version            string
 }
 
 // NewAdvisorySummary creates a summary from an advisory doc
@@ -57,6 +59,7 @@ func NewAdvisorySummary(
 		{Expr: tlpLabelExpr, Action: util.StringMatcher(&e.TLPLabel), Optional: true},
 		{Expr: publisherExpr, Action: util.ReMarshalMatcher(e.Publisher)},
 		{Expr: statusExpr, Action: util.StringMatcher(&e.Status)},
+		{Expr: versionExpr, Action: util.StringMatcher(&e.Version)},
 	}, doc); err != nil {
 		return nil, err
 	}
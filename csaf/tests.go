@@ -0,0 +1,623 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package csaf
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/csaf-poc/csaf_distribution/util"
+)
+
+// TestSeverity is the severity of a test finding.
+type TestSeverity string
+
+const (
+	// TestError is the severity of findings of the mandatory tests
+	// and of enforced optional or informative tests.
+	TestError TestSeverity = "error"
+	// TestWarning is the severity of findings of the optional tests.
+	TestWarning TestSeverity = "warning"
+	// TestInfo is the severity of findings of the informative tests.
+	TestInfo TestSeverity = "info"
+)
+
+// TestResult is a finding of one of the tests of section 6
+// of the CSAF standard.
+type TestResult struct {
+	// ID is the number of the test, e.g. "6.1.1".
+	ID string `json:"id"`
+	// Severity is the severity of the finding.
+	Severity TestSeverity `json:"severity"`
+	// Pointer is a JSON pointer to the element the finding is about.
+	Pointer string `json:"pointer"`
+	// Message describes the finding.
+	Message string `json:"message"`
+}
+
+// String implements the fmt.Stringer interface.
+func (tr *TestResult) String() string {
+	return tr.ID + ": " + tr.Pointer + ": " + tr.Message
+}
+
+// TestResults is a list of test findings.
+type TestResults []TestResult
+
+// Strings returns the findings as strings.
+func (trs TestResults) Strings() []string {
+	strs := make([]string, len(trs))
+	for i := range trs {
+		strs[i] = trs[i].String()
+	}
+	return strs
+}
+
+// BySeverity returns the findings of the given severity.
+func (trs TestResults) BySeverity(severity TestSeverity) TestResults {
+	var found TestResults
+	for i := range trs {
+		if trs[i].Severity == severity {
+			found = append(found, trs[i])
+		}
+	}
+	return found
+}
+
+// Enforce raises the severity of the findings of the tests
+// given by ids to TestError. An id also matches all the tests
+// it is a prefix of, e.g. "6.2" matches all optional tests.
+func (trs TestResults) Enforce(ids []string) TestResults {
+	for i := range trs {
+		for _, id := range ids {
+			if trs[i].ID == id || strings.HasPrefix(trs[i].ID, id+".") {
+				trs[i].Severity = TestError
+				break
+			}
+		}
+	}
+	return trs
+}
+
+// TestConfig selects the tests to be run on a document.
+type TestConfig struct {
+	// Mandatory runs the mandatory tests of section 6.1.
+	Mandatory bool
+	// Optional runs the optional and informative tests
+	// of the sections 6.2 and 6.3.
+	Optional bool
+	// Enforce are the ids of optional or informative tests
+	// whose findings are reported as errors. If not empty
+	// the optional and informative tests are run.
+	Enforce []string
+}
+
+// Enabled returns true if any tests are configured.
+func (tc *TestConfig) Enabled() bool {
+	return tc.Mandatory || tc.Optional || len(tc.Enforce) > 0
+}
+
+// Run runs the configured tests on the document doc.
+func (tc *TestConfig) Run(doc interface{}) (TestResults, error) {
+	var results TestResults
+	if tc.Mandatory {
+		rs, err := RunMandatoryTests(doc)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, rs...)
+	}
+	if tc.Optional || len(tc.Enforce) > 0 {
+		rs, err := RunOptionalTests(doc)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, rs.Enforce(tc.Enforce)...)
+		if rs, err = RunInformativeTests(doc); err != nil {
+			return nil, err
+		}
+		results = append(results, rs.Enforce(tc.Enforce)...)
+	}
+	return results, nil
+}
+
+// tester collects the results of the tests run on an advisory.
+type tester struct {
+	adv *Advisory
+	// doc is the de-serialized JSON document if the
+	// advisory was created from one.
+	doc      interface{}
+	severity TestSeverity
+	results  TestResults
+}
+
+// newTester creates a tester for a document which is either
+// an *Advisory or a de-serialized JSON document.
+func newTester(doc interface{}) (*tester, error) {
+	if adv, ok := doc.(*Advisory); ok {
+		return &tester{adv: adv}, nil
+	}
+	var adv Advisory
+	if err := util.ReMarshalJSON(&adv, doc); err != nil {
+		return nil, err
+	}
+	return &tester{adv: &adv, doc: doc}, nil
+}
+
+// add adds a finding.
+func (t *tester) add(id, pointer, format string, args ...interface{}) {
+	t.results = append(t.results, TestResult{
+		ID:       id,
+		Severity: t.severity,
+		Pointer:  pointer,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// run runs the given tests and returns the sorted findings.
+func (t *tester) run(tests []func(*tester), severity TestSeverity) TestResults {
+	t.severity = severity
+	for _, test := range tests {
+		test(t)
+	}
+	sort.SliceStable(t.results, func(i, j int) bool {
+		return compareTestIDs(t.results[i].ID, t.results[j].ID) < 0
+	})
+	return t.results
+}
+
+// compareTestIDs compares test ids numerically part by part.
+func compareTestIDs(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, _ := strconv.Atoi(as[i])
+		bn, _ := strconv.Atoi(bs[i])
+		if c := compareInts(an, bn); c != 0 {
+			return c
+		}
+	}
+	return compareInts(len(as), len(bs))
+}
+
+// productRef is a product id found in an advisory along with its location.
+type productRef struct {
+	id      ProductID
+	pointer string
+}
+
+// groupRef is a product group id found in an advisory along with its location.
+type groupRef struct {
+	id      ProductGroupID
+	pointer string
+}
+
+// productStatusField is a named list in the product status of a vulnerability.
+type productStatusField struct {
+	name     string
+	products Products
+}
+
+// fields returns the named lists of the product status.
+func (ps *ProductStatus) fields() []productStatusField {
+	if ps == nil {
+		return nil
+	}
+	return []productStatusField{
+		{"first_affected", ps.FirstAffected},
+		{"first_fixed", ps.FirstFixed},
+		{"fixed", ps.Fixed},
+		{"known_affected", ps.KnownAffected},
+		{"known_not_affected", ps.KnownNotAffected},
+		{"last_affected", ps.LastAffected},
+		{"recommended", ps.Recommended},
+		{"under_investigation", ps.UnderInvestigation},
+	}
+}
+
+func appendProducts(refs []productRef, products Products, pointer string) []productRef {
+	for i, p := range products {
+		refs = append(refs, productRef{p, fmt.Sprintf("%s/%d", pointer, i)})
+	}
+	return refs
+}
+
+func appendGroups(refs []groupRef, groups ProductGroups, pointer string) []groupRef {
+	for i, g := range groups {
+		refs = append(refs, groupRef{g, fmt.Sprintf("%s/%d", pointer, i)})
+	}
+	return refs
+}
+
+// productDefinitions returns all places in the product tree
+// where product ids are defined.
+func (adv *Advisory) productDefinitions() []productRef {
+	pt := adv.ProductTree
+	if pt == nil {
+		return nil
+	}
+	var refs []productRef
+
+	var branches func([]*Branch, string)
+	branches = func(bs []*Branch, pointer string) {
+		for i, b := range bs {
+			if b == nil {
+				continue
+			}
+			bp := fmt.Sprintf("%s/%d", pointer, i)
+			if b.Product != nil && b.Product.ProductID != nil {
+				refs = append(refs, productRef{
+					*b.Product.ProductID, bp + "/product/product_id"})
+			}
+			branches(b.Branches, bp+"/branches")
+		}
+	}
+	branches(pt.Branches, "/product_tree/branches")
+
+	for i, fpn := range pt.FullProductNames {
+		if fpn != nil && fpn.ProductID != nil {
+			refs = append(refs, productRef{
+				*fpn.ProductID,
+				fmt.Sprintf("/product_tree/full_product_names/%d/product_id", i)})
+		}
+	}
+	for i, r := range pt.Relationships {
+		if r != nil && r.FullProductName != nil && r.FullProductName.ProductID != nil {
+			refs = append(refs, productRef{
+				*r.FullProductName.ProductID,
+				fmt.Sprintf("/product_tree/relationships/%d/full_product_name/product_id", i)})
+		}
+	}
+	return refs
+}
+
+// productReferences returns all places in the advisory
+// where product ids are referenced.
+func (adv *Advisory) productReferences() []productRef {
+	var refs []productRef
+
+	if pt := adv.ProductTree; pt != nil {
+		for i, pg := range pt.ProductGroups {
+			if pg != nil {
+				refs = appendProducts(refs, pg.ProductIDs,
+					fmt.Sprintf("/product_tree/product_groups/%d/product_ids", i))
+			}
+		}
+		for i, r := range pt.Relationships {
+			if r == nil {
+				continue
+			}
+			pointer := fmt.Sprintf("/product_tree/relationships/%d", i)
+			if r.ProductReference != nil {
+				refs = append(refs, productRef{
+					*r.ProductReference, pointer + "/product_reference"})
+			}
+			if r.RelatesToProductReference != nil {
+				refs = append(refs, productRef{
+					*r.RelatesToProductReference, pointer + "/relates_to_product_reference"})
+			}
+		}
+	}
+
+	for i, v := range adv.Vulnerabilities {
+		if v == nil {
+			continue
+		}
+		pointer := fmt.Sprintf("/vulnerabilities/%d", i)
+		for _, f := range v.ProductStatus.fields() {
+			refs = appendProducts(refs, f.products, pointer+"/product_status/"+f.name)
+		}
+		for j, r := range v.Remediations {
+			if r != nil {
+				refs = appendProducts(refs, r.ProductIDs,
+					fmt.Sprintf("%s/remediations/%d/product_ids", pointer, j))
+			}
+		}
+		for j, s := range v.Scores {
+			if s != nil {
+				refs = appendProducts(refs, s.Products,
+					fmt.Sprintf("%s/scores/%d/products", pointer, j))
+			}
+		}
+		for j, t := range v.Threats {
+			if t != nil {
+				refs = appendProducts(refs, t.ProductIDs,
+					fmt.Sprintf("%s/threats/%d/product_ids", pointer, j))
+			}
+		}
+		for j, f := range v.Flags {
+			if f != nil {
+				refs = appendProducts(refs, f.ProductIDs,
+					fmt.Sprintf("%s/flags/%d/product_ids", pointer, j))
+			}
+		}
+	}
+	return refs
+}
+
+// groupDefinitions returns all places in the product tree
+// where product group ids are defined.
+func (adv *Advisory) groupDefinitions() []groupRef {
+	if adv.ProductTree == nil {
+		return nil
+	}
+	var refs []groupRef
+	for i, pg := range adv.ProductTree.ProductGroups {
+		if pg != nil && pg.GroupID != nil {
+			refs = append(refs, groupRef{
+				*pg.GroupID,
+				fmt.Sprintf("/product_tree/product_groups/%d/group_id", i)})
+		}
+	}
+	return refs
+}
+
+// groupReferences returns all places in the advisory
+// where product group ids are referenced.
+func (adv *Advisory) groupReferences() []groupRef {
+	var refs []groupRef
+	for i, v := range adv.Vulnerabilities {
+		if v == nil {
+			continue
+		}
+		pointer := fmt.Sprintf("/vulnerabilities/%d", i)
+		for j, r := range v.Remediations {
+			if r != nil {
+				refs = appendGroups(refs, r.GroupIDs,
+					fmt.Sprintf("%s/remediations/%d/group_ids", pointer, j))
+			}
+		}
+		for j, t := range v.Threats {
+			if t != nil {
+				refs = appendGroups(refs, t.GroupIDs,
+					fmt.Sprintf("%s/threats/%d/group_ids", pointer, j))
+			}
+		}
+		for j, f := range v.Flags {
+			if f != nil {
+				refs = appendGroups(refs, f.GroupIDs,
+					fmt.Sprintf("%s/flags/%d/group_ids", pointer, j))
+			}
+		}
+	}
+	return refs
+}
+
+// groupMembers returns a mapping of the product group ids
+// to the products in these groups.
+func (adv *Advisory) groupMembers() map[ProductGroupID]Products {
+	members := map[ProductGroupID]Products{}
+	if adv.ProductTree == nil {
+		return members
+	}
+	for _, pg := range adv.ProductTree.ProductGroups {
+		if pg != nil && pg.GroupID != nil {
+			members[*pg.GroupID] = append(members[*pg.GroupID], pg.ProductIDs...)
+		}
+	}
+	return members
+}
+
+// resolveProducts returns the given products plus the products
+// of the given groups.
+func resolveProducts(
+	products Products,
+	groups ProductGroups,
+	members map[ProductGroupID]Products,
+) Products {
+	all := make(Products, 0, len(products))
+	all = append(all, products...)
+	for _, g := range groups {
+		all = append(all, members[g]...)
+	}
+	return all
+}
+
+// walkBranches calls fn for every branch in the product tree.
+func (adv *Advisory) walkBranches(fn func(*Branch, string)) {
+	if adv.ProductTree == nil {
+		return
+	}
+	var recurse func([]*Branch, string)
+	recurse = func(bs []*Branch, pointer string) {
+		for i, b := range bs {
+			if b == nil {
+				continue
+			}
+			bp := fmt.Sprintf("%s/%d", pointer, i)
+			fn(b, bp)
+			recurse(b.Branches, bp+"/branches")
+		}
+	}
+	recurse(adv.ProductTree.Branches, "/product_tree/branches")
+}
+
+// fullProductNames returns all full product names of the
+// product tree along with their location.
+func (adv *Advisory) fullProductNames() ([]*FullProductName, []string) {
+	var (
+		names    []*FullProductName
+		pointers []string
+	)
+	adv.walkBranches(func(b *Branch, pointer string) {
+		if b.Product != nil {
+			names = append(names, b.Product)
+			pointers = append(pointers, pointer+"/product")
+		}
+	})
+	if pt := adv.ProductTree; pt != nil {
+		for i, fpn := range pt.FullProductNames {
+			if fpn != nil {
+				names = append(names, fpn)
+				pointers = append(pointers,
+					fmt.Sprintf("/product_tree/full_product_names/%d", i))
+			}
+		}
+		for i, r := range pt.Relationships {
+			if r != nil && r.FullProductName != nil {
+				names = append(names, r.FullProductName)
+				pointers = append(pointers,
+					fmt.Sprintf("/product_tree/relationships/%d/full_product_name", i))
+			}
+		}
+	}
+	return names, pointers
+}
+
+// sortedRevisions returns the revision history sorted ascending by date
+// along with the original indices of the entries. Entries with unparsable
+// dates are left out.
+func (adv *Advisory) sortedRevisions() ([]*Revision, []int) {
+	if adv.Document == nil || adv.Document.Tracking == nil {
+		return nil, nil
+	}
+	type indexed struct {
+		rev   *Revision
+		index int
+		date  int64
+	}
+	var revs []indexed
+	for i, r := range adv.Document.Tracking.RevisionHistory {
+		if r == nil || r.Date == nil || r.Number == nil {
+			continue
+		}
+		t, err := r.Date.Time()
+		if err != nil {
+			continue
+		}
+		revs = append(revs, indexed{r, i, t.UnixNano()})
+	}
+	sort.SliceStable(revs, func(i, j int) bool {
+		if revs[i].date != revs[j].date {
+			return revs[i].date < revs[j].date
+		}
+		return compareRevisionNumbers(*revs[i].rev.Number, *revs[j].rev.Number) < 0
+	})
+	sorted := make([]*Revision, len(revs))
+	indices := make([]int, len(revs))
+	for i := range revs {
+		sorted[i], indices[i] = revs[i].rev, revs[i].index
+	}
+	return sorted, indices
+}
+
+// deref returns the value of s or the empty string if s is nil.
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+var (
+	intVersionPattern = regexp.MustCompile(`^(0|[1-9][0-9]*)$`)
+	semVersionPattern = regexp.MustCompile(
+		`^(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)` +
+			`(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?` +
+			`(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?$`)
+)
+
+// revisionVersion is a parsed revision number.
+type revisionVersion struct {
+	valid    bool
+	semantic bool
+	major    int
+	minor    int
+	patch    int
+	pre      string
+	build    string
+}
+
+// parseRevisionNumber parses an integer or a semantic version.
+func parseRevisionNumber(rn RevisionNumber) revisionVersion {
+	s := string(rn)
+	if intVersionPattern.MatchString(s) {
+		major, err := strconv.Atoi(s)
+		return revisionVersion{valid: err == nil, major: major}
+	}
+	m := semVersionPattern.FindStringSubmatch(s)
+	if m == nil {
+		return revisionVersion{}
+	}
+	v := revisionVersion{valid: true, semantic: true, pre: m[4], build: m[5]}
+	var err1, err2, err3 error
+	v.major, err1 = strconv.Atoi(m[1])
+	v.minor, err2 = strconv.Atoi(m[2])
+	v.patch, err3 = strconv.Atoi(m[3])
+	v.valid = err1 == nil && err2 == nil && err3 == nil
+	return v
+}
+
+// zero returns true if the version is 0 or 0.y.z.
+func (v revisionVersion) zero() bool {
+	return v.valid && v.major == 0
+}
+
+// comparePreReleases compares two pre-release parts
+// following the precedence rules of semantic versioning.
+func comparePreReleases(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aerr := strconv.Atoi(as[i])
+		bn, berr := strconv.Atoi(bs[i])
+		switch {
+		case aerr == nil && berr == nil:
+			if an != bn {
+				return compareInts(an, bn)
+			}
+		case aerr == nil:
+			return -1
+		case berr == nil:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return compareInts(len(as), len(bs))
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareRevisionNumbers compares two revision numbers.
+// Numbers which are not of the same kind are compared as strings.
+func compareRevisionNumbers(a, b RevisionNumber) int {
+	va, vb := parseRevisionNumber(a), parseRevisionNumber(b)
+	if !va.valid || !vb.valid || va.semantic != vb.semantic {
+		return strings.Compare(string(a), string(b))
+	}
+	if c := compareInts(va.major, vb.major); c != 0 {
+		return c
+	}
+	if c := compareInts(va.minor, vb.minor); c != 0 {
+		return c
+	}
+	if c := compareInts(va.patch, vb.patch); c != 0 {
+		return c
+	}
+	return comparePreReleases(va.pre, vb.pre)
+}
@@ -44,6 +44,9 @@ var (
 	compiledProviderSchema   compiledSchema
 	compiledAggregatorSchema compiledSchema
 	compiledRolieSchema      compiledSchema
+	compiledCVSS20Schema     compiledSchema
+	compiledCVSS30Schema     compiledSchema
+	compiledCVSS31Schema     compiledSchema
 )
 
 func init() {
@@ -65,6 +68,15 @@ func init() {
 	compiledRolieSchema.compiler([]schemaData{
 		{"https://raw.githubusercontent.com/tschmidtb51/csaf/ROLIE-schema/csaf_2.0/json_schema/ROLIE_feed_json_schema.json", rolieSchema},
 	})
+	compiledCVSS20Schema.compiler([]schemaData{
+		{"https://www.first.org/cvss/cvss-v2.0.json", cvss20},
+	})
+	compiledCVSS30Schema.compiler([]schemaData{
+		{"https://www.first.org/cvss/cvss-v3.0.json", cvss30},
+	})
+	compiledCVSS31Schema.compiler([]schemaData{
+		{"https://www.first.org/cvss/cvss-v3.1.json", cvss31},
+	})
 }
 
 type schemaData struct {
@@ -0,0 +1,223 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package util
+
+import (
+	"bytes"
+	"container/list"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+const (
+	defaultCacheEntries = 1000
+	defaultCacheSize    = 4 << 20
+)
+
+// HTTPCache stores the responses of GET requests with
+// an ETag or a Last-Modified header. It can be shared
+// by CachingClients used in parallel.
+type HTTPCache struct {
+	// MaxEntries is the maximal number of cached responses (default 1000).
+	// The least recently used ones are dropped.
+	MaxEntries int
+	// MaxSize is the maximal size of a cached body (default 4MiB).
+	MaxSize int64
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     list.List
+}
+
+// cacheEntry is a cached response.
+type cacheEntry struct {
+	url          string
+	etag         string
+	lastModified string
+	header       http.Header
+	body         []byte
+}
+
+// CachingClient is a Client sending conditional GET requests
+// for the responses stored in its Cache. If the server answers
+// with 304 Not Modified the stored response is returned.
+type CachingClient struct {
+	Client
+	Cache *HTTPCache
+}
+
+// readCloser combines a reader with the closer of another one.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// get returns the cached response of an URL. Nil if there is none.
+func (hc *HTTPCache) get(url string) *cacheEntry {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	if elem := hc.entries[url]; elem != nil {
+		hc.lru.MoveToFront(elem)
+		return elem.Value.(*cacheEntry)
+	}
+	return nil
+}
+
+// put stores a response and drops the least recently used
+// ones if there are too many.
+func (hc *HTTPCache) put(entry *cacheEntry) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	if hc.entries == nil {
+		hc.entries = make(map[string]*list.Element)
+	}
+	if elem := hc.entries[entry.url]; elem != nil {
+		elem.Value = entry
+		hc.lru.MoveToFront(elem)
+		return
+	}
+	hc.entries[entry.url] = hc.lru.PushFront(entry)
+	limit := hc.MaxEntries
+	if limit <= 0 {
+		limit = defaultCacheEntries
+	}
+	for hc.lru.Len() > limit {
+		oldest := hc.lru.Back()
+		hc.lru.Remove(oldest)
+		delete(hc.entries, oldest.Value.(*cacheEntry).url)
+	}
+}
+
+// remove drops the cached response of an URL.
+func (hc *HTTPCache) remove(url string) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	if elem := hc.entries[url]; elem != nil {
+		hc.lru.Remove(elem)
+		delete(hc.entries, url)
+	}
+}
+
+// maxSize returns the maximal size of a cached body.
+func (hc *HTTPCache) maxSize() int64 {
+	if hc.MaxSize <= 0 {
+		return defaultCacheSize
+	}
+	return hc.MaxSize
+}
+
+// Do implements the respective method of the Client interface.
+func (cc *CachingClient) Do(req *http.Request) (*http.Response, error) {
+
+	if req.Method != "" && req.Method != http.MethodGet {
+		return cc.Client.Do(req)
+	}
+
+	key := req.URL.String()
+	entry := cc.Cache.get(key)
+
+	if entry != nil {
+		// Do not modify the request of the caller.
+		req = req.Clone(req.Context())
+		if entry.etag != "" && req.Header.Get("If-None-Match") == "" {
+			req.Header.Set("If-None-Match", entry.etag)
+		}
+		if entry.lastModified != "" && req.Header.Get("If-Modified-Since") == "" {
+			req.Header.Set("If-Modified-Since", entry.lastModified)
+		}
+	}
+
+	res, err := cc.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case res.StatusCode == http.StatusNotModified && entry != nil:
+		res.Body.Close()
+		return &http.Response{
+			Status:        "200 OK",
+			StatusCode:    http.StatusOK,
+			Proto:         res.Proto,
+			ProtoMajor:    res.ProtoMajor,
+			ProtoMinor:    res.ProtoMinor,
+			Header:        entry.header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader(entry.body)),
+			ContentLength: int64(len(entry.body)),
+			Request:       res.Request,
+			TLS:           res.TLS,
+		}, nil
+
+	case res.StatusCode != http.StatusOK:
+		return res, nil
+	}
+
+	etag := res.Header.Get("ETag")
+	lastModified := res.Header.Get("Last-Modified")
+	limit := cc.Cache.maxSize()
+
+	if etag == "" && lastModified == "" || res.ContentLength > limit {
+		cc.Cache.remove(key)
+		return res, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, limit+1))
+	if err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+
+	if int64(len(body)) > limit {
+		// Too large to be cached. Hand out what is read and the rest.
+		cc.Cache.remove(key)
+		res.Body = readCloser{
+			Reader: io.MultiReader(bytes.NewReader(body), res.Body),
+			Closer: res.Body,
+		}
+		return res, nil
+	}
+	res.Body.Close()
+
+	cc.Cache.put(&cacheEntry{
+		url:          key,
+		etag:         etag,
+		lastModified: lastModified,
+		header:       res.Header.Clone(),
+		body:         body,
+	})
+
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	return res, nil
+}
+
+// Get implements the respective method of the Client interface.
+func (cc *CachingClient) Get(url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return cc.Do(req)
+}
+
+// Head implements the respective method of the Client interface.
+func (cc *CachingClient) Head(url string) (*http.Response, error) {
+	return cc.Client.Head(url)
+}
+
+// Post implements the respective method of the Client interface.
+func (cc *CachingClient) Post(url, contentType string, body io.Reader) (*http.Response, error) {
+	return cc.Client.Post(url, contentType, body)
+}
+
+// PostForm implements the respective method of the Client interface.
+func (cc *CachingClient) PostForm(url string, data url.Values) (*http.Response, error) {
+	return cc.Client.PostForm(url, data)
+}
@@ -10,10 +10,12 @@ package util
 
 import (
 	"context"
+	"errors"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"strings"
 
 	"golang.org/x/time/rate"
 )
@@ -41,7 +43,7 @@ type LimitingClient struct {
 // Do implements the respective method of the Client interface.
 func (lc *LoggingClient) Do(req *http.Request) (*http.Response, error) {
 	log.Printf("[DO]: %s\n", req.URL.String())
-	return lc.Do(req)
+	return lc.Client.Do(req)
 }
 
 // Get implements the respective method of the Client interface.
@@ -53,19 +55,19 @@ func (lc *LoggingClient) Get(url string) (*http.Response, error) {
 // Head implements the respective method of the Client interface.
 func (lc *LoggingClient) Head(url string) (*http.Response, error) {
 	log.Printf("[HEAD]: %s\n", url)
-	return lc.Head(url)
+	return lc.Client.Head(url)
 }
 
 // Post implements the respective method of the Client interface.
 func (lc *LoggingClient) Post(url, contentType string, body io.Reader) (*http.Response, error) {
 	log.Printf("[POST]: %s\n", url)
-	return lc.Post(url, contentType, body)
+	return lc.Client.Post(url, contentType, body)
 }
 
 // PostForm implements the respective method of the Client interface.
 func (lc *LoggingClient) PostForm(url string, data url.Values) (*http.Response, error) {
 	log.Printf("[POST FORM]: %s\n", url)
-	return lc.PostForm(url, data)
+	return lc.Client.PostForm(url, data)
 }
 
 // Do implements the respective method of the Client interface.
@@ -97,3 +99,89 @@ func (lc *LimitingClient) PostForm(url string, data url.Values) (*http.Response,
 	lc.Limiter.Wait(context.Background())
 	return lc.Client.PostForm(url, data)
 }
+
+// HeaderClient is a Client adding extra headers to the requests
+// to the given hosts. Without hosts they are added to all requests.
+type HeaderClient struct {
+	Client
+	Header http.Header
+	Hosts  []string
+}
+
+// sendsTo checks if the headers are sent to the host of u.
+func (hc *HeaderClient) sendsTo(u *url.URL) bool {
+	if len(hc.Hosts) == 0 {
+		return true
+	}
+	host := u.Hostname()
+	for _, h := range hc.Hosts {
+		if strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckRedirect removes the extra headers from requests redirected
+// to other hosts. It is meant to be the CheckRedirect function
+// of the http.Client used by the HeaderClient.
+func (hc *HeaderClient) CheckRedirect(req *http.Request, via []*http.Request) error {
+	// Same limit as the default of http.Client.
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if !hc.sendsTo(req.URL) {
+		for key := range hc.Header {
+			req.Header.Del(key)
+		}
+	}
+	return nil
+}
+
+// Do implements the respective method of the Client interface.
+func (hc *HeaderClient) Do(req *http.Request) (*http.Response, error) {
+	if hc.sendsTo(req.URL) {
+		// Do not modify the request of the caller.
+		req = req.Clone(req.Context())
+		for key, values := range hc.Header {
+			for _, v := range values {
+				req.Header.Add(key, v)
+			}
+		}
+	}
+	return hc.Client.Do(req)
+}
+
+// Get implements the respective method of the Client interface.
+func (hc *HeaderClient) Get(url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return hc.Do(req)
+}
+
+// Head implements the respective method of the Client interface.
+func (hc *HeaderClient) Head(url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodHead, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return hc.Do(req)
+}
+
+// Post implements the respective method of the Client interface.
+func (hc *HeaderClient) Post(url, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return hc.Do(req)
+}
+
+// PostForm implements the respective method of the Client interface.
+func (hc *HeaderClient) PostForm(url string, data url.Values) (*http.Response, error) {
+	return hc.Post(
+		url, "application/x-www-form-urlencoded", strings.NewReader(data.Encode()))
+}
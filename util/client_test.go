@@ -0,0 +1,148 @@
+package util
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryingClient(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch calls {
+		case 1:
+			http.Error(w, "down", http.StatusBadGateway)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+		default:
+			io.WriteString(w, "ok")
+		}
+	}))
+	defer srv.Close()
+
+	client := &RetryingClient{
+		Client:     srv.Client(),
+		Retries:    2,
+		MinBackoff: time.Millisecond,
+	}
+
+	res, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK || calls != 3 {
+		t.Fatalf("expected 200 after 3 calls, got %d after %d", res.StatusCode, calls)
+	}
+
+	// Give up after the configured retries.
+	calls = 0
+	client.Retries = 1
+	res, err = client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusServiceUnavailable || calls != 2 {
+		t.Fatalf("expected 503 after 2 calls, got %d after %d", res.StatusCode, calls)
+	}
+}
+
+func TestCachingClient(t *testing.T) {
+	var full, notModified int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full++
+		io.WriteString(w, "content")
+	}))
+	defer srv.Close()
+
+	client := &CachingClient{Client: srv.Client(), Cache: new(HTTPCache)}
+
+	for i := 0; i < 2; i++ {
+		res, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != http.StatusOK || string(body) != "content" {
+			t.Fatalf("unexpected response %d %q", res.StatusCode, body)
+		}
+	}
+	if full != 1 || notModified != 1 {
+		t.Fatalf("expected 1 full and 1 conditional response, got %d and %d",
+			full, notModified)
+	}
+}
+
+func TestHeaderClient(t *testing.T) {
+	var got []string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("X-Token"))
+	}))
+	defer other.Close()
+	// Same server under another host name.
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("X-Token"))
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, otherURL, http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+
+	hClient := srv.Client()
+	client := &HeaderClient{
+		Client: hClient,
+		Header: http.Header{"X-Token": []string{"secret"}},
+		Hosts:  []string{"127.0.0.1"},
+	}
+	hClient.CheckRedirect = client.CheckRedirect
+
+	for _, u := range []string{srv.URL, otherURL, srv.URL + "/redirect"} {
+		res, err := client.Get(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+	if want := []string{"secret", "", "secret", ""}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected headers %q, got %q", want, got)
+	}
+
+	// The request of the caller is not modified and
+	// the headers are not added twice when it is sent again.
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.Request.Header.Values("X-Token") == nil {
+			t.Error("expected header in sent request")
+		}
+	}
+	if v := req.Header.Values("X-Token"); len(v) != 0 {
+		t.Errorf("expected unmodified request, got headers %q", v)
+	}
+}
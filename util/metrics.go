@@ -0,0 +1,279 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package util
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gofrs/flock"
+)
+
+// Metrics is a registry of counters and gauges which can be exported
+// in the text format of Prometheus. All methods of a nil Metrics
+// do nothing so it can be used if no metrics are configured.
+type Metrics struct {
+	mu       sync.Mutex
+	families map[string]*metricFamily
+}
+
+// metricFamily are the values of a metric by their labels.
+type metricFamily struct {
+	typ    string
+	help   string
+	values map[string]float64
+}
+
+// NewMetrics creates an empty registry.
+func NewMetrics() *Metrics {
+	return &Metrics{families: make(map[string]*metricFamily)}
+}
+
+func (m *Metrics) register(name, typ, help string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if f := m.families[name]; f != nil {
+		f.typ, f.help = typ, help
+		return
+	}
+	m.families[name] = &metricFamily{
+		typ:    typ,
+		help:   help,
+		values: make(map[string]float64),
+	}
+}
+
+// Counter registers a counter.
+func (m *Metrics) Counter(name, help string) {
+	m.register(name, "counter", help)
+}
+
+// Gauge registers a gauge.
+func (m *Metrics) Gauge(name, help string) {
+	m.register(name, "gauge", help)
+}
+
+// family returns the family of the given name.
+// Not registered metrics are created untyped.
+// Expects the lock to be held.
+func (m *Metrics) family(name string) *metricFamily {
+	f := m.families[name]
+	if f == nil {
+		f = &metricFamily{typ: "untyped", values: make(map[string]float64)}
+		m.families[name] = f
+	}
+	return f
+}
+
+// Add adds v to the metric with the given labels. The labels
+// are given as pairs of names and values.
+func (m *Metrics) Add(name string, v float64, labels ...string) {
+	if m == nil {
+		return
+	}
+	key := formatLabels(labels)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.family(name).values[key] += v
+}
+
+// Set sets the metric with the given labels to v. The labels
+// are given as pairs of names and values.
+func (m *Metrics) Set(name string, v float64, labels ...string) {
+	if m == nil {
+		return
+	}
+	key := formatLabels(labels)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.family(name).values[key] = v
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels renders label pairs in the text format.
+func formatLabels(labels []string) string {
+	if len(labels) < 2 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(labels[i])
+		b.WriteString(`="`)
+		labelEscaper.WriteString(&b, labels[i+1])
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// WriteTo writes the metrics in the text format of Prometheus.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	if m == nil {
+		return 0, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.families))
+	for name := range m.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	nw := NWriter{Writer: w}
+	out := bufio.NewWriter(&nw)
+
+	for _, name := range names {
+		f := m.families[name]
+		if len(f.values) == 0 {
+			continue
+		}
+		if f.help != "" {
+			fmt.Fprintf(out, "# HELP %s %s\n", name, f.help)
+		}
+		fmt.Fprintf(out, "# TYPE %s %s\n", name, f.typ)
+		keys := make([]string, 0, len(f.values))
+		for key := range f.values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(out, "%s%s %s\n", name, key,
+				strconv.FormatFloat(f.values[key], 'g', -1, 64))
+		}
+	}
+	err := out.Flush()
+	return nw.N, err
+}
+
+// ReadFrom loads the values of metrics written by WriteTo.
+// This is used to continue counting across processes.
+func (m *Metrics) ReadFrom(r io.Reader) (int64, error) {
+	if m == nil {
+		return 0, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	nr := &nReader{Reader: r}
+	scanner := bufio.NewScanner(nr)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		idx := strings.LastIndexByte(line, ' ')
+		if idx < 0 {
+			return nr.n, fmt.Errorf("invalid metric line %q", line)
+		}
+		v, err := strconv.ParseFloat(line[idx+1:], 64)
+		if err != nil {
+			return nr.n, fmt.Errorf("invalid metric line %q: %v", line, err)
+		}
+		series := line[:idx]
+		name, key := series, ""
+		if brace := strings.IndexByte(series, '{'); brace >= 0 {
+			name, key = series[:brace], series[brace:]
+		}
+		m.family(name).values[key] = v
+	}
+	return nr.n, scanner.Err()
+}
+
+type nReader struct {
+	io.Reader
+	n int64
+}
+
+func (nr *nReader) Read(p []byte) (int, error) {
+	n, err := nr.Reader.Read(p)
+	nr.n += int64(n)
+	return n, err
+}
+
+// ServeHTTP implements http.Handler to be scraped by Prometheus.
+func (m *Metrics) ServeHTTP(rw http.ResponseWriter, _ *http.Request) {
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(rw)
+}
+
+// ReadFile loads the values of metrics from a file written by WriteFile.
+// A missing file is not an error.
+func (m *Metrics) ReadFile(fname string) error {
+	if m == nil {
+		return nil
+	}
+	f, err := os.Open(fname)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	_, err = m.ReadFrom(f)
+	return err
+}
+
+// WriteFile atomically writes the metrics to a file,
+// e.g. to be picked up by the textfile collector of
+// the Prometheus node exporter.
+func (m *Metrics) WriteFile(fname string) error {
+	if m == nil {
+		return nil
+	}
+	tmp, f, err := MakeUniqFile(fname + ".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := m.WriteTo(f); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, fname)
+}
+
+// UpdateFile loads the metrics from a file, calls fn
+// and writes them back. A lock file guards against
+// concurrent updates by other processes.
+func (m *Metrics) UpdateFile(fname string, fn func(*Metrics)) error {
+	if m == nil {
+		return nil
+	}
+	fl := flock.New(fname + ".lock")
+	if err := fl.Lock(); err != nil {
+		return err
+	}
+	defer fl.Unlock()
+	if err := m.ReadFile(fname); err != nil {
+		return err
+	}
+	fn(m)
+	return m.WriteFile(fname)
+}
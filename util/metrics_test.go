@@ -0,0 +1,61 @@
+package util
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMetricsRoundTrip(t *testing.T) {
+	m := NewMetrics()
+	m.Counter("uploads_total", "Number of uploads.")
+	m.Gauge("duration_seconds", "Duration of the last run.")
+	m.Add("uploads_total", 2, "reason", `a "quoted"`+"\nvalue")
+	m.Add("uploads_total", 1)
+	m.Set("duration_seconds", 1.5, "provider", "p1", "mode", "full")
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	const expected = `# HELP duration_seconds Duration of the last run.
+# TYPE duration_seconds gauge
+duration_seconds{provider="p1",mode="full"} 1.5
+# HELP uploads_total Number of uploads.
+# TYPE uploads_total counter
+uploads_total 1
+uploads_total{reason="a \"quoted\"\nvalue"} 2
+`
+	if got := buf.String(); got != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+
+	// Continue counting from the written values.
+	n := NewMetrics()
+	n.Counter("uploads_total", "Number of uploads.")
+	n.Gauge("duration_seconds", "Duration of the last run.")
+	if _, err := n.ReadFrom(&buf); err != nil {
+		t.Fatal(err)
+	}
+	n.Add("uploads_total", 1)
+
+	buf.Reset()
+	if _, err := n.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); !strings.Contains(got, "\nuploads_total 2\n") ||
+		!strings.Contains(got, `{provider="p1",mode="full"} 1.5`) {
+		t.Fatalf("unexpected after reading back:\n%s", got)
+	}
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *Metrics
+	m.Counter("x", "")
+	m.Add("x", 1)
+	m.Set("x", 1)
+	if n, err := m.WriteTo(nil); n != 0 || err != nil {
+		t.Errorf("expected nothing written, got %d, %v", n, err)
+	}
+}
@@ -0,0 +1,193 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package util
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultMinBackoff = time.Second
+	defaultMaxBackoff = time.Minute
+)
+
+// RetryingClient is a Client retrying idempotent requests
+// which failed because of network errors or transient server
+// errors. The delay between the attempts grows exponentially
+// from MinBackoff to MaxBackoff. A Retry-After header of the
+// server is honored. If it asks to wait longer than MaxBackoff
+// the response is returned without further attempts.
+type RetryingClient struct {
+	Client
+	// Retries is the maximal number of retries of a request.
+	Retries int
+	// MinBackoff is the delay before the first retry (default 1s).
+	MinBackoff time.Duration
+	// MaxBackoff is the maximal delay between two attempts (default 1m).
+	MaxBackoff time.Duration
+}
+
+// idempotent checks if a request with the given method can be repeated.
+func idempotent(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions,
+		http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// transient checks if a status code is worth a retry.
+func transient(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout,
+		http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfter returns the delay requested by the Retry-After
+// header of a response. Zero if there is none.
+func retryAfter(res *http.Response, now time.Time) time.Duration {
+	value := res.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// limits returns the minimal and the maximal backoff.
+func (rc *RetryingClient) limits() (time.Duration, time.Duration) {
+	minBackoff, maxBackoff := rc.MinBackoff, rc.MaxBackoff
+	if minBackoff <= 0 {
+		minBackoff = defaultMinBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+	return minBackoff, maxBackoff
+}
+
+// backoff returns the delay before the given retry.
+func (rc *RetryingClient) backoff(retry int) time.Duration {
+	minBackoff, maxBackoff := rc.limits()
+	delay := minBackoff
+	for i := 0; i < retry && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
+
+// Do implements the respective method of the Client interface.
+func (rc *RetryingClient) Do(req *http.Request) (*http.Response, error) {
+
+	if rc.Retries <= 0 || !idempotent(req.Method) ||
+		req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return rc.Client.Do(req)
+	}
+
+	ctx := req.Context()
+
+	for retry := 0; ; retry++ {
+		// Each attempt gets its own copy as the
+		// decorated clients may modify the request.
+		attempt := req.Clone(ctx)
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attempt.Body = body
+		}
+
+		res, err := rc.Client.Do(attempt)
+		if retry >= rc.Retries || err == nil && !transient(res.StatusCode) {
+			return res, err
+		}
+
+		delay := rc.backoff(retry)
+		if err == nil {
+			if after := retryAfter(res, time.Now()); after > 0 {
+				if _, maxBackoff := rc.limits(); after > maxBackoff {
+					return res, nil
+				}
+				if after > delay {
+					delay = after
+				}
+			}
+			// Drain the body to reuse the connection.
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+			log.Printf("%s %s: %s, retrying in %s\n",
+				attempt.Method, attempt.URL, res.Status, delay)
+		} else {
+			log.Printf("%s %s: %v, retrying in %s\n",
+				attempt.Method, attempt.URL, err, delay)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Get implements the respective method of the Client interface.
+func (rc *RetryingClient) Get(url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return rc.Do(req)
+}
+
+// Head implements the respective method of the Client interface.
+func (rc *RetryingClient) Head(url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodHead, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return rc.Do(req)
+}
+
+// Post implements the respective method of the Client interface.
+// POST requests are not idempotent and not retried.
+func (rc *RetryingClient) Post(url, contentType string, body io.Reader) (*http.Response, error) {
+	return rc.Client.Post(url, contentType, body)
+}
+
+// PostForm implements the respective method of the Client interface.
+// POST requests are not idempotent and not retried.
+func (rc *RetryingClient) PostForm(url string, data url.Values) (*http.Response, error) {
+	return rc.Client.PostForm(url, data)
+}